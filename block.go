@@ -2,7 +2,6 @@ package cacher
 
 import (
 	"context"
-	"log"
 	"time"
 )
 
@@ -24,6 +23,10 @@ type (
 
 		// SkipLocalCache skips local cache as if it is not set.
 		SkipLocalCache bool
+
+		// Version identifies the write of the value,it is assigned by the cacher
+		// and compared by CompareAndSwap. 0 means the key does not exist.
+		Version uint64
 	}
 )
 
@@ -37,6 +40,7 @@ func (self *CacheBlock) Clone() *CacheBlock {
 		SetOnlyExist:   self.SetOnlyExist,
 		SetOnlyNew:     self.SetOnlyNew,
 		SkipLocalCache: self.SkipLocalCache,
+		Version:        self.Version,
 	}
 }
 
@@ -56,7 +60,7 @@ func (self *CacheBlock) Ttl() time.Duration {
 
 	if self.TTL != 0 {
 		if self.TTL < time.Second {
-			log.Printf("too short TTL for key=%q: %s", self.Key, self.TTL)
+			DefaultLogger().Warn("cache: too short TTL", "key", self.Key, "ttl", self.TTL)
 			return defaultTTL
 		}
 		return self.TTL
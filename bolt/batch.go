@@ -0,0 +1,234 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/volts-dev/cacher"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// keys deleted per transaction by a sweep,so the writers are not blocked for long
+const sweepBatch = 1000
+
+// GetMulti reads the keys in one transaction,missing keys are omitted.
+func (self *BoltCache) GetMulti(keys []string, ctx ...context.Context) (map[string]any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	db, err := self.check(cacher.Context(ctx...))
+	if err != nil {
+		return nil, err
+	}
+
+	now := self.config.Clock.Now()
+	raw := make(map[string][]byte, len(keys))
+	err = db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(dataBucket)
+		for _, key := range keys {
+			if v := data.Get([]byte(key)); v != nil {
+				if expireAt, value := decodeValue(v); !expired(expireAt, now) {
+					raw[key] = bytes.Clone(value)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]any, len(raw))
+	for key, b := range raw {
+		var value any
+		if err := self.config.Unmarshal(b, &value); err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// SetMulti writes the blocks in one transaction.
+func (self *BoltCache) SetMulti(blocks ...*cacher.CacheBlock) error {
+	if !self.config.Active {
+		return nil
+	}
+
+	db, err := self.check(context.Background())
+	if err != nil {
+		return err
+	}
+
+	values := make([][]byte, len(blocks))
+	for i, block := range blocks {
+		b, err := self.config.Marshal(block.Value)
+		if err != nil {
+			return err
+		}
+		values[i] = b
+	}
+
+	now := self.config.Clock.Now()
+	return db.Update(func(tx *bbolt.Tx) error {
+		for i, block := range blocks {
+			if err := self.put(tx, block, values[i], now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteMulti deletes the keys in one transaction.
+func (self *BoltCache) DeleteMulti(keys []string, ctx ...context.Context) error {
+	db, err := self.check(cacher.Context(ctx...))
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		for _, key := range keys {
+			if err := self.delete(tx, []byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TTL returns the remaining time of the key,-1 means never expire.
+func (self *BoltCache) TTL(key string, ctx ...context.Context) (time.Duration, error) {
+	db, err := self.check(cacher.Context(ctx...))
+	if err != nil {
+		return 0, err
+	}
+
+	now := self.config.Clock.Now()
+	var ttl time.Duration
+	err = db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(dataBucket).Get([]byte(key))
+		if v == nil {
+			return cacher.ErrCacheMiss
+		}
+
+		expireAt, _ := decodeValue(v)
+		switch {
+		case expireAt == 0:
+			ttl = -1
+		case expired(expireAt, now):
+			return cacher.ErrCacheMiss
+		default:
+			ttl = time.Duration(expireAt - now.UnixNano())
+		}
+		return nil
+	})
+	return ttl, err
+}
+
+// Expire resets the TTL of the key and moves its index entry,a negative TTL means never expire.
+func (self *BoltCache) Expire(key string, ttl time.Duration, ctx ...context.Context) error {
+	db, err := self.check(cacher.Context(ctx...))
+	if err != nil {
+		return err
+	}
+
+	now := self.config.Clock.Now()
+	return db.Update(func(tx *bbolt.Tx) error {
+		data, index := tx.Bucket(dataBucket), tx.Bucket(ttlBucket)
+		k := []byte(key)
+		v := data.Get(k)
+		if v == nil {
+			return cacher.ErrCacheMiss
+		}
+
+		expireAt, value := decodeValue(v)
+		if expired(expireAt, now) {
+			return cacher.ErrCacheMiss
+		}
+
+		if expireAt != 0 {
+			if err := index.Delete(ttlKey(expireAt, k)); err != nil {
+				return err
+			}
+		}
+
+		expireAt = 0
+		if ttl >= 0 {
+			expireAt = now.Add(ttl).UnixNano()
+			if err := index.Put(ttlKey(expireAt, k), nil); err != nil {
+				return err
+			}
+		}
+		return data.Put(k, encodeValue(expireAt, bytes.Clone(value)))
+	})
+}
+
+// vaccuum sweeps the expired keys every interval until exit is closed by Init or Close,
+// the clock and the interval are passed in since Init rewrites the config.
+func (self *BoltCache) vaccuum(exit chan struct{}, clock cacher.Clock, interval time.Duration) {
+	for {
+		select {
+		case <-clock.After(interval):
+		case <-exit:
+			return
+		}
+
+		if !self.config.Active {
+			continue
+		}
+
+		start := time.Now()
+		n, err := self.sweep()
+		if err != nil {
+			self.config.Logger.Error("cache: bolt sweep failed", "error", err)
+		}
+		self.config.Logger.Debug("cache: bolt sweep", "expired", n, "duration", time.Since(start))
+	}
+}
+
+// sweep walks the ttl index from the earliest expiry and deletes the keys which are due.
+func (self *BoltCache) sweep() (int, error) {
+	db, err := self.check(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for {
+		now := self.config.Clock.Now().UnixNano()
+		n := 0
+		err = db.Update(func(tx *bbolt.Tx) error {
+			data := tx.Bucket(dataBucket)
+			cur := tx.Bucket(ttlBucket).Cursor()
+			for k, _ := cur.First(); k != nil && n < sweepBatch; k, _ = cur.First() {
+				expireAt, key := decodeValue(k)
+				if expireAt > now {
+					break
+				}
+
+				// 索引可能比数据旧
+				if v := data.Get(key); v != nil {
+					if at, _ := decodeValue(v); at == expireAt {
+						if err := data.Delete(key); err != nil {
+							return err
+						}
+					}
+				}
+
+				if err := cur.Delete(); err != nil {
+					return err
+				}
+				n++
+			}
+			return nil
+		})
+
+		total += n
+		if err != nil || n < sweepBatch {
+			return total, err
+		}
+	}
+}
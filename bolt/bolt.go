@@ -0,0 +1,395 @@
+// Package bolt keeps the caches in an embedded bbolt database,
+// for caches which must survive restarts but do not fit in memory.
+//
+// the values are in the "data" bucket prefixed by their expiry,
+// and the "ttl" bucket indexes the keys by expiry so a sweep only visits the expired ones.
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/internal/codec"
+	bbolt "go.etcd.io/bbolt"
+)
+
+var (
+	dataBucket = []byte("data")
+	ttlBucket  = []byte("ttl")
+
+	errClosed = errors.New("cache: bolt is closed")
+)
+
+// the registry defers the opening to the first use,so Init sets the path before
+// and no database is opened in the temp directory.
+var Bolt = cacher.Register("Bolt", func() cacher.ICacher {
+	return newCache()
+})
+
+type BoltCache struct {
+	sync.RWMutex
+	config *Config
+	db     *bbolt.DB
+	err    error         // of opening the database
+	exit   chan struct{} // closed to stop the sweeps of the current database
+	closed bool
+}
+
+// New opens the database,an error is returned by the operations.
+func New(opts ...cacher.Option) *BoltCache {
+	c := newCache(opts...)
+	c.open()
+	return c
+}
+
+// newCache returns the cacher without opening the database,check opens it on the first use.
+func newCache(opts ...cacher.Option) *BoltCache {
+	cfg := &Config{
+		Active:   true,
+		Path:     filepath.Join(os.TempDir(), "cacher.db"),
+		Interval: cacher.INTERVAL_TIME * time.Second,
+	}
+	cfg.Init(opts...)
+
+	if cfg.Logger == nil {
+		cfg.Logger = cacher.DefaultLogger()
+	}
+
+	if cfg.Clock == nil {
+		cfg.Clock = cacher.SystemClock
+	}
+
+	if cfg.Marshal == nil || cfg.Unmarshal == nil {
+		cfg.Marshal, cfg.Unmarshal = codec.GobMarshal, codec.GobUnmarshal
+	}
+
+	return &BoltCache{config: cfg}
+}
+
+// open closes the previous database and opens the one of the config,
+// the lock must be held or not shared yet.
+func (self *BoltCache) open() {
+	self.shut()
+
+	cfg := self.config
+	self.db, self.err = bbolt.Open(cfg.Path, 0o644, &bbolt.Options{Timeout: time.Second, NoSync: cfg.NoSync})
+	if self.err == nil {
+		self.err = self.db.Update(func(tx *bbolt.Tx) error {
+			return createBuckets(tx)
+		})
+	}
+
+	if self.err != nil {
+		cfg.Logger.Error("cache: open bolt failed", "path", cfg.Path, "error", self.err)
+		return
+	}
+
+	if cfg.Interval > 0 {
+		self.exit = make(chan struct{})
+		go self.vaccuum(self.exit, cfg.Clock, cfg.Interval)
+	}
+}
+
+// shut stops the sweeps and closes the database,bbolt waits for the open transactions.
+func (self *BoltCache) shut() error {
+	if self.exit != nil {
+		close(self.exit)
+		self.exit = nil
+	}
+
+	var err error
+	if self.db != nil {
+		err = self.db.Close()
+		self.db = nil
+	}
+	return err
+}
+
+func createBuckets(tx *bbolt.Tx) error {
+	if _, err := tx.CreateBucketIfNotExists(dataBucket); err != nil {
+		return err
+	}
+	_, err := tx.CreateBucketIfNotExists(ttlBucket)
+	return err
+}
+
+// the value in the data bucket is the expiry in unix nanoseconds followed by the encoded value,
+// 0 means never expire.
+func encodeValue(expireAt int64, value []byte) []byte {
+	b := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(b, uint64(expireAt))
+	return append(b, value...)
+}
+
+func decodeValue(b []byte) (int64, []byte) {
+	if len(b) < 8 {
+		return 0, nil
+	}
+	return int64(binary.BigEndian.Uint64(b)), b[8:]
+}
+
+// ttlKey sorts by expiry first so the sweep stops at the first key which is alive.
+func ttlKey(expireAt int64, key []byte) []byte {
+	b := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(b, uint64(expireAt))
+	return append(b, key...)
+}
+
+func expired(expireAt int64, now time.Time) bool {
+	return expireAt != 0 && expireAt <= now.UnixNano()
+}
+
+// Init applies the options and reopens the database if it is open,
+// a cacher created by cacher.New("bolt") opens the path given by WithPath on the first use.
+func (self *BoltCache) Init(opts ...cacher.Option) {
+	self.Lock()
+	defer self.Unlock()
+
+	self.config.Init(opts...)
+	if !self.closed && !self.pending() {
+		self.open()
+	}
+}
+
+// pending reports the database is not opened yet,the lock must be held.
+func (self *BoltCache) pending() bool {
+	return self.db == nil && self.err == nil
+}
+
+func (self *BoltCache) String() string {
+	return "bolt"
+}
+
+func (self *BoltCache) Active(on ...bool) bool {
+	if len(on) > 0 {
+		self.config.Active = on[0]
+	}
+
+	return self.config.Active
+}
+
+// check returns the current database.
+func (self *BoltCache) check(ctx context.Context) (*bbolt.DB, error) {
+	self.RLock()
+	db, err := self.db, self.err
+	self.RUnlock()
+
+	if db == nil && err == nil {
+		self.Lock()
+		if self.pending() {
+			self.open()
+		}
+		db, err = self.db, self.err
+		self.Unlock()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return db, ctx.Err()
+}
+
+func (self *BoltCache) Get(key string, ctx ...context.Context) (any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	db, err := self.check(cacher.Context(ctx...))
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	err = db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(dataBucket).Get([]byte(key))
+		if v == nil {
+			return cacher.ErrCacheMiss
+		}
+
+		expireAt, value := decodeValue(v)
+		if expired(expireAt, self.config.Clock.Now()) {
+			return cacher.ErrCacheMiss // 由清扫删除
+		}
+
+		b = bytes.Clone(value) // 只在事务内有效
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	if err := self.config.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// put writes the key and moves its ttl index entry,a write skipped by SetOnlyNew
+// or SetOnlyExist keeps the old entry so the sweep still finds the old row.
+func (self *BoltCache) put(tx *bbolt.Tx, block *cacher.CacheBlock, value []byte, now time.Time) error {
+	data, index := tx.Bucket(dataBucket), tx.Bucket(ttlBucket)
+	key := []byte(block.Key)
+
+	var oldExpireAt int64
+	old := data.Get(key)
+	if old != nil {
+		oldExpireAt, _ = decodeValue(old)
+	}
+
+	exists := old != nil && !expired(oldExpireAt, now)
+	if (exists && block.SetOnlyNew) || (!exists && block.SetOnlyExist) {
+		return nil
+	}
+
+	if oldExpireAt != 0 {
+		if err := index.Delete(ttlKey(oldExpireAt, key)); err != nil {
+			return err
+		}
+	}
+
+	var expireAt int64
+	if ttl := block.Ttl(); ttl > 0 {
+		expireAt = now.Add(ttl).UnixNano()
+		if err := index.Put(ttlKey(expireAt, key), nil); err != nil {
+			return err
+		}
+	}
+	return data.Put(key, encodeValue(expireAt, value))
+}
+
+// Set writes the block,concurrent Sets are committed in one transaction by bbolt.Batch.
+func (self *BoltCache) Set(block *cacher.CacheBlock) error {
+	if !self.config.Active {
+		return nil
+	}
+
+	db, err := self.check(block.Context())
+	if err != nil {
+		return err
+	}
+
+	value, err := self.config.Marshal(block.Value)
+	if err != nil {
+		return err
+	}
+
+	now := self.config.Clock.Now()
+	return db.Batch(func(tx *bbolt.Tx) error {
+		return self.put(tx, block, value, now)
+	})
+}
+
+func (self *BoltCache) Exists(key string, ctx ...context.Context) bool {
+	_, err := self.Get(key, ctx...)
+	return err == nil
+}
+
+func (self *BoltCache) delete(tx *bbolt.Tx, key []byte) error {
+	data := tx.Bucket(dataBucket)
+	old := data.Get(key)
+	if old == nil {
+		return nil
+	}
+
+	if expireAt, _ := decodeValue(old); expireAt != 0 {
+		if err := tx.Bucket(ttlBucket).Delete(ttlKey(expireAt, key)); err != nil {
+			return err
+		}
+	}
+	return data.Delete(key)
+}
+
+// Delete removes the key,deleting a missing key is not an error.
+func (self *BoltCache) Delete(key string, ctx ...context.Context) error {
+	db, err := self.check(cacher.Context(ctx...))
+	if err != nil {
+		return err
+	}
+
+	return db.Batch(func(tx *bbolt.Tx) error {
+		return self.delete(tx, []byte(key))
+	})
+}
+
+// KeysPrefix returns the alive keys with the prefix in order by seeking the B-tree.
+func (self *BoltCache) KeysPrefix(prefix string, ctx ...context.Context) ([]string, error) {
+	if !self.config.Active {
+		return nil, nil
+	}
+
+	c := cacher.Context(ctx...)
+	db, err := self.check(c)
+	if err != nil {
+		return nil, err
+	}
+
+	now := self.config.Clock.Now()
+	var keys []string
+	err = db.View(func(tx *bbolt.Tx) error {
+		cur := tx.Bucket(dataBucket).Cursor()
+		p := []byte(prefix)
+		for k, v := cur.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = cur.Next() {
+			if len(keys)%1024 == 0 {
+				if err := c.Err(); err != nil {
+					return err
+				}
+			}
+
+			if expireAt, _ := decodeValue(v); !expired(expireAt, now) {
+				keys = append(keys, string(k))
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (self *BoltCache) Keys(ctx ...context.Context) []string {
+	keys, _ := self.KeysPrefix("", ctx...)
+	return keys
+}
+
+func (self *BoltCache) Len() int {
+	return len(self.Keys())
+}
+
+// Clear drops and recreates the buckets.
+func (self *BoltCache) Clear() error {
+	db, err := self.check(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{dataBucket, ttlBucket} {
+			if err := tx.DeleteBucket(name); err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+				return err
+			}
+		}
+		return createBuckets(tx)
+	})
+}
+
+// Close stops the sweeps and closes the database,the data is kept in the file.
+func (self *BoltCache) Close() error {
+	self.Lock()
+	defer self.Unlock()
+
+	if self.closed {
+		return nil
+	}
+
+	self.closed = true
+	if self.err == nil {
+		self.err = errClosed
+	}
+	return self.shut()
+}
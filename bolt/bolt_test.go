@@ -0,0 +1,147 @@
+package bolt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/cachertest"
+	bbolt "go.etcd.io/bbolt"
+)
+
+func open(t *testing.T, path string, clock cacher.Clock) *BoltCache {
+	chr := New(WithPath(path), WithInterval(0), cacher.WithClock(clock))
+	if chr.err != nil {
+		t.Fatal(chr.err)
+	}
+	return chr
+}
+
+func TestConformance(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	cachertest.Run(t, func(t *testing.T) cacher.ICacher {
+		return open(t, filepath.Join(t.TempDir(), "cache.db"), clock)
+	}, cachertest.WithSleep(clock.Advance))
+}
+
+func TestSweep(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	chr := open(t, filepath.Join(t.TempDir(), "cache.db"), clock)
+	defer chr.Close()
+
+	for i := 0; i < 10; i++ {
+		chr.Set(&cacher.CacheBlock{Key: fmt.Sprintf("short%d", i), Value: i, TTL: time.Second})
+	}
+	chr.Set(&cacher.CacheBlock{Key: "long", Value: "v", TTL: time.Hour})
+	chr.Set(&cacher.CacheBlock{Key: "forever", Value: "v", TTL: -1})
+	// 重设TTL后旧的索引不能删除数据
+	chr.Set(&cacher.CacheBlock{Key: "short0", Value: 0, TTL: time.Hour})
+
+	clock.Advance(2 * time.Second)
+	n, err := chr.sweep()
+	if err != nil || n != 9 {
+		t.Fatalf("swept %d %v", n, err)
+	}
+
+	chr.db.View(func(tx *bbolt.Tx) error {
+		if n := tx.Bucket(dataBucket).Stats().KeyN; n != 3 {
+			t.Errorf("%d keys left in data", n)
+		}
+		if n := tx.Bucket(ttlBucket).Stats().KeyN; n != 2 {
+			t.Errorf("%d keys left in the ttl index", n)
+		}
+		return nil
+	})
+
+	if ttl, _ := chr.TTL("forever"); ttl != -1 {
+		t.Fatalf("ttl of forever %v", ttl)
+	}
+}
+
+func TestSweepSkippedWrite(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	chr := open(t, filepath.Join(t.TempDir(), "cache.db"), clock)
+	defer chr.Close()
+
+	chr.Set(&cacher.CacheBlock{Key: "live", Value: "v", TTL: time.Second})
+	chr.Set(&cacher.CacheBlock{Key: "dead", Value: "v", TTL: time.Second})
+	clock.Advance(2 * time.Second)
+	chr.Set(&cacher.CacheBlock{Key: "live", Value: "v", TTL: time.Second})
+
+	// 跳过的写入不能删除旧的索引
+	chr.Set(&cacher.CacheBlock{Key: "live", Value: "new", SetOnlyNew: true})
+	chr.Set(&cacher.CacheBlock{Key: "dead", Value: "new", SetOnlyExist: true})
+
+	clock.Advance(2 * time.Second)
+	if n, err := chr.sweep(); err != nil || n != 2 {
+		t.Fatalf("swept %d %v", n, err)
+	}
+
+	chr.db.View(func(tx *bbolt.Tx) error {
+		if n := tx.Bucket(dataBucket).Stats().KeyN; n != 0 {
+			t.Errorf("%d expired rows leak", n)
+		}
+		return nil
+	})
+}
+
+func TestBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	chr := open(t, path, cacher.SystemClock)
+
+	chr.SetMulti(
+		&cacher.CacheBlock{Key: "user:1", Value: "a"},
+		&cacher.CacheBlock{Key: "user:2", Value: "b"},
+		&cacher.CacheBlock{Key: "order:1", Value: "c"},
+	)
+
+	keys, err := chr.KeysPrefix("user:")
+	if err != nil || fmt.Sprint(keys) != "[user:1 user:2]" {
+		t.Fatalf("keys with prefix %v %v", keys, err)
+	}
+
+	chr.DeleteMulti([]string{"user:1", "missing"})
+	chr.Close()
+
+	// 重启后数据仍在
+	chr = open(t, path, cacher.SystemClock)
+	defer chr.Close()
+	values, err := chr.GetMulti([]string{"user:1", "user:2", "order:1"})
+	if err != nil || len(values) != 2 || values["order:1"] != "c" {
+		t.Fatalf("get multi %v %v", values, err)
+	}
+
+	var _ cacher.IBatcher = chr
+	var _ cacher.ITTLer = chr
+}
+
+func TestRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	// 注册表不打开临时目录的数据库,第一次使用时打开WithPath的
+	chr, err := cacher.New("bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chr.Init(WithPath(path), WithInterval(0))
+	if err := chr.Set(&cacher.CacheBlock{Key: "key", Value: "value"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "cacher.db")); !os.IsNotExist(err) {
+		t.Fatalf("the default database is opened %v", err)
+	}
+
+	reopened := open(t, path, cacher.SystemClock)
+	defer reopened.Close()
+	if v, err := reopened.Get("key"); err != nil || v != "value" {
+		t.Fatalf("get from the path of Init %v %v", v, err)
+	}
+}
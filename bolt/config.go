@@ -0,0 +1,59 @@
+package bolt
+
+import (
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+type (
+	MarshalFunc   func(any) ([]byte, error)
+	UnmarshalFunc func([]byte, *any) error
+
+	Option func(*Config)
+
+	Config struct {
+		cacher.Config
+		Active    bool
+		Path      string        // the database file
+		Interval  time.Duration // interval of the expiry sweeps,0 disables them
+		NoSync    bool          `field:"no_sync"` // skip fsync on commit,faster but a crash may lose the recent writes
+		Marshal   MarshalFunc
+		Unmarshal UnmarshalFunc
+		Logger    cacher.Logger
+		Clock     cacher.Clock
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+// WithPath sets the database file.
+func WithPath(path string) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("path", path)
+	}
+}
+
+// WithInterval sets how often the expired keys are swept.
+func WithInterval(interval time.Duration) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("interval", interval)
+	}
+}
+
+// WithNoSync skips fsync on commit.
+func WithNoSync() cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("no_sync", true)
+	}
+}
+
+// WithCodec sets how the values are encoded.
+func WithCodec(marshal MarshalFunc, unmarshal UnmarshalFunc) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("marshal", marshal)
+		cfg.SetByField("unmarshal", unmarshal)
+	}
+}
@@ -0,0 +1,334 @@
+// Package breaker guards a remote cacher by a circuit breaker,so an unreachable
+// backend fails fast instead of making every call wait for its timeout.
+//
+//	chr := breaker.New(redisCache, breaker.WithFallback(memory.New()))
+//
+// the breaker opens once ErrorRate of at least MinRequests calls in the Window fail,
+// while open the calls go to the Fallback or,without one,Get misses and Set is dropped.
+// Delete and Clear return ErrOpen because the backend keeps the stale keys.
+// after OpenTimeout HalfOpenRequests trial calls pass,it closes if they all succeed
+// and opens again on the first failure.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+// buckets of the rolling window
+const buckets = 10
+
+var ErrOpen = errors.New("cache: circuit breaker is open")
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+type (
+	State int
+
+	// BreakerCache wraps a cacher by a circuit breaker.
+	BreakerCache struct {
+		cacher.ICacher
+		config *Config
+
+		mu       sync.Mutex
+		state    State
+		gen      uint64 // bumped by every transition,results of an older state are ignored
+		openedAt time.Time
+		window   [buckets]bucket
+		trials   int // trial calls let through in half open
+		passed   int // successful trial calls
+	}
+
+	bucket struct {
+		start    time.Time
+		requests int
+		failures int
+	}
+)
+
+func (self State) String() string {
+	switch self {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+func New(chr cacher.ICacher, opts ...cacher.Option) *BreakerCache {
+	cfg := &Config{
+		Window:           10 * time.Second,
+		MinRequests:      20,
+		ErrorRate:        0.5,
+		OpenTimeout:      5 * time.Second,
+		HalfOpenRequests: 1,
+	}
+	cfg.Init(opts...)
+
+	if cfg.Window < buckets {
+		cfg.Window = buckets
+	}
+	if cfg.MinRequests < 1 {
+		cfg.MinRequests = 1
+	}
+	if cfg.HalfOpenRequests < 1 {
+		cfg.HalfOpenRequests = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = isFailure
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = cacher.DefaultLogger()
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = cacher.SystemClock
+	}
+
+	return &BreakerCache{
+		ICacher: chr,
+		config:  cfg,
+	}
+}
+
+// isFailure counts every error except misses,inactive cachers and calls canceled by the caller.
+func isFailure(err error) bool {
+	return err != nil &&
+		!errors.Is(err, cacher.ErrCacheMiss) &&
+		!errors.Is(err, cacher.ErrInactive) &&
+		!errors.Is(err, context.Canceled)
+}
+
+func (self *BreakerCache) Init(opts ...cacher.Option) {
+	self.ICacher.Init(opts...)
+}
+
+// Unwrap returns the underlying cacher.
+func (self *BreakerCache) Unwrap() cacher.ICacher {
+	return self.ICacher
+}
+
+// State returns the current state,an open breaker whose timeout passed is reported half open.
+func (self *BreakerCache) State() State {
+	self.mu.Lock()
+	from, to := self.state, self.expire(self.config.Clock.Now())
+	self.mu.Unlock()
+
+	self.notify(from, to)
+	return to
+}
+
+// expire moves an open breaker to half open once the timeout passed,it returns the state.
+func (self *BreakerCache) expire(now time.Time) State {
+	if self.state == Open && now.Sub(self.openedAt) >= self.config.OpenTimeout {
+		self.transit(HalfOpen, now)
+	}
+	return self.state
+}
+
+// transit switches the state and resets the counters,it must be called with the lock held.
+func (self *BreakerCache) transit(to State, now time.Time) {
+	self.state = to
+	self.gen++
+	self.window = [buckets]bucket{}
+	self.trials, self.passed = 0, 0
+	if to == Open {
+		self.openedAt = now
+	}
+}
+
+func (self *BreakerCache) notify(from, to State) {
+	if from == to {
+		return
+	}
+
+	self.config.Logger.Info("cache: circuit breaker changed", "cacher", self.ICacher.String(), "from", from, "to", to)
+	if self.config.OnStateChange != nil {
+		self.config.OnStateChange(from, to)
+	}
+}
+
+// allow reports whether a call may pass to the backend and returns the generation it belongs to.
+func (self *BreakerCache) allow() (uint64, bool) {
+	self.mu.Lock()
+	from := self.state
+	to := self.expire(self.config.Clock.Now())
+	ok := true
+	switch to {
+	case Open:
+		ok = false
+	case HalfOpen:
+		ok = self.trials < self.config.HalfOpenRequests
+		if ok {
+			self.trials++
+		}
+	}
+	gen := self.gen
+	self.mu.Unlock()
+
+	self.notify(from, to)
+	return gen, ok
+}
+
+// done records the result of a call which passed at the generation.
+func (self *BreakerCache) done(gen uint64, err error) {
+	failed := self.config.IsFailure(err)
+	now := self.config.Clock.Now()
+
+	self.mu.Lock()
+	if gen != self.gen {
+		self.mu.Unlock()
+		return // 状态已变,结果作废
+	}
+
+	from := self.state
+	switch self.state {
+	case Closed:
+		b := self.current(now)
+		b.requests++
+		if failed {
+			b.failures++
+		}
+
+		if failed {
+			requests, failures := self.count(now)
+			if requests >= self.config.MinRequests && float64(failures) >= self.config.ErrorRate*float64(requests) {
+				self.transit(Open, now)
+			}
+		}
+
+	case HalfOpen:
+		if failed {
+			self.transit(Open, now)
+		} else if self.passed++; self.passed >= self.config.HalfOpenRequests {
+			self.transit(Closed, now)
+		}
+	}
+	to := self.state
+	self.mu.Unlock()
+
+	self.notify(from, to)
+}
+
+// current returns the bucket of the time,a bucket of an older round is reset.
+func (self *BreakerCache) current(now time.Time) *bucket {
+	width := self.config.Window / buckets
+	start := now.Truncate(width)
+	b := &self.window[int(start.UnixNano()/int64(width))%buckets]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	return b
+}
+
+// count sums the buckets within the window.
+func (self *BreakerCache) count(now time.Time) (requests, failures int) {
+	for _, b := range self.window {
+		if now.Sub(b.start) < self.config.Window {
+			requests += b.requests
+			failures += b.failures
+		}
+	}
+	return
+}
+
+func (self *BreakerCache) Get(key string, ctx ...context.Context) (any, error) {
+	gen, ok := self.allow()
+	if !ok {
+		if fb := self.config.Fallback; fb != nil {
+			return fb.Get(key, ctx...)
+		}
+		return nil, cacher.ErrCacheMiss
+	}
+
+	value, err := self.ICacher.Get(key, ctx...)
+	self.done(gen, err)
+	return value, err
+}
+
+// Set writes to the fallback while open,or drops the block without one.
+func (self *BreakerCache) Set(block *cacher.CacheBlock) error {
+	gen, ok := self.allow()
+	if !ok {
+		if fb := self.config.Fallback; fb != nil {
+			return fb.Set(block)
+		}
+		return nil
+	}
+
+	err := self.ICacher.Set(block)
+	self.done(gen, err)
+	return err
+}
+
+func (self *BreakerCache) Exists(key string, ctx ...context.Context) bool {
+	_, err := self.Get(key, ctx...)
+	return err == nil
+}
+
+// Delete deletes from the fallback while open but returns ErrOpen,
+// the key stays on the backend and may be read once the breaker closes.
+func (self *BreakerCache) Delete(key string, ctx ...context.Context) error {
+	gen, ok := self.allow()
+	if !ok {
+		if fb := self.config.Fallback; fb != nil {
+			fb.Delete(key, ctx...)
+		}
+		return ErrOpen
+	}
+
+	err := self.ICacher.Delete(key, ctx...)
+	self.done(gen, err)
+	return err
+}
+
+// Keys returns the keys of the fallback while open.
+func (self *BreakerCache) Keys(ctx ...context.Context) []string {
+	if self.State() == Open {
+		if fb := self.config.Fallback; fb != nil {
+			return fb.Keys(ctx...)
+		}
+		return nil
+	}
+	return self.ICacher.Keys(ctx...)
+}
+
+func (self *BreakerCache) Len() int {
+	if self.State() == Open {
+		if fb := self.config.Fallback; fb != nil {
+			return fb.Len()
+		}
+		return 0
+	}
+	return self.ICacher.Len()
+}
+
+// Clear clears the fallback while open but returns ErrOpen.
+func (self *BreakerCache) Clear() error {
+	gen, ok := self.allow()
+	if !ok {
+		if fb := self.config.Fallback; fb != nil {
+			fb.Clear()
+		}
+		return ErrOpen
+	}
+
+	err := self.ICacher.Clear()
+	self.done(gen, err)
+	return err
+}
+
+// Close closes the underlying cacher,the fallback is owned by the caller.
+func (self *BreakerCache) Close() error {
+	return self.ICacher.Close()
+}
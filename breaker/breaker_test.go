@@ -0,0 +1,183 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/cachertest"
+	"github.com/volts-dev/cacher/memory"
+)
+
+var errDown = errors.New("backend is down")
+
+// flaky fails every call while down.
+type flaky struct {
+	cacher.ICacher
+	down  atomic.Bool
+	calls atomic.Int64
+}
+
+func (self *flaky) Get(key string, ctx ...context.Context) (any, error) {
+	self.calls.Add(1)
+	if self.down.Load() {
+		return nil, errDown
+	}
+	return self.ICacher.Get(key, ctx...)
+}
+
+func (self *flaky) Set(block *cacher.CacheBlock) error {
+	self.calls.Add(1)
+	if self.down.Load() {
+		return errDown
+	}
+	return self.ICacher.Set(block)
+}
+
+func (self *flaky) Delete(key string, ctx ...context.Context) error {
+	self.calls.Add(1)
+	if self.down.Load() {
+		return errDown
+	}
+	return self.ICacher.Delete(key, ctx...)
+}
+
+func TestConformance(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	cachertest.Run(t, func(t *testing.T) cacher.ICacher {
+		return New(memory.New(cacher.WithClock(clock)))
+	}, cachertest.WithSleep(clock.Advance))
+}
+
+func TestTransitions(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	backend := &flaky{ICacher: memory.New()}
+	var changes []State
+	chr := New(backend,
+		WithThreshold(0.5, 4, 10*time.Second),
+		WithOpenTimeout(5*time.Second, 2),
+		WithOnStateChange(func(from, to State) { changes = append(changes, to) }),
+		cacher.WithClock(clock),
+	)
+
+	chr.Set(&cacher.CacheBlock{Key: "key", Value: "value"})
+	// 未命中不算失败
+	for i := 0; i < 10; i++ {
+		chr.Get("missing")
+	}
+	if s := chr.State(); s != Closed {
+		t.Fatalf("%s after misses", s)
+	}
+
+	backend.down.Store(true)
+	for i := 0; i < 11; i++ {
+		chr.Get("key")
+	}
+	if s := chr.State(); s != Open {
+		t.Fatalf("%s after failures", s)
+	}
+
+	// 打开后不再访问后端
+	calls := backend.calls.Load()
+	if _, err := chr.Get("key"); err != cacher.ErrCacheMiss {
+		t.Fatalf("get while open %v", err)
+	}
+	if err := chr.Set(&cacher.CacheBlock{Key: "key", Value: "value"}); err != nil {
+		t.Fatalf("set while open %v", err)
+	}
+	if err := chr.Delete("key"); err != ErrOpen {
+		t.Fatalf("delete while open %v", err)
+	}
+	if n := backend.calls.Load(); n != calls {
+		t.Fatalf("%d calls to the backend while open", n-calls)
+	}
+
+	// 半开时一次失败即重新打开
+	clock.Advance(5 * time.Second)
+	if _, err := chr.Get("key"); err != errDown {
+		t.Fatalf("trial get %v", err)
+	}
+	if s := chr.State(); s != Open {
+		t.Fatalf("%s after a failed trial", s)
+	}
+
+	clock.Advance(5 * time.Second)
+	backend.down.Store(false)
+	for i := 0; i < 2; i++ {
+		if v, err := chr.Get("key"); err != nil || v != "value" {
+			t.Fatalf("trial get %v %v", v, err)
+		}
+	}
+	if s := chr.State(); s != Closed {
+		t.Fatalf("%s after the trials", s)
+	}
+
+	want := []State{Open, HalfOpen, Open, HalfOpen, Closed}
+	if len(changes) != len(want) {
+		t.Fatalf("changes %v", changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("changes %v", changes)
+		}
+	}
+}
+
+func TestWindow(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	backend := &flaky{ICacher: memory.New()}
+	chr := New(backend, WithThreshold(0.5, 4, 10*time.Second), cacher.WithClock(clock))
+
+	backend.down.Store(true)
+	for i := 0; i < 3; i++ {
+		chr.Get("key")
+	}
+
+	// 窗口外的失败不计入
+	clock.Advance(11 * time.Second)
+	chr.Get("key")
+	if s := chr.State(); s != Closed {
+		t.Fatalf("%s with failures out of the window", s)
+	}
+
+	for i := 0; i < 3; i++ {
+		chr.Get("key")
+	}
+	if s := chr.State(); s != Open {
+		t.Fatalf("%s with failures in the window", s)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	backend := &flaky{ICacher: memory.New()}
+	fallback := memory.New()
+	chr := New(backend,
+		WithThreshold(1, 1, time.Second),
+		WithFallback(fallback),
+		cacher.WithClock(clock),
+	)
+
+	backend.down.Store(true)
+	chr.Get("key")
+	if s := chr.State(); s != Open {
+		t.Fatalf("%s after a failure", s)
+	}
+
+	if err := chr.Set(&cacher.CacheBlock{Key: "key", Value: "value"}); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := chr.Get("key"); err != nil || v != "value" {
+		t.Fatalf("get from the fallback %v %v", v, err)
+	}
+	if keys := chr.Keys(); len(keys) != 1 || chr.Len() != 1 {
+		t.Fatalf("keys of the fallback %v", keys)
+	}
+
+	if err := chr.Delete("key"); err != ErrOpen || fallback.Exists("key") {
+		t.Fatalf("delete while open %v", err)
+	}
+}
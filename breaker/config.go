@@ -0,0 +1,67 @@
+package breaker
+
+import (
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+type (
+	Option func(*Config)
+
+	Config struct {
+		cacher.Config
+		Fallback         cacher.ICacher       // serves the operations while the breaker is open,nil means a miss
+		Window           time.Duration        // the error rate is measured over the window
+		MinRequests      int                  `field:"min_requests"`       // requests in the window before it may open
+		ErrorRate        float64              `field:"error_rate"`         // opens once the failed share of the window reaches it
+		OpenTimeout      time.Duration        `field:"open_timeout"`       // stays open before a trial
+		HalfOpenRequests int                  `field:"half_open_requests"` // successful trials to close
+		OnStateChange    func(from, to State) `field:"on_state_change"`
+		IsFailure        func(err error) bool `field:"is_failure"` // default counts every error except misses and canceled calls
+		Logger           cacher.Logger
+		Clock            cacher.Clock
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+// WithFallback serves the operations by the cacher while the breaker is open.
+func WithFallback(chr cacher.ICacher) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("fallback", chr)
+	}
+}
+
+// WithThreshold opens the breaker once rate of at least min requests in the window fail.
+func WithThreshold(rate float64, min int, window time.Duration) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("error_rate", rate)
+		cfg.SetByField("min_requests", min)
+		cfg.SetByField("window", window)
+	}
+}
+
+// WithOpenTimeout sets how long the breaker stays open before it lets n trial requests through.
+func WithOpenTimeout(timeout time.Duration, n int) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("open_timeout", timeout)
+		cfg.SetByField("half_open_requests", n)
+	}
+}
+
+// WithOnStateChange reports the transitions of the breaker,fn must not block.
+func WithOnStateChange(fn func(from, to State)) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("on_state_change", fn)
+	}
+}
+
+// WithIsFailure decides which errors count as failures.
+func WithIsFailure(fn func(err error) bool) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("is_failure", fn)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"hash/crc32"
 	"strings"
+	"time"
 )
 
 const (
@@ -62,6 +63,54 @@ type (
 		Clear() error // clear all cache.
 		Close() error
 	}
+
+	// ICounter is implemented by cachers which support counters.
+	ICounter interface {
+		Incr(key string) error // increase cached int value by key, as a counter.
+		Decr(key string) error // decrease cached int value by key, as a counter.
+	}
+
+	// IBatcher is implemented by cachers which support batch operations.
+	IBatcher interface {
+		GetMulti(keys []string, ctx ...context.Context) (map[string]any, error) // missing keys are omitted.
+		SetMulti(blocks ...*CacheBlock) error
+		DeleteMulti(keys []string, ctx ...context.Context) error
+	}
+
+	// IVersioner is implemented by cachers which support optimistic concurrency.
+	IVersioner interface {
+		GetWithVersion(key string, ctx ...context.Context) (value any, version uint64, err error)
+		// CompareAndSwap sets the block only if the version of the key is still expected,
+		// otherwise it returns ErrVersionMismatch. expected 0 means the key must not exist.
+		CompareAndSwap(ctx context.Context, key string, expected uint64, block *CacheBlock) error
+	}
+
+	// UpdateFunc computes the new value from the old one,
+	// del deletes the key instead of setting the value.
+	UpdateFunc func(old any, exists bool) (value any, ttl time.Duration, del bool)
+
+	// IUpdater is implemented by cachers which can apply a function to a key atomically.
+	IUpdater interface {
+		Update(ctx context.Context, key string, fn UpdateFunc) error
+	}
+
+	// ITTLer is implemented by cachers which can report and change the expiration of a key.
+	ITTLer interface {
+		TTL(key string, ctx ...context.Context) (time.Duration, error) // negative means never expire.
+		Expire(key string, ttl time.Duration, ctx ...context.Context) error
+	}
+
+	// IHashCacher is implemented by cachers which store a value as a hash of fields,
+	// so a field is read or written without the others. see HashFields and ScanHash for structs.
+	// a key holding a plain value returns ErrWrongType.
+	IHashCacher interface {
+		HGet(key, field string, ctx ...context.Context) (any, error) // ErrCacheMiss if the key or field is missing.
+		// HSet sets the fields and resets the TTL of the whole hash,
+		// the TTL follows CacheBlock.TTL: 0 is the default and negative never expires.
+		HSet(key string, fields map[string]any, ttl time.Duration, ctx ...context.Context) error
+		HDel(key string, fields []string, ctx ...context.Context) error     // the key is deleted with its last field.
+		HGetAll(key string, ctx ...context.Context) (map[string]any, error) // ErrCacheMiss if the key is missing.
+	}
 )
 
 var adapters = make(map[CacherType]func() ICacher)
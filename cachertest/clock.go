@@ -0,0 +1,78 @@
+package cachertest
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// Clock is a fake cacher.Clock which only moves by Advance.
+	Clock struct {
+		sync.Mutex
+		cond    *sync.Cond
+		now     time.Time
+		waiters []*waiter
+	}
+
+	waiter struct {
+		until time.Time
+		ch    chan time.Time
+	}
+)
+
+// NewClock returns a fake clock starting at the given time.
+func NewClock(start time.Time) *Clock {
+	c := &Clock{now: start}
+	c.cond = sync.NewCond(&c.Mutex)
+	return c
+}
+
+func (self *Clock) Now() time.Time {
+	self.Lock()
+	defer self.Unlock()
+	return self.now
+}
+
+func (self *Clock) After(d time.Duration) <-chan time.Time {
+	self.Lock()
+	defer self.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- self.now
+		return ch
+	}
+
+	self.waiters = append(self.waiters, &waiter{until: self.now.Add(d), ch: ch})
+	self.cond.Broadcast()
+	return ch
+}
+
+// Advance moves the clock forward and fires the timers which are due.
+func (self *Clock) Advance(d time.Duration) {
+	self.Lock()
+	defer self.Unlock()
+
+	self.now = self.now.Add(d)
+	waiters := self.waiters[:0]
+	for _, w := range self.waiters {
+		if self.now.Before(w.until) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- self.now
+	}
+	self.waiters = waiters
+	self.cond.Broadcast()
+}
+
+// BlockUntil waits until n goroutines are waiting on After,
+// e.g. the gc of a cacher is idle again after an Advance.
+func (self *Clock) BlockUntil(n int) {
+	self.Lock()
+	defer self.Unlock()
+
+	for len(self.waiters) < n {
+		self.cond.Wait()
+	}
+}
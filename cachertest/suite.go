@@ -0,0 +1,546 @@
+// Package cachertest provides a conformance suite which every ICacher adapter should pass.
+//
+// usage:
+//
+//	func TestConformance(t *testing.T) {
+//		cachertest.Run(t, func(t *testing.T) cacher.ICacher {
+//			return mycache.New()
+//		})
+//	}
+package cachertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+type (
+	// Factory returns a new and empty cacher for every test.
+	Factory func(t *testing.T) cacher.ICacher
+
+	Option func(*Suite)
+
+	Suite struct {
+		New   Factory
+		Sleep func(time.Duration) // lets the time pass,default is time.Sleep
+		Skip  map[string]bool     // names of the tests to skip
+	}
+)
+
+// WithSleep replaces time.Sleep,e.g. to advance a fake clock.
+func WithSleep(sleep func(time.Duration)) Option {
+	return func(s *Suite) {
+		s.Sleep = sleep
+	}
+}
+
+// WithSkip skips the named tests which the adapter does not support.
+func WithSkip(names ...string) Option {
+	return func(s *Suite) {
+		for _, name := range names {
+			s.Skip[name] = true
+		}
+	}
+}
+
+// Run runs the conformance suite against the cachers created by the factory.
+func Run(t *testing.T, factory Factory, opts ...Option) {
+	s := &Suite{
+		New:   factory,
+		Sleep: time.Sleep,
+		Skip:  make(map[string]bool),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.Run(t)
+}
+
+func (self *Suite) Run(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, cacher.ICacher)
+	}{
+		{"Miss", self.testMiss},
+		{"SetGet", self.testSetGet},
+		{"Overwrite", self.testOverwrite},
+		{"TTL", self.testTTL},
+		{"SetOnlyNew", self.testSetOnlyNew},
+		{"SetOnlyExist", self.testSetOnlyExist},
+		{"Delete", self.testDelete},
+		{"DeleteMissing", self.testDeleteMissing},
+		{"KeysLen", self.testKeysLen},
+		{"Clear", self.testClear},
+		{"Concurrency", self.testConcurrency},
+		{"SameKey", self.testSameKey},
+		{"Active", self.testActive},
+		{"Close", self.testClose},
+		{"CompareAndSwap", self.testCompareAndSwap},
+		{"Update", self.testUpdate},
+		{"Hash", self.testHash},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if self.Skip[test.name] {
+				t.Skip("skipped by the adapter")
+			}
+
+			chr := self.New(t)
+			defer chr.Close()
+			test.fn(t, chr)
+		})
+	}
+}
+
+func set(t *testing.T, chr cacher.ICacher, block *cacher.CacheBlock) {
+	t.Helper()
+	if err := chr.Set(block); err != nil {
+		t.Fatalf("set %s: %v", block.Key, err)
+	}
+}
+
+func expect(t *testing.T, chr cacher.ICacher, key string, want any) {
+	t.Helper()
+	got, err := chr.Get(key)
+	if err != nil {
+		t.Fatalf("get %s: %v", key, err)
+	}
+	if got != want {
+		t.Fatalf("get %s: got %v(%T) want %v(%T)", key, got, got, want, want)
+	}
+	if !chr.Exists(key) {
+		t.Fatalf("%s should exist", key)
+	}
+}
+
+func expectMiss(t *testing.T, chr cacher.ICacher, key string) {
+	t.Helper()
+	if _, err := chr.Get(key); !errors.Is(err, cacher.ErrCacheMiss) {
+		t.Fatalf("get %s: expected ErrCacheMiss, got %v", key, err)
+	}
+	if chr.Exists(key) {
+		t.Fatalf("%s should not exist", key)
+	}
+}
+
+func (self *Suite) testMiss(t *testing.T, chr cacher.ICacher) {
+	expectMiss(t, chr, "missing")
+}
+
+func (self *Suite) testSetGet(t *testing.T, chr cacher.ICacher) {
+	set(t, chr, &cacher.CacheBlock{Key: "key", Value: "value"})
+	expect(t, chr, "key", "value")
+}
+
+func (self *Suite) testOverwrite(t *testing.T, chr cacher.ICacher) {
+	set(t, chr, &cacher.CacheBlock{Key: "key", Value: "old"})
+	set(t, chr, &cacher.CacheBlock{Key: "key", Value: "new"})
+	expect(t, chr, "key", "new")
+
+	if n := chr.Len(); n != 1 {
+		t.Fatalf("overwrite must not add a key, len %d", n)
+	}
+}
+
+func (self *Suite) testTTL(t *testing.T, chr cacher.ICacher) {
+	set(t, chr, &cacher.CacheBlock{Key: "short", Value: "value", TTL: time.Second})
+	set(t, chr, &cacher.CacheBlock{Key: "long", Value: "value", TTL: time.Hour})
+
+	self.Sleep(1500 * time.Millisecond)
+
+	expectMiss(t, chr, "short")
+	expect(t, chr, "long", "value")
+}
+
+func (self *Suite) testSetOnlyNew(t *testing.T, chr cacher.ICacher) {
+	set(t, chr, &cacher.CacheBlock{Key: "key", Value: "old"})
+	set(t, chr, &cacher.CacheBlock{Key: "key", Value: "new", SetOnlyNew: true})
+	expect(t, chr, "key", "old")
+
+	set(t, chr, &cacher.CacheBlock{Key: "fresh", Value: "new", SetOnlyNew: true})
+	expect(t, chr, "fresh", "new")
+}
+
+func (self *Suite) testSetOnlyExist(t *testing.T, chr cacher.ICacher) {
+	set(t, chr, &cacher.CacheBlock{Key: "missing", Value: "new", SetOnlyExist: true})
+	expectMiss(t, chr, "missing")
+
+	set(t, chr, &cacher.CacheBlock{Key: "key", Value: "old"})
+	set(t, chr, &cacher.CacheBlock{Key: "key", Value: "new", SetOnlyExist: true})
+	expect(t, chr, "key", "new")
+}
+
+func (self *Suite) testDelete(t *testing.T, chr cacher.ICacher) {
+	set(t, chr, &cacher.CacheBlock{Key: "key", Value: "value"})
+	if err := chr.Delete("key"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	expectMiss(t, chr, "key")
+
+	if n := chr.Len(); n != 0 {
+		t.Fatalf("len %d after delete", n)
+	}
+}
+
+// deleting a missing key is not an error.
+func (self *Suite) testDeleteMissing(t *testing.T, chr cacher.ICacher) {
+	if err := chr.Delete("missing"); err != nil {
+		t.Fatalf("delete missing key: %v", err)
+	}
+}
+
+func (self *Suite) testKeysLen(t *testing.T, chr cacher.ICacher) {
+	want := make([]string, 10)
+	for i := range want {
+		want[i] = fmt.Sprintf("key%d", i)
+		set(t, chr, &cacher.CacheBlock{Key: want[i], Value: "value"})
+	}
+
+	keys := chr.Keys()
+	sort.Strings(keys)
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Fatalf("keys %v want %v", keys, want)
+	}
+
+	if n := chr.Len(); n != len(want) {
+		t.Fatalf("len %d want %d", n, len(want))
+	}
+}
+
+func (self *Suite) testClear(t *testing.T, chr cacher.ICacher) {
+	set(t, chr, &cacher.CacheBlock{Key: "a", Value: "value"})
+	set(t, chr, &cacher.CacheBlock{Key: "b", Value: "value"})
+
+	if err := chr.Clear(); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+
+	expectMiss(t, chr, "a")
+	if n := chr.Len(); n != 0 {
+		t.Fatalf("len %d after clear", n)
+	}
+}
+
+func (self *Suite) testConcurrency(t *testing.T, chr cacher.ICacher) {
+	const workers, count = 8, 100
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				key := fmt.Sprintf("%d-%d", w, i)
+				value := fmt.Sprintf("value%d", i)
+				if err := chr.Set(&cacher.CacheBlock{Key: key, Value: value}); err != nil {
+					errs <- err
+					return
+				}
+				if got, err := chr.Get(key); err != nil || got != value {
+					errs <- fmt.Errorf("get %s: %v %v", key, got, err)
+					return
+				}
+				if i%2 == 1 {
+					if err := chr.Delete(key); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if n := chr.Len(); n != workers*count/2 {
+		t.Fatalf("len %d want %d", n, workers*count/2)
+	}
+}
+
+// testSameKey reads and writes one key from all workers,it finds the races under -race.
+func (self *Suite) testSameKey(t *testing.T, chr cacher.ICacher) {
+	const workers, count = 8, 100
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				var err error
+				switch i % 4 {
+				case 0:
+					err = chr.Set(&cacher.CacheBlock{Key: "key", Value: fmt.Sprintf("value%d", w), TTL: time.Minute})
+				case 3:
+					if w == 0 {
+						err = chr.Delete("key")
+					}
+				default:
+					chr.Exists("key")
+					if ttler, ok := chr.(cacher.ITTLer); ok {
+						ttler.TTL("key")
+						ttler.Expire("key", time.Minute)
+					}
+					_, err = chr.Get("key")
+					if v, ok := chr.(cacher.IVersioner); ok && err == nil {
+						var version uint64
+						if _, version, err = v.GetWithVersion("key"); err == nil {
+							err = v.CompareAndSwap(context.Background(), "key", version, &cacher.CacheBlock{Value: "swapped", TTL: time.Minute})
+						}
+					}
+				}
+
+				if errors.Is(err, cacher.ErrCacheMiss) || errors.Is(err, cacher.ErrVersionMismatch) {
+					err = nil
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func (self *Suite) testActive(t *testing.T, chr cacher.ICacher) {
+	if !chr.Active() {
+		t.Fatal("new cacher should be active")
+	}
+
+	set(t, chr, &cacher.CacheBlock{Key: "key", Value: "value"})
+	if chr.Active(false) {
+		t.Fatal("cacher should be inactive")
+	}
+
+	if _, err := chr.Get("key"); !errors.Is(err, cacher.ErrInactive) {
+		t.Fatalf("expected ErrInactive, got %v", err)
+	}
+	chr.Set(&cacher.CacheBlock{Key: "other", Value: "value"})
+
+	if !chr.Active(true) {
+		t.Fatal("cacher should be active")
+	}
+	expect(t, chr, "key", "value")
+	expectMiss(t, chr, "other")
+}
+
+func (self *Suite) testClose(t *testing.T, chr cacher.ICacher) {
+	set(t, chr, &cacher.CacheBlock{Key: "key", Value: "value"})
+	if err := chr.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	// closing twice is safe
+	if err := chr.Close(); err != nil {
+		t.Fatalf("close twice: %v", err)
+	}
+}
+
+func (self *Suite) testCompareAndSwap(t *testing.T, chr cacher.ICacher) {
+	versioner, ok := chr.(cacher.IVersioner)
+	if !ok {
+		t.Skip("not an IVersioner")
+	}
+
+	ctx := context.Background()
+	if err := versioner.CompareAndSwap(ctx, "key", 0, &cacher.CacheBlock{Value: "v1"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	value, v1, err := versioner.GetWithVersion("key")
+	if err != nil || value != "v1" || v1 == 0 {
+		t.Fatalf("get with version: %v %d %v", value, v1, err)
+	}
+
+	if err := versioner.CompareAndSwap(ctx, "key", 0, &cacher.CacheBlock{Value: "v2"}); !errors.Is(err, cacher.ErrVersionMismatch) {
+		t.Fatalf("create existing key: expected ErrVersionMismatch, got %v", err)
+	}
+
+	block := &cacher.CacheBlock{Value: "v2"}
+	if err := versioner.CompareAndSwap(ctx, "key", v1, block); err != nil {
+		t.Fatalf("swap: %v", err)
+	}
+	expect(t, chr, "key", "v2")
+
+	_, v2, _ := versioner.GetWithVersion("key")
+	if v2 == v1 || block.Version != v2 {
+		t.Fatalf("version is not changed by the swap %d %d %d", v1, v2, block.Version)
+	}
+
+	if err := versioner.CompareAndSwap(ctx, "key", v1, &cacher.CacheBlock{Value: "v3"}); !errors.Is(err, cacher.ErrVersionMismatch) {
+		t.Fatalf("stale version: expected ErrVersionMismatch, got %v", err)
+	}
+
+	set(t, chr, &cacher.CacheBlock{Key: "key", Value: "v4"})
+	if err := versioner.CompareAndSwap(ctx, "key", v2, &cacher.CacheBlock{Value: "v5"}); !errors.Is(err, cacher.ErrVersionMismatch) {
+		t.Fatalf("version after Set: expected ErrVersionMismatch, got %v", err)
+	}
+	expect(t, chr, "key", "v4")
+}
+
+func (self *Suite) testUpdate(t *testing.T, chr cacher.ICacher) {
+	updater, ok := chr.(cacher.IUpdater)
+	if !ok {
+		t.Skip("not an IUpdater")
+	}
+
+	const workers, count = 8, 25
+
+	incr := func(old any, exists bool) (any, time.Duration, bool) {
+		n := 0
+		if exists {
+			n, _ = strconv.Atoi(old.(string))
+		}
+		return strconv.Itoa(n + 1), time.Hour, false
+	}
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				if err := updater.Update(ctx, "counter", incr); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("update: %v", err)
+	}
+	expect(t, chr, "counter", strconv.Itoa(workers*count))
+
+	err := updater.Update(ctx, "counter", func(old any, exists bool) (any, time.Duration, bool) {
+		return nil, 0, true
+	})
+	if err != nil {
+		t.Fatalf("update delete: %v", err)
+	}
+	expectMiss(t, chr, "counter")
+
+	// deleting a missing key is not an error
+	err = updater.Update(ctx, "missing", func(old any, exists bool) (any, time.Duration, bool) {
+		if exists {
+			t.Error("missing key exists")
+		}
+		return nil, 0, true
+	})
+	if err != nil {
+		t.Fatalf("update missing: %v", err)
+	}
+}
+
+func (self *Suite) testHash(t *testing.T, chr cacher.ICacher) {
+	hasher, ok := chr.(cacher.IHashCacher)
+	if !ok {
+		t.Skip("not an IHashCacher")
+	}
+
+	type profile struct {
+		Name  string   `hash:"name"`
+		Score int      `hash:"score"`
+		Tags  []string `hash:"tags,omitempty"`
+		Note  string   `hash:"-"`
+	}
+
+	fields, err := cacher.HashFields(profile{Name: "tom", Score: 1, Tags: []string{"a", "b"}, Note: "skipped"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hasher.HSet("user", fields, time.Minute); err != nil {
+		t.Fatalf("hset: %v", err)
+	}
+
+	// 只改一个字段
+	if err := hasher.HSet("user", map[string]any{"score": 2}, time.Minute); err != nil {
+		t.Fatalf("hset field: %v", err)
+	}
+	if name, err := hasher.HGet("user", "name"); err != nil || name != "tom" {
+		t.Fatalf("hget name: %v %v", name, err)
+	}
+	if _, err := hasher.HGet("user", "note"); !errors.Is(err, cacher.ErrCacheMiss) {
+		t.Fatalf("hget skipped field: expected ErrCacheMiss, got %v", err)
+	}
+
+	all, err := hasher.HGetAll("user")
+	if err != nil || len(all) != 3 {
+		t.Fatalf("hgetall: %v %v", all, err)
+	}
+	var got profile
+	if err := cacher.ScanHash(all, &got); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if got.Name != "tom" || got.Score != 2 || len(got.Tags) != 2 || got.Tags[1] != "b" {
+		t.Fatalf("scanned %+v", got)
+	}
+
+	if err := hasher.HDel("user", []string{"tags", "missing"}); err != nil {
+		t.Fatalf("hdel: %v", err)
+	}
+	if _, err := hasher.HGet("user", "tags"); !errors.Is(err, cacher.ErrCacheMiss) {
+		t.Fatalf("hget deleted field: expected ErrCacheMiss, got %v", err)
+	}
+
+	// HDel保持剩余的TTL,和redis一样
+	if ttler, ok := chr.(cacher.ITTLer); ok {
+		self.Sleep(1500 * time.Millisecond)
+		if err := hasher.HDel("user", []string{"missing"}); err != nil {
+			t.Fatalf("hdel missing: %v", err)
+		}
+		if ttl, err := ttler.TTL("user"); err != nil || ttl > time.Minute-time.Second {
+			t.Fatalf("ttl after hdel: %v %v", ttl, err)
+		}
+	}
+
+	if err := hasher.HDel("user", []string{"name", "score"}); err != nil {
+		t.Fatalf("hdel all: %v", err)
+	}
+	if _, err := hasher.HGetAll("user"); !errors.Is(err, cacher.ErrCacheMiss) {
+		t.Fatalf("hgetall after the last field: expected ErrCacheMiss, got %v", err)
+	}
+
+	set(t, chr, &cacher.CacheBlock{Key: "plain", Value: "v"})
+	if err := hasher.HSet("plain", map[string]any{"f": "v"}, time.Minute); !errors.Is(err, cacher.ErrWrongType) {
+		t.Fatalf("hset on a plain key: expected ErrWrongType, got %v", err)
+	}
+	if _, err := hasher.HGet("plain", "f"); !errors.Is(err, cacher.ErrWrongType) {
+		t.Fatalf("hget on a plain key: expected ErrWrongType, got %v", err)
+	}
+
+	if err := hasher.HSet("short", map[string]any{"f": "v"}, 2*time.Second); err != nil {
+		t.Fatalf("hset short: %v", err)
+	}
+	self.Sleep(3 * time.Second)
+	if _, err := hasher.HGet("short", "f"); !errors.Is(err, cacher.ErrCacheMiss) {
+		t.Fatalf("hget expired hash: expected ErrCacheMiss, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package cacher
+
+import (
+	"time"
+)
+
+type (
+	// Clock tells the time to the cachers,it is replaced by a fake clock in tests.
+	Clock interface {
+		Now() time.Time
+		// After waits for the duration to elapse and then sends the current time.
+		After(d time.Duration) <-chan time.Time
+	}
+
+	systemClock struct{}
+)
+
+// SystemClock is the default clock which is backed by the time package.
+var SystemClock Clock = systemClock{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithClock sets the clock of a cacher.
+func WithClock(clock Clock) Option {
+	return func(cfg *Config) {
+		cfg.SetByField("clock", clock)
+	}
+}
@@ -0,0 +1,118 @@
+package cacher
+
+import (
+	"context"
+)
+
+type (
+	// IContextCacher is the context-first variant of ICacher.
+	// every operation gives up once the context is done and returns ctx.Err().
+	IContextCacher interface {
+		GetCtx(ctx context.Context, key string) (any, error)
+		SetCtx(ctx context.Context, block *CacheBlock) error
+		ExistsCtx(ctx context.Context, key string) (bool, error)
+		DeleteCtx(ctx context.Context, key string) error
+		KeysCtx(ctx context.Context) ([]string, error)
+		LenCtx(ctx context.Context) (int, error)
+		ClearCtx(ctx context.Context) error
+		CloseCtx(ctx context.Context) error
+	}
+
+	// contextCache adapts an ICacher which knows nothing about the context.
+	contextCache struct {
+		ICacher
+	}
+)
+
+// WithContext returns the context-first API of the cacher.
+// cachers which do not implement IContextCacher are adapted,the operation
+// keeps running in background when the context is done before it returns.
+func WithContext(c ICacher) IContextCacher {
+	if cc, ok := c.(IContextCacher); ok {
+		return cc
+	}
+	return &contextCache{c}
+}
+
+// call runs fn unless the context is done first.
+func call[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	type result struct {
+		val T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		val, err := fn()
+		done <- result{val, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.val, r.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+func (self *contextCache) GetCtx(ctx context.Context, key string) (any, error) {
+	return call(ctx, func() (any, error) {
+		return self.ICacher.Get(key, ctx)
+	})
+}
+
+func (self *contextCache) SetCtx(ctx context.Context, block *CacheBlock) error {
+	_, err := call(ctx, func() (struct{}, error) {
+		bb := block.Clone()
+		bb.Ctx = ctx
+		return struct{}{}, self.ICacher.Set(bb)
+	})
+	return err
+}
+
+func (self *contextCache) ExistsCtx(ctx context.Context, key string) (bool, error) {
+	return call(ctx, func() (bool, error) {
+		return self.ICacher.Exists(key, ctx), nil
+	})
+}
+
+func (self *contextCache) DeleteCtx(ctx context.Context, key string) error {
+	_, err := call(ctx, func() (struct{}, error) {
+		return struct{}{}, self.ICacher.Delete(key, ctx)
+	})
+	return err
+}
+
+func (self *contextCache) KeysCtx(ctx context.Context) ([]string, error) {
+	return call(ctx, func() ([]string, error) {
+		return self.ICacher.Keys(ctx), nil
+	})
+}
+
+func (self *contextCache) LenCtx(ctx context.Context) (int, error) {
+	return call(ctx, func() (int, error) {
+		return self.ICacher.Len(), nil
+	})
+}
+
+func (self *contextCache) ClearCtx(ctx context.Context) error {
+	_, err := call(ctx, func() (struct{}, error) {
+		return struct{}{}, self.ICacher.Clear()
+	})
+	return err
+}
+
+func (self *contextCache) CloseCtx(ctx context.Context) error {
+	_, err := call(ctx, func() (struct{}, error) {
+		return struct{}{}, self.ICacher.Close()
+	})
+	return err
+}
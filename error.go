@@ -5,4 +5,14 @@ import "errors"
 var (
 	ErrCacheMiss = errors.New("cache: key is missing")
 	ErrInactive  = errors.New("cache: cache is inactive")
+	ErrReadOnly  = errors.New("cache: cache is read only")
+	ErrWrongType = errors.New("cache: key holds the wrong kind of value")
+
+	// ErrVersionMismatch is returned by CompareAndSwap if the key was changed meanwhile.
+	ErrVersionMismatch = errors.New("cache: version mismatch")
+
+	// errors of the locks
+	ErrLockHeld     = errors.New("cache: lock is held by others")
+	ErrLockLost     = errors.New("cache: lock is lost")
+	ErrLockReleased = errors.New("cache: lock is released")
 )
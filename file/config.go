@@ -0,0 +1,59 @@
+package file
+
+import (
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+type (
+	MarshalFunc   func(any) ([]byte, error)
+	UnmarshalFunc func([]byte, *any) error
+
+	Option func(*Config)
+
+	Config struct {
+		cacher.Config
+		Active    bool
+		Dir       string        // root of the shards
+		MaxSize   int64         `field:"max_size"` // bytes,the least recently accessed files are evicted over it,0 is unlimited
+		Interval  time.Duration // interval of the gc walker,0 disables it
+		Marshal   MarshalFunc
+		Unmarshal UnmarshalFunc
+		Logger    cacher.Logger
+		Clock     cacher.Clock
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+// WithDir sets the directory of the cache files.
+func WithDir(dir string) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("dir", dir)
+	}
+}
+
+// WithMaxSize caps the total size of the cache files in bytes.
+func WithMaxSize(size int64) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("max_size", size)
+	}
+}
+
+// WithInterval sets how often the gc walks the files.
+func WithInterval(interval time.Duration) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("interval", interval)
+	}
+}
+
+// WithCodec sets how the values are encoded in the files.
+func WithCodec(marshal MarshalFunc, unmarshal UnmarshalFunc) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("marshal", marshal)
+		cfg.SetByField("unmarshal", unmarshal)
+	}
+}
@@ -0,0 +1,415 @@
+// Package file stores every cache as a file,the key is hashed into sharded directories
+// like <dir>/ab/cd/abcd....cache so no directory grows too large.
+//
+// the files are written to a temporary file and renamed,so readers never see a partial file.
+// the modification time of a file is its last access time which the gc evicts by.
+package file
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/internal/codec"
+)
+
+const (
+	magic      = "CACHERF1"
+	headerLen  = len(magic) + 8 + 2 // magic,expire at,length of the key
+	ext        = ".cache"
+	tmpExt     = ".tmp"
+	lockStripe = 256
+)
+
+var errFormat = errors.New("cache: not a cache file")
+
+var File = cacher.Register("File", func() cacher.ICacher {
+	return New()
+})
+
+type (
+	FileCache struct {
+		config    *Config
+		locks     [lockStripe]sync.Mutex // serialize the writes of a key in the process
+		size      int64                  // approximate total size of the files
+		evict     chan struct{}
+		exit      chan struct{}
+		closeOnce sync.Once
+	}
+
+	// header is the metadata in front of the value.
+	header struct {
+		Key      string
+		ExpireAt time.Time // zero means never expire
+	}
+)
+
+// New returns a file cacher,the files are kept in the directory after Close.
+func New(opts ...cacher.Option) *FileCache {
+	cfg := &Config{
+		Active:   true,
+		Dir:      filepath.Join(os.TempDir(), "cacher"),
+		Interval: cacher.INTERVAL_TIME * time.Second,
+	}
+	cfg.Init(opts...)
+
+	if cfg.Logger == nil {
+		cfg.Logger = cacher.DefaultLogger()
+	}
+
+	if cfg.Clock == nil {
+		cfg.Clock = cacher.SystemClock
+	}
+
+	if cfg.Marshal == nil || cfg.Unmarshal == nil {
+		cfg.Marshal, cfg.Unmarshal = codec.GobMarshal, codec.GobUnmarshal
+	}
+
+	c := &FileCache{
+		config: cfg,
+		evict:  make(chan struct{}, 1),
+		exit:   make(chan struct{}),
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		cfg.Logger.Error("cache: create dir failed", "dir", cfg.Dir, "error", err)
+	}
+
+	// 统计已有文件的大小
+	c.walk(func(path string, info fs.FileInfo) error {
+		c.size += info.Size()
+		return nil
+	})
+
+	if cfg.Interval > 0 || cfg.MaxSize > 0 {
+		go c.vaccuum()
+	}
+
+	return c
+}
+
+func (self *FileCache) Init(opts ...cacher.Option) {
+	self.config.Init(opts...)
+}
+
+func (self *FileCache) String() string {
+	return "file"
+}
+
+func (self *FileCache) Active(on ...bool) bool {
+	if len(on) > 0 {
+		self.config.Active = on[0]
+	}
+
+	return self.config.Active
+}
+
+// path returns the file of the key and the index of its lock.
+func (self *FileCache) path(key string) (string, int) {
+	h := xxhash.Sum64String(key)
+	name := fmt.Sprintf("%016x", h)
+	return filepath.Join(self.config.Dir, name[:2], name[2:4], name+ext), int(h % lockStripe)
+}
+
+// Get reads the file of the key and refreshes its access time.
+func (self *FileCache) Get(key string, ctx ...context.Context) (any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	if err := cacher.Context(ctx...).Err(); err != nil {
+		return nil, err
+	}
+
+	path, _ := self.path(key)
+	hdr, b, err := self.read(path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if hdr.Key != key {
+		return nil, cacher.ErrCacheMiss // hash collision
+	}
+
+	var value any
+	if err := self.config.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+
+	now := self.config.Clock.Now()
+	os.Chtimes(path, now, now)
+	return value, nil
+}
+
+// read returns the header and optionally the value,an expired file is a miss
+// and removed unless its key is being written.
+func (self *FileCache) read(path string, value bool) (*header, []byte, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, cacher.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	hdr, err := readHeader(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if hdr.expired(self.config.Clock.Now()) {
+		f.Close()
+		self.removeExpired(path, false) // Set持有锁时会覆盖它
+		return nil, nil, cacher.ErrCacheMiss
+	}
+
+	if !value {
+		return hdr, nil, nil
+	}
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hdr, b, nil
+}
+
+func readHeader(r io.Reader) (*header, error) {
+	var buf [headerLen]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil || string(buf[:len(magic)]) != magic {
+		return nil, errFormat
+	}
+
+	hdr := &header{}
+	if at := int64(binary.LittleEndian.Uint64(buf[len(magic):])); at != 0 {
+		hdr.ExpireAt = time.Unix(0, at)
+	}
+
+	key := make([]byte, binary.LittleEndian.Uint16(buf[len(magic)+8:]))
+	if _, err := io.ReadFull(r, key); err != nil {
+		return nil, errFormat
+	}
+	hdr.Key = string(key)
+	return hdr, nil
+}
+
+func (self *header) encode() []byte {
+	b := make([]byte, headerLen, headerLen+len(self.Key))
+	copy(b, magic)
+	if !self.ExpireAt.IsZero() {
+		binary.LittleEndian.PutUint64(b[len(magic):], uint64(self.ExpireAt.UnixNano()))
+	}
+	binary.LittleEndian.PutUint16(b[len(magic)+8:], uint16(len(self.Key)))
+	return append(b, self.Key...)
+}
+
+func (self *header) expired(now time.Time) bool {
+	return !self.ExpireAt.IsZero() && !now.Before(self.ExpireAt)
+}
+
+// Set writes the file by renaming a temporary file over it.
+// SetOnlyNew and SetOnlyExist are atomic only within the process.
+func (self *FileCache) Set(block *cacher.CacheBlock) error {
+	if !self.config.Active {
+		return nil
+	}
+
+	if err := block.Context().Err(); err != nil {
+		return err
+	}
+
+	if len(block.Key) > 0xffff {
+		return fmt.Errorf("cache: key is too long %d", len(block.Key))
+	}
+
+	value, err := self.config.Marshal(block.Value)
+	if err != nil {
+		return err
+	}
+
+	path, i := self.path(block.Key)
+	self.locks[i].Lock()
+	defer self.locks[i].Unlock()
+
+	if block.SetOnlyNew || block.SetOnlyExist {
+		hdr, _, err := self.read(path, false)
+		exists := err == nil && hdr.Key == block.Key
+		if (exists && block.SetOnlyNew) || (!exists && block.SetOnlyExist) {
+			return nil
+		}
+	}
+
+	now := self.config.Clock.Now()
+	hdr := &header{Key: block.Key}
+	if ttl := block.Ttl(); ttl > 0 {
+		hdr.ExpireAt = now.Add(ttl)
+	}
+
+	old := fileSize(path)
+	if err := self.write(path, hdr.encode(), value, now); err != nil {
+		self.config.Logger.Error("cache: write file failed", "key", block.Key, "error", err)
+		return err
+	}
+
+	size := atomic.AddInt64(&self.size, fileSize(path)-old)
+	if self.config.MaxSize > 0 && size > self.config.MaxSize {
+		select {
+		case self.evict <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (self *FileCache) write(path string, hdr, value []byte, now time.Time) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*"+tmpExt)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(hdr)
+	if err == nil {
+		_, err = f.Write(value)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, now, now)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+func fileSize(path string) int64 {
+	if info, err := os.Stat(path); err == nil {
+		return info.Size()
+	}
+	return 0
+}
+
+// remove deletes the file and keeps the size up to date.
+func (self *FileCache) remove(path string) error {
+	size := fileSize(path)
+	err := os.Remove(path)
+	if err == nil {
+		atomic.AddInt64(&self.size, -size)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (self *FileCache) Exists(key string, ctx ...context.Context) bool {
+	if !self.config.Active {
+		return false
+	}
+
+	path, _ := self.path(key)
+	hdr, _, err := self.read(path, false)
+	return err == nil && hdr.Key == key
+}
+
+// Delete removes the file of the key,deleting a missing key is not an error.
+func (self *FileCache) Delete(key string, ctx ...context.Context) error {
+	if err := cacher.Context(ctx...).Err(); err != nil {
+		return err
+	}
+
+	path, i := self.path(key)
+	self.locks[i].Lock()
+	defer self.locks[i].Unlock()
+
+	return self.remove(path)
+}
+
+// walk calls fn for every cache file.
+func (self *FileCache) walk(fn func(path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(self.config.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil // removed meanwhile
+			}
+			return err
+		}
+
+		if d.IsDir() || filepath.Ext(path) != ext {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		return fn(path, info)
+	})
+}
+
+// Keys reads the headers of all files which are not expired.
+func (self *FileCache) Keys(ctx ...context.Context) []string {
+	if !self.config.Active {
+		return nil
+	}
+
+	c := cacher.Context(ctx...)
+	var keys []string
+	self.walk(func(path string, info fs.FileInfo) error {
+		if err := c.Err(); err != nil {
+			return err
+		}
+
+		if hdr, _, err := self.read(path, false); err == nil {
+			keys = append(keys, hdr.Key)
+		}
+		return nil
+	})
+	return keys
+}
+
+func (self *FileCache) Len() int {
+	return len(self.Keys())
+}
+
+// Clear removes all cache files but leaves the directories.
+func (self *FileCache) Clear() error {
+	return self.walk(func(path string, info fs.FileInfo) error {
+		return self.remove(path)
+	})
+}
+
+// Close stops the gc,the files are kept for the next run.
+func (self *FileCache) Close() error {
+	self.closeOnce.Do(func() {
+		close(self.exit)
+	})
+	return nil
+}
+
+// Size returns the approximate total size of the cache files in bytes.
+func (self *FileCache) Size() int64 {
+	return atomic.LoadInt64(&self.size)
+}
@@ -0,0 +1,110 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/cachertest"
+)
+
+func TestConformance(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	cachertest.Run(t, func(t *testing.T) cacher.ICacher {
+		return New(WithDir(t.TempDir()), WithInterval(0), cacher.WithClock(clock))
+	}, cachertest.WithSleep(clock.Advance))
+}
+
+func TestRegister(t *testing.T) {
+	chr, err := cacher.New("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chr.Close()
+
+	if chr.String() != "file" {
+		t.Fatalf("unexpected cacher %s", chr)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	dir := t.TempDir()
+	chr := New(WithDir(dir), WithInterval(0))
+	chr.Set(&cacher.CacheBlock{Key: "A", Value: "a"})
+	chr.Close()
+
+	path, _ := chr.path("A")
+	rel, _ := filepath.Rel(dir, path)
+	if parts := strings.Split(rel, string(filepath.Separator)); len(parts) != 3 || !strings.HasPrefix(parts[2], parts[0]+parts[1]) {
+		t.Fatalf("unexpected layout %s", rel)
+	}
+
+	// 文件在关闭后保留
+	chr = New(WithDir(dir), WithInterval(0))
+	defer chr.Close()
+	if v, err := chr.Get("A"); err != nil || v != "a" {
+		t.Fatalf("get A %v %v", v, err)
+	}
+}
+
+func TestGC(t *testing.T) {
+	dir := t.TempDir()
+	clock := cachertest.NewClock(time.Now())
+	chr := New(WithDir(dir), WithInterval(0), cacher.WithClock(clock))
+	defer chr.Close()
+
+	value := strings.Repeat("x", 100)
+	for _, key := range []string{"A", "B", "C", "D"} {
+		chr.Set(&cacher.CacheBlock{Key: key, Value: value})
+		clock.Advance(time.Second)
+	}
+	chr.Set(&cacher.CacheBlock{Key: "E", Value: value, TTL: time.Second})
+	chr.Get("A") // A 最近被访问过
+
+	stale := filepath.Join(dir, "stale.cache.1.tmp")
+	os.WriteFile(stale, nil, 0o644)
+	os.Chtimes(stale, clock.Now().Add(-2*staleTmp), clock.Now().Add(-2*staleTmp))
+
+	clock.Advance(2 * time.Second)
+	chr.config.MaxSize = chr.Size() * 2 / 5
+	chr.gc()
+
+	keys := chr.Keys()
+	sort.Strings(keys)
+	if strings.Join(keys, "") != "AD" {
+		t.Fatalf("keys after gc %v", keys)
+	}
+	if chr.Size() > chr.config.MaxSize {
+		t.Fatalf("size %d over %d", chr.Size(), chr.config.MaxSize)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatal("stale temporary file is not removed")
+	}
+}
+
+func TestGCRecheck(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	chr := New(WithDir(t.TempDir()), WithInterval(0), cacher.WithClock(clock))
+	defer chr.Close()
+
+	chr.Set(&cacher.CacheBlock{Key: "A", Value: "old", TTL: time.Second})
+	path, _ := chr.path("A")
+	info, _ := os.Stat(path)
+	clock.Advance(2 * time.Second)
+
+	// gc看到过期后,Set在删除前重写了文件
+	chr.Set(&cacher.CacheBlock{Key: "A", Value: "new"})
+	if chr.removeExpired(path, true) {
+		t.Fatal("rewritten file is removed as expired")
+	}
+	if chr.evictFile(entry{path: path, access: info.ModTime(), size: info.Size()}) {
+		t.Fatal("rewritten file is evicted")
+	}
+	if v, err := chr.Get("A"); err != nil || v != "new" {
+		t.Fatalf("get A %v %v", v, err)
+	}
+}
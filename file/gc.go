@@ -0,0 +1,173 @@
+package file
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// temporary files of crashed writes are removed after it
+const staleTmp = time.Hour
+
+type entry struct {
+	path   string
+	access time.Time
+	size   int64
+}
+
+// vaccuum runs the gc every Interval or once the size cap is exceeded.
+func (self *FileCache) vaccuum() {
+	for {
+		var tick <-chan time.Time
+		if self.config.Interval > 0 {
+			tick = self.config.Clock.After(self.config.Interval)
+		}
+
+		select {
+		case <-tick:
+		case <-self.evict:
+		case <-self.exit:
+			return
+		}
+
+		if self.config.Active {
+			self.gc()
+		}
+	}
+}
+
+// gc removes the expired files and evicts the least recently accessed ones over MaxSize.
+func (self *FileCache) gc() {
+	start := time.Now()
+	now := self.config.Clock.Now()
+
+	var (
+		entries []entry
+		total   int64
+		expired int
+	)
+	filepath.WalkDir(self.config.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		switch filepath.Ext(path) {
+		case tmpExt:
+			if now.Sub(info.ModTime()) > staleTmp {
+				os.Remove(path)
+			}
+			return nil
+		case ext:
+		default:
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		hdr, err := readHeader(f)
+		f.Close()
+
+		if errors.Is(err, errFormat) || (err == nil && hdr.expired(now)) {
+			if self.removeExpired(path, true) {
+				expired++
+			}
+			return nil
+		}
+
+		entries = append(entries, entry{path: path, access: info.ModTime(), size: info.Size()})
+		total += info.Size()
+		return nil
+	})
+
+	evicted := 0
+	if max := self.config.MaxSize; max > 0 && total > max {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].access.Before(entries[j].access)
+		})
+
+		for _, e := range entries {
+			if total <= max {
+				break
+			}
+			if self.evictFile(e) {
+				total -= e.size
+				evicted++
+			}
+		}
+	}
+
+	// 以实际大小校正
+	atomic.StoreInt64(&self.size, total)
+
+	self.config.Logger.Debug("cache: file gc",
+		"expired", expired,
+		"evicted", evicted,
+		"size", total,
+		"duration", time.Since(start),
+	)
+}
+
+// lock returns the lock of the file,its name is the hash of the key.
+func (self *FileCache) lock(path string) *sync.Mutex {
+	h, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), ext), 16, 64)
+	if err != nil {
+		return nil // 不是Set写的文件
+	}
+	return &self.locks[h%lockStripe]
+}
+
+// removeExpired removes the file if it is still expired or broken under the lock of its key,
+// so a file which Set renamed over it meanwhile is kept. it gives up if wait is false and the lock is held.
+func (self *FileCache) removeExpired(path string, wait bool) bool {
+	if mu := self.lock(path); mu != nil {
+		if !wait && !mu.TryLock() {
+			return false
+		} else if wait {
+			mu.Lock()
+		}
+		defer mu.Unlock()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	hdr, err := readHeader(f)
+	f.Close()
+
+	if errors.Is(err, errFormat) || (err == nil && hdr.expired(self.config.Clock.Now())) {
+		return self.remove(path) == nil
+	}
+	return false
+}
+
+// evictFile removes the file under the lock of its key unless it was written or read since the walk.
+func (self *FileCache) evictFile(e entry) bool {
+	if mu := self.lock(e.path); mu != nil {
+		mu.Lock()
+		defer mu.Unlock()
+	}
+
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return errors.Is(err, os.ErrNotExist)
+	}
+	if !info.ModTime().Equal(e.access) {
+		return false
+	}
+	return self.remove(e.path) == nil
+}
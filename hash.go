@@ -0,0 +1,184 @@
+package cacher
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// hashTag names the hash field of a struct field,"-" skips it
+// and "omitempty" skips a zero value,e.g. `hash:"name,omitempty"`.
+const hashTag = "hash"
+
+type hashField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// hashFields returns the mapped fields of the struct type,
+// untagged embedded structs are flattened and untagged fields keep their names.
+func hashFields(typ reflect.Type) []hashField {
+	var fields []hashField
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag, has := f.Tag.Lookup(hashTag)
+		if tag == "-" {
+			continue
+		}
+
+		if f.Anonymous && !has && f.Type.Kind() == reflect.Struct {
+			for _, sub := range hashFields(f.Type) {
+				sub.index = append([]int{i}, sub.index...)
+				fields = append(fields, sub)
+			}
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, hashField{name: name, index: []int{i}, omitEmpty: opts == "omitempty"})
+	}
+	return fields
+}
+
+// structOf returns the struct which v is or points to.
+func structOf(v any) (reflect.Value, error) {
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Pointer && !val.IsNil() {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("cache: %T is not a struct", v)
+	}
+	return val, nil
+}
+
+// HashFields maps the struct to the fields of a hash by the `hash` tags.
+//
+//	type Profile struct {
+//		Name  string `hash:"name"`
+//		Score int    `hash:"score,omitempty"`
+//	}
+//
+//	fields, _ := cacher.HashFields(profile)
+//	chr.HSet("user:1", fields, time.Hour)
+func HashFields(v any) (map[string]any, error) {
+	val, err := structOf(v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]any)
+	for _, f := range hashFields(val.Type()) {
+		fv := val.FieldByIndex(f.index)
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		fields[f.name] = fv.Interface()
+	}
+	return fields, nil
+}
+
+// ScanHash fills the struct dst points to from the fields of a hash by the `hash` tags,
+// the struct fields without a hash field are kept. the values are converted
+// between numeric kinds and element by element for slices and maps,
+// since a decoded int64 or []any does not match an int or []string field.
+func ScanHash(fields map[string]any, dst any) error {
+	ptr := reflect.ValueOf(dst)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("cache: scan into non pointer %T", dst)
+	}
+
+	val, err := structOf(dst)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range hashFields(val.Type()) {
+		v, has := fields[f.name]
+		if !has {
+			continue
+		}
+		if err := assign(val.FieldByIndex(f.index), v); err != nil {
+			return fmt.Errorf("cache: scan field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// assign sets dst to v converting it if needed.
+func assign(dst reflect.Value, v any) error {
+	if v == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	src := reflect.ValueOf(v)
+	switch {
+	case src.Type().AssignableTo(dst.Type()):
+		dst.Set(src)
+
+	case dst.Kind() == reflect.Pointer:
+		elem := reflect.New(dst.Type().Elem())
+		if err := assign(elem.Elem(), v); err != nil {
+			return err
+		}
+		dst.Set(elem)
+
+	case dst.Kind() == reflect.Slice && (src.Kind() == reflect.Slice || src.Kind() == reflect.Array):
+		s := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			if err := assign(s.Index(i), src.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+
+	case dst.Kind() == reflect.Map && src.Kind() == reflect.Map:
+		m := reflect.MakeMapWithSize(dst.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(dst.Type().Key()).Elem()
+			if err := assign(k, iter.Key().Interface()); err != nil {
+				return err
+			}
+			e := reflect.New(dst.Type().Elem()).Elem()
+			if err := assign(e, iter.Value().Interface()); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, e)
+		}
+		dst.Set(m)
+
+	case sameKind(src.Kind(), dst.Kind()):
+		dst.Set(src.Convert(dst.Type()))
+
+	default:
+		return fmt.Errorf("can not assign %T to %s", v, dst.Type())
+	}
+	return nil
+}
+
+// sameKind reports whether the kinds convert without changing the meaning,
+// unlike reflect which converts an int to a string of the rune.
+func sameKind(a, b reflect.Kind) bool {
+	kind := func(k reflect.Kind) int {
+		switch {
+		case k >= reflect.Int && k <= reflect.Float64:
+			return 1
+		case k == reflect.String:
+			return 2
+		case k == reflect.Bool:
+			return 3
+		}
+		return 0
+	}
+	return kind(a) != 0 && kind(a) == kind(b)
+}
@@ -0,0 +1,64 @@
+package cacher_test
+
+import (
+	"testing"
+
+	"github.com/volts-dev/cacher"
+)
+
+type base struct {
+	ID int64 `hash:"id"`
+}
+
+type account struct {
+	base
+	Name    string
+	Email   *string        `hash:"email,omitempty"`
+	Limits  map[string]int `hash:"limits"`
+	Enabled bool           `hash:"enabled"`
+	secret  string
+}
+
+func TestHashFields(t *testing.T) {
+	fields, err := cacher.HashFields(&account{base: base{ID: 7}, Name: "tom", secret: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 4 || fields["id"] != int64(7) || fields["Name"] != "tom" {
+		t.Fatalf("fields %v", fields)
+	}
+	if _, has := fields["email"]; has {
+		t.Fatal("empty email is not omitted")
+	}
+
+	if _, err := cacher.HashFields("not a struct"); err == nil {
+		t.Fatal("string is mapped")
+	}
+}
+
+func TestScanHash(t *testing.T) {
+	// 解码后的类型与字段不同
+	fields := map[string]any{
+		"id":      uint8(7),
+		"Name":    "tom",
+		"email":   "tom@example.com",
+		"limits":  map[string]any{"daily": int64(10)},
+		"enabled": true,
+		"unknown": 1,
+	}
+
+	var a account
+	if err := cacher.ScanHash(fields, &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.ID != 7 || a.Name != "tom" || a.Email == nil || *a.Email != "tom@example.com" || a.Limits["daily"] != 10 || !a.Enabled {
+		t.Fatalf("scanned %+v", a)
+	}
+
+	if err := cacher.ScanHash(map[string]any{"Name": 1}, &a); err == nil {
+		t.Fatal("int is scanned into a string")
+	}
+	if err := cacher.ScanHash(fields, a); err == nil {
+		t.Fatal("scan into a non pointer")
+	}
+}
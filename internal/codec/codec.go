@@ -0,0 +1,78 @@
+// Package codec is the value pipeline shared by the network adapters:
+// msgpack,then s2 compression for values over the threshold.
+// the last byte tells whether the value is compressed.
+package codec
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/vmihailenco/msgpack"
+)
+
+const (
+	compressionThreshold = 64
+	timeLen              = 4
+)
+
+const (
+	noCompression = 0x0
+	s2Compression = 0x1
+)
+
+// Marshal encodes every value with msgpack so that Unmarshal can restore its type.
+func Marshal(value interface{}) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	b, err := msgpack.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return compress(b), nil
+}
+
+func Unmarshal(b []byte, value interface{}) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	switch c := b[len(b)-1]; c {
+	case noCompression:
+		b = b[:len(b)-1]
+	case s2Compression:
+		b = b[:len(b)-1]
+
+		var err error
+		b, err = s2.Decode(nil, b)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown compression method: %x", c)
+	}
+
+	return msgpack.Unmarshal(b, value)
+}
+
+func compress(data []byte) []byte {
+	if len(data) < compressionThreshold {
+		n := len(data) + 1
+		b := make([]byte, n, n+timeLen)
+		copy(b, data)
+		b[len(b)-1] = noCompression
+		return b
+	}
+
+	n := s2.MaxEncodedLen(len(data)) + 1
+	b := make([]byte, n, n+timeLen)
+	b = s2.Encode(b, data)
+	b = append(b, s2Compression)
+	return b
+}
@@ -0,0 +1,20 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// GobMarshal is the codec of the adapters which keep the values locally,
+// types other than the builtin ones must be registered by gob.Register.
+func GobMarshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func GobUnmarshal(b []byte, v *any) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
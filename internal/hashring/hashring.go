@@ -0,0 +1,107 @@
+// Package hashring is the consistent hash ring shared by the adapters which partition the keys.
+// every node owns replicas*weight points on the ring,so adding or removing a node
+// only moves about its share of the keys.
+package hashring
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+const DefaultReplicas = 160
+
+type (
+	// Ring is not safe for concurrent writes,the callers lock it.
+	Ring struct {
+		replicas int
+		points   []point
+		weights  map[string]int
+	}
+
+	point struct {
+		hash uint64
+		node string
+	}
+)
+
+func New(replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+
+	return &Ring{
+		replicas: replicas,
+		weights:  make(map[string]int),
+	}
+}
+
+// Add puts the node on the ring or changes its weight,a weight below 1 is 1.
+func (self *Ring) Add(node string, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+
+	if _, has := self.weights[node]; has {
+		self.remove(node)
+	}
+	self.weights[node] = weight
+
+	for i := 0; i < self.replicas*weight; i++ {
+		self.points = append(self.points, point{hash: xxhash.Sum64String(node + "-" + strconv.Itoa(i)), node: node})
+	}
+
+	sort.Slice(self.points, func(i, j int) bool {
+		return self.points[i].hash < self.points[j].hash
+	})
+}
+
+// Remove takes the node off the ring,its keys move to the next nodes.
+func (self *Ring) Remove(node string) {
+	if _, has := self.weights[node]; has {
+		self.remove(node)
+		delete(self.weights, node)
+	}
+}
+
+func (self *Ring) remove(node string) {
+	points := self.points[:0]
+	for _, p := range self.points {
+		if p.node != node {
+			points = append(points, p)
+		}
+	}
+	self.points = points
+}
+
+// Weight returns the weight of the node,0 if it is not on the ring.
+func (self *Ring) Weight(node string) int {
+	return self.weights[node]
+}
+
+// Get returns the node owning the key.
+func (self *Ring) Get(key string) (string, bool) {
+	if len(self.points) == 0 {
+		return "", false
+	}
+
+	h := xxhash.Sum64String(key)
+	i := sort.Search(len(self.points), func(i int) bool {
+		return self.points[i].hash >= h
+	})
+	if i == len(self.points) {
+		i = 0
+	}
+	return self.points[i].node, true
+}
+
+// Nodes returns the nodes on the ring in order.
+func (self *Ring) Nodes() []string {
+	nodes := make([]string, 0, len(self.weights))
+	for node := range self.weights {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
@@ -0,0 +1,239 @@
+package cacher
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLockRetry   = 50 * time.Millisecond
+	defaultLockTimeout = 5 * time.Second // of a single renewal
+)
+
+type (
+	// LockStore keeps the tokens of the locks,every method must be atomic.
+	LockStore interface {
+		// Acquire sets the token if the lock is free.
+		Acquire(ctx context.Context, name, token string, ttl time.Duration) (bool, error)
+		// Extend resets the TTL if the lock still holds the token.
+		Extend(ctx context.Context, name, token string, ttl time.Duration) (bool, error)
+		// Release frees the lock if it still holds the token.
+		Release(ctx context.Context, name, token string) (bool, error)
+	}
+
+	// Locker hands out leases of named locks kept in a LockStore.
+	Locker struct {
+		Store     LockStore
+		Clock     Clock
+		Logger    Logger
+		Retry     time.Duration // how often Lock retries a held lock
+		AutoRenew bool          // renew the leases at a third of their TTL until Unlock
+	}
+
+	// Lease is a held lock.
+	Lease struct {
+		Name  string
+		Token string
+
+		locker   *Locker
+		mu       sync.Mutex
+		ttl      time.Duration
+		stop     chan struct{} // closed to stop the renewal
+		stopOnce sync.Once
+		renewing chan struct{} // closed when the renewal has returned
+		done     chan struct{}
+		once     sync.Once
+		err      error
+	}
+)
+
+// NewLocker returns a locker which renews the leases automatically.
+func NewLocker(store LockStore) *Locker {
+	return &Locker{
+		Store:     store,
+		Clock:     SystemClock,
+		Logger:    DefaultLogger(),
+		Retry:     defaultLockRetry,
+		AutoRenew: true,
+	}
+}
+
+// Lock waits until the lock is acquired or the context is done.
+func (self *Locker) Lock(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	for {
+		lease, err := self.TryLock(ctx, name, ttl)
+		if err != ErrLockHeld {
+			return lease, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-self.Clock.After(self.Retry):
+		}
+	}
+}
+
+// TryLock acquires the lock once and returns ErrLockHeld if it is held by others.
+func (self *Locker) TryLock(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := self.Store.Acquire(ctx, name, token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLockHeld
+	}
+
+	lease := &Lease{
+		Name:     name,
+		Token:    token,
+		locker:   self,
+		ttl:      ttl,
+		stop:     make(chan struct{}),
+		renewing: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if self.AutoRenew {
+		go lease.renew()
+	} else {
+		close(lease.renewing)
+	}
+	return lease, nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Unlock releases the lock and returns ErrLockLost if it has been taken over meanwhile.
+// the renewal is stopped first,so a renewal racing the release can not mark the lease lost.
+func (self *Lease) Unlock(ctx context.Context) error {
+	self.halt()
+	<-self.renewing
+
+	if err := self.Err(); err != nil {
+		return err
+	}
+
+	ok, err := self.locker.Store.Release(ctx, self.Name, self.Token)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		self.end(ErrLockLost)
+		return ErrLockLost
+	}
+
+	self.end(nil)
+	return nil
+}
+
+// Extend resets the TTL of the lock and the renewal uses the new TTL from now on.
+func (self *Lease) Extend(ctx context.Context, ttl time.Duration) error {
+	if err := self.Err(); err != nil {
+		return err
+	}
+
+	ok, err := self.locker.Store.Extend(ctx, self.Name, self.Token, ttl)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		self.end(ErrLockLost)
+		return ErrLockLost
+	}
+
+	self.mu.Lock()
+	self.ttl = ttl
+	self.mu.Unlock()
+	return nil
+}
+
+// Done is closed when the lease is unlocked or lost.
+func (self *Lease) Done() <-chan struct{} {
+	return self.done
+}
+
+// Err returns ErrLockLost if the lock has expired or been taken over,
+// or ErrLockReleased after Unlock.
+func (self *Lease) Err() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.err
+}
+
+func (self *Lease) end(err error) {
+	self.once.Do(func() {
+		if err == nil {
+			err = ErrLockReleased
+		}
+
+		self.mu.Lock()
+		self.err = err
+		self.mu.Unlock()
+
+		self.halt()
+		close(self.done)
+	})
+}
+
+// halt stops the renewal without waiting for it.
+func (self *Lease) halt() {
+	self.stopOnce.Do(func() {
+		close(self.stop)
+	})
+}
+
+// renew extends the lock at a third of its TTL,
+// the lease is lost if it could not be extended within the TTL.
+func (self *Lease) renew() {
+	defer close(self.renewing)
+
+	clock := self.locker.Clock
+	renewed := clock.Now()
+	for {
+		self.mu.Lock()
+		ttl := self.ttl
+		self.mu.Unlock()
+
+		select {
+		case <-self.stop:
+			return
+		case <-clock.After(ttl / 3):
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), defaultLockTimeout)
+		ok, err := self.locker.Store.Extend(ctx, self.Name, self.Token, ttl)
+		cancel()
+
+		switch {
+		case err == nil && ok:
+			renewed = clock.Now()
+		case err == nil:
+			self.locker.Logger.Warn("cache: lock lost", "lock", self.Name)
+			self.end(ErrLockLost)
+			return
+		case clock.Now().Sub(renewed) >= ttl:
+			self.locker.Logger.Error("cache: lock expired while renewing", "lock", self.Name, "error", err)
+			self.end(ErrLockLost)
+			return
+		default:
+			self.locker.Logger.Warn("cache: lock renewal failed", "lock", self.Name, "error", err)
+		}
+	}
+}
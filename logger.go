@@ -0,0 +1,44 @@
+package cacher
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+type (
+	// Logger is the structured logger used by the cachers.
+	// *slog.Logger satisfies it, args are alternating key/value pairs.
+	Logger interface {
+		Debug(msg string, args ...any)
+		Info(msg string, args ...any)
+		Warn(msg string, args ...any)
+		Error(msg string, args ...any)
+	}
+
+	loggerHolder struct {
+		Logger
+	}
+)
+
+var defaultLogger atomic.Value
+
+// DefaultLogger returns the logger for cachers which are created without WithLogger.
+// it is slog.Default() unless SetDefaultLogger is called.
+func DefaultLogger() Logger {
+	if holder, ok := defaultLogger.Load().(loggerHolder); ok && holder.Logger != nil {
+		return holder.Logger
+	}
+	return slog.Default()
+}
+
+// SetDefaultLogger replaces the default logger,nil restore slog.Default().
+func SetDefaultLogger(logger Logger) {
+	defaultLogger.Store(loggerHolder{logger})
+}
+
+// WithLogger sets the logger of a cacher.
+func WithLogger(logger Logger) Option {
+	return func(cfg *Config) {
+		cfg.SetByField("logger", logger)
+	}
+}
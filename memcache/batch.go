@@ -0,0 +1,131 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/volts-dev/cacher"
+)
+
+// pipeline writes the quiet commands of the keys followed by "mn" to their servers,
+// one round trip per server,and passes the responses before "MN" to read.
+// write gets the index of the key in keys.
+func (self *MemcacheCache) pipeline(ctx context.Context, keys []string, write func(c *conn, i int, key, b64 string), read func(r *response) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	groups := make(map[*server][]int)
+	for i, key := range keys {
+		s, err := self.pick(key)
+		if err != nil {
+			return err
+		}
+		groups[s] = append(groups[s], i)
+	}
+
+	for s, idx := range groups {
+		err := s.do(ctx, func(c *conn) error {
+			for _, i := range idx {
+				k, b64, err := encodeKey(keys[i])
+				if err != nil {
+					return err
+				}
+				write(c, i, k, b64)
+			}
+			c.writeLine("mn")
+			if err := c.w.Flush(); err != nil {
+				return err
+			}
+
+			var first error
+			for {
+				r, err := c.readResponse()
+				if err != nil {
+					return err
+				}
+				if r.code == "MN" {
+					return first
+				}
+				if err := read(r); err != nil && first == nil {
+					first = err
+				}
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.addr, err)
+		}
+	}
+	return nil
+}
+
+// GetMulti reads the keys in one round trip per server,missing keys are omitted.
+func (self *MemcacheCache) GetMulti(keys []string, ctx ...context.Context) (map[string]any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	values := make(map[string]any, len(keys))
+	err := self.pipeline(cacher.Context(ctx...), keys, func(c *conn, i int, key, b64 string) {
+		c.writeLine("mg", key+b64, "v", "k", "q")
+	}, func(r *response) error {
+		if r.code != "VA" {
+			return fmt.Errorf("cache: memcache unexpected response %s", r.code)
+		}
+
+		key, err := decodeKey(r)
+		if err != nil {
+			return err
+		}
+
+		var value any
+		if err := self.config.Unmarshal(r.value, &value); err != nil {
+			return err
+		}
+		values[key] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// SetMulti writes the blocks in one round trip per server,
+// the blocks which are not stored by their SetOnlyNew or SetOnlyExist are skipped.
+func (self *MemcacheCache) SetMulti(blocks ...*cacher.CacheBlock) error {
+	if !self.config.Active || len(blocks) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(blocks))
+	values := make([][]byte, len(blocks))
+	for i, block := range blocks {
+		b, err := self.config.Marshal(block.Value)
+		if err != nil {
+			return err
+		}
+		keys[i], values[i] = block.Key, b
+	}
+
+	return self.pipeline(blocks[0].Context(), keys, func(c *conn, i int, key, b64 string) {
+		c.writeSet(key, b64, values[i], append(self.setFlags(blocks[i]), "q")...)
+	}, func(r *response) error {
+		if r.code != "NS" {
+			return fmt.Errorf("cache: memcache unexpected response %s", r.code)
+		}
+		return nil
+	})
+}
+
+// DeleteMulti deletes the keys in one round trip per server.
+func (self *MemcacheCache) DeleteMulti(keys []string, ctx ...context.Context) error {
+	return self.pipeline(cacher.Context(ctx...), keys, func(c *conn, i int, key, b64 string) {
+		c.writeLine("md", key+b64, "q")
+	}, func(r *response) error {
+		if r.code != "NF" {
+			return fmt.Errorf("cache: memcache unexpected response %s", r.code)
+		}
+		return nil
+	})
+}
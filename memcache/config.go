@@ -0,0 +1,61 @@
+package memcache
+
+import (
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+type (
+	MarshalFunc   func(interface{}) ([]byte, error)
+	UnmarshalFunc func([]byte, interface{}) error
+
+	Option func(*Config)
+
+	Config struct {
+		cacher.Config
+		Active    bool
+		Servers   []string
+		Replicas  int           // points of every server on the hash ring
+		Timeout   time.Duration // of dialing and every command
+		MaxIdle   int           `field:"max_idle"` // idle connections kept per server
+		Marshal   MarshalFunc
+		Unmarshal UnmarshalFunc
+		Logger    cacher.Logger
+		Clock     cacher.Clock
+		// UpdateRetries limits the CAS loop of Update.
+		UpdateRetries int `field:"update_retries"`
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+// WithServers sets the addresses of the memcached servers.
+func WithServers(addrs ...string) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("servers", addrs)
+	}
+}
+
+// WithTimeout limits dialing and every command.
+func WithTimeout(timeout time.Duration) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("timeout", timeout)
+	}
+}
+
+// WithMaxIdle sets how many idle connections are kept per server.
+func WithMaxIdle(n int) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("max_idle", n)
+	}
+}
+
+// WithUpdateRetries limits how many times Update retries a conflicting CAS.
+func WithUpdateRetries(n int) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("update_retries", n)
+	}
+}
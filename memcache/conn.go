@@ -0,0 +1,242 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+const maxKeyLen = 250
+
+var (
+	errClosed     = errors.New("cache: memcache is closed")
+	errNotStored  = errors.New("cache: memcache not stored")
+	errKeyTooLong = errors.New("cache: memcache key is too long")
+)
+
+type (
+	// server is a pool of the connections to one memcached.
+	server struct {
+		addr    string
+		timeout time.Duration
+		idle    chan *conn
+		done    chan struct{} // closed by close
+	}
+
+	conn struct {
+		net.Conn
+		r *bufio.Reader
+		w *bufio.Writer
+	}
+
+	// response is a meta response line like "VA 5 c12 t-1".
+	response struct {
+		code  string
+		size  int
+		flags []string
+		value []byte
+	}
+)
+
+func newServer(addr string, timeout time.Duration, maxIdle int) *server {
+	return &server{
+		addr:    addr,
+		timeout: timeout,
+		idle:    make(chan *conn, maxIdle),
+		done:    make(chan struct{}),
+	}
+}
+
+func (self *server) get(ctx context.Context) (*conn, error) {
+	select {
+	case c := <-self.idle:
+		return c, nil
+	default:
+	}
+
+	d := net.Dialer{Timeout: self.timeout}
+	nc, err := d.DialContext(ctx, "tcp", self.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}, nil
+}
+
+func (self *server) put(c *conn) {
+	select {
+	case <-self.done:
+		c.Close()
+		return
+	default:
+	}
+
+	select {
+	case self.idle <- c:
+	default:
+		c.Close()
+	}
+}
+
+// do runs fn on a pooled connection,the connection is dropped
+// if fn fails in a way which may leave unread responses.
+func (self *server) do(ctx context.Context, fn func(c *conn) error) error {
+	c, err := self.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(self.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.SetDeadline(deadline)
+
+	err = fn(c)
+	if err == nil || errors.Is(err, cacher.ErrCacheMiss) || errors.Is(err, cacher.ErrVersionMismatch) || errors.Is(err, errNotStored) {
+		self.put(c)
+	} else {
+		c.Close()
+	}
+	return err
+}
+
+func (self *server) close() {
+	close(self.done)
+	for {
+		select {
+		case c := <-self.idle:
+			c.Close()
+		default:
+			return
+		}
+	}
+}
+
+// encodeKey returns the key and "b" if it must be sent in base64,
+// the meta protocol does not allow spaces or control characters in a plain key.
+func encodeKey(key string) (string, string, error) {
+	plain := len(key) > 0 && len(key) <= maxKeyLen
+	for i := 0; plain && i < len(key); i++ {
+		if key[i] <= ' ' || key[i] >= 0x7f {
+			plain = false
+		}
+	}
+	if plain {
+		return key, "", nil
+	}
+
+	b64 := base64.StdEncoding.EncodeToString([]byte(key))
+	if len(b64) > maxKeyLen {
+		return "", "", fmt.Errorf("%w: %d", errKeyTooLong, len(key))
+	}
+	return b64, " b", nil
+}
+
+// decodeKey restores a key returned by the k flag.
+func decodeKey(r *response) (string, error) {
+	key, b64 := "", false
+	for _, f := range r.flags {
+		switch {
+		case f == "b":
+			b64 = true
+		case f[0] == 'k':
+			key = f[1:]
+		}
+	}
+
+	if !b64 {
+		return key, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(key)
+	return string(b), err
+}
+
+// ttlFlag returns the T flag of the block,memcached treats a TTL over 30 days as a unix time.
+func ttlFlag(ttl time.Duration, now time.Time) string {
+	if ttl <= 0 {
+		return "T0"
+	}
+
+	secs := int64((ttl + time.Second - 1) / time.Second)
+	if secs > 30*24*3600 {
+		secs = now.Add(ttl).Unix()
+	}
+	return "T" + strconv.FormatInt(secs, 10)
+}
+
+func (self *conn) writeLine(parts ...string) {
+	for i, p := range parts {
+		if i > 0 {
+			self.w.WriteByte(' ')
+		}
+		self.w.WriteString(p)
+	}
+	self.w.WriteString("\r\n")
+}
+
+// writeSet writes a ms command without flushing,the flags follow the length of the value.
+func (self *conn) writeSet(key, b64 string, value []byte, flags ...string) {
+	self.writeLine(append([]string{"ms", key, strconv.Itoa(len(value)) + b64}, flags...)...)
+	self.w.Write(value)
+	self.w.WriteString("\r\n")
+}
+
+// readResponse reads a response line and the value of a VA response.
+func (self *conn) readResponse() (*response, error) {
+	line, err := self.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("cache: memcache empty response")
+	}
+
+	r := &response{code: fields[0], flags: fields[1:]}
+	switch r.code {
+	case "VA":
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("cache: memcache bad response %q", line)
+		}
+		if r.size, err = strconv.Atoi(fields[1]); err != nil {
+			return nil, fmt.Errorf("cache: memcache bad response %q", line)
+		}
+		r.flags = fields[2:]
+
+		r.value = make([]byte, r.size+2)
+		if _, err := io.ReadFull(self.r, r.value); err != nil {
+			return nil, err
+		}
+		r.value = r.value[:r.size]
+
+	case "ERROR", "CLIENT_ERROR", "SERVER_ERROR":
+		return nil, fmt.Errorf("cache: memcache %s", strings.TrimSpace(line))
+	}
+	return r, nil
+}
+
+// flag returns the value of the flag in the response.
+func (self *response) flag(name byte) (string, bool) {
+	for _, f := range self.flags {
+		if f[0] == name {
+			return f[1:], true
+		}
+	}
+	return "", false
+}
+
+func (self *response) cas() uint64 {
+	v, _ := self.flag('c')
+	n, _ := strconv.ParseUint(v, 10, 64)
+	return n
+}
@@ -0,0 +1,398 @@
+// Package memcache keeps the caches in a fleet of memcached servers,
+// the keys are spread over the servers by a consistent hash ring.
+//
+// the data commands use the meta protocol (mg/ms/md) of memcached 1.6
+// which supports CAS and TTL,the values are encoded like the redis adapter.
+// Keys and Clear use the text protocol and visit every server.
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/internal/codec"
+	"github.com/volts-dev/cacher/internal/hashring"
+)
+
+var Memcache = cacher.Register("Memcache", func() cacher.ICacher {
+	return New()
+})
+
+type MemcacheCache struct {
+	sync.RWMutex
+	config  *Config
+	ring    *hashring.Ring
+	servers map[string]*server // by address
+	closed  bool
+}
+
+// New returns a memcache cacher,the connections are dialed on demand.
+func New(opts ...cacher.Option) *MemcacheCache {
+	cfg := &Config{
+		Active:  true,
+		Servers: []string{"127.0.0.1:11211"},
+		Timeout: time.Second,
+		MaxIdle: 8,
+	}
+	cfg.Init(opts...)
+
+	if cfg.Logger == nil {
+		cfg.Logger = cacher.DefaultLogger()
+	}
+
+	if cfg.Clock == nil {
+		cfg.Clock = cacher.SystemClock
+	}
+
+	if cfg.Marshal == nil {
+		cfg.Marshal = codec.Marshal
+	}
+
+	if cfg.Unmarshal == nil {
+		cfg.Unmarshal = codec.Unmarshal
+	}
+
+	c := &MemcacheCache{config: cfg}
+	c.connect()
+	return c
+}
+
+// connect rebuilds the ring and the pools from the config.
+func (self *MemcacheCache) connect() {
+	if !self.closed {
+		for _, s := range self.servers {
+			s.close()
+		}
+	}
+
+	self.servers = make(map[string]*server, len(self.config.Servers))
+	self.ring = hashring.New(self.config.Replicas)
+	for _, addr := range self.config.Servers {
+		self.servers[addr] = newServer(addr, self.config.Timeout, self.config.MaxIdle)
+		self.ring.Add(addr, 1)
+	}
+}
+
+func (self *MemcacheCache) Init(opts ...cacher.Option) {
+	self.Lock()
+	defer self.Unlock()
+
+	self.config.Init(opts...)
+	self.connect()
+}
+
+func (self *MemcacheCache) String() string {
+	return "memcache"
+}
+
+func (self *MemcacheCache) Active(on ...bool) bool {
+	if len(on) > 0 {
+		self.config.Active = on[0]
+	}
+
+	return self.config.Active
+}
+
+// pick returns the server of the key.
+func (self *MemcacheCache) pick(key string) (*server, error) {
+	self.RLock()
+	defer self.RUnlock()
+
+	if self.closed {
+		return nil, errClosed
+	}
+
+	addr, ok := self.ring.Get(key)
+	if !ok {
+		return nil, fmt.Errorf("cache: memcache has no servers")
+	}
+	return self.servers[addr], nil
+}
+
+// do sends a single command to the server of the key and reads its response.
+func (self *MemcacheCache) do(ctx context.Context, key string, fn func(c *conn, key, b64 string) (*response, error)) (*response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s, err := self.pick(key)
+	if err != nil {
+		return nil, err
+	}
+
+	k, b64, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var r *response
+	err = s.do(ctx, func(c *conn) error {
+		var err error
+		r, err = fn(c, k, b64)
+		return err
+	})
+	return r, err
+}
+
+// get sends "mg <key> <flags>",a miss returns cacher.ErrCacheMiss.
+func (self *MemcacheCache) get(ctx context.Context, key string, flags ...string) (*response, error) {
+	return self.do(ctx, key, func(c *conn, k, b64 string) (*response, error) {
+		c.writeLine(append([]string{"mg", k + b64}, flags...)...)
+		if err := c.w.Flush(); err != nil {
+			return nil, err
+		}
+
+		r, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+
+		switch r.code {
+		case "VA", "HD":
+			return r, nil
+		case "EN":
+			return nil, cacher.ErrCacheMiss
+		}
+		return nil, fmt.Errorf("cache: memcache unexpected response %s", r.code)
+	})
+}
+
+// set sends "ms <key> <len> <flags>",a value which is not stored returns errNotStored
+// and a CAS conflict returns cacher.ErrVersionMismatch.
+func (self *MemcacheCache) set(ctx context.Context, key string, value []byte, flags ...string) (*response, error) {
+	return self.do(ctx, key, func(c *conn, k, b64 string) (*response, error) {
+		c.writeSet(k, b64, value, flags...)
+		if err := c.w.Flush(); err != nil {
+			return nil, err
+		}
+
+		r, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+
+		switch r.code {
+		case "HD":
+			return r, nil
+		case "NS":
+			return nil, errNotStored
+		case "EX", "NF":
+			return nil, cacher.ErrVersionMismatch
+		}
+		return nil, fmt.Errorf("cache: memcache unexpected response %s", r.code)
+	})
+}
+
+// setFlags returns the flags of ms for the block.
+func (self *MemcacheCache) setFlags(block *cacher.CacheBlock) []string {
+	mode := "MS"
+	switch {
+	case block.SetOnlyNew:
+		mode = "ME"
+	case block.SetOnlyExist:
+		mode = "MR"
+	}
+	return []string{ttlFlag(block.Ttl(), self.config.Clock.Now()), mode}
+}
+
+func (self *MemcacheCache) Get(key string, ctx ...context.Context) (any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	r, err := self.get(cacher.Context(ctx...), key, "v")
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	if err := self.config.Unmarshal(r.value, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Set stores the block,SetOnlyNew and SetOnlyExist are atomic on the server.
+func (self *MemcacheCache) Set(block *cacher.CacheBlock) error {
+	if !self.config.Active {
+		return nil
+	}
+
+	value, err := self.config.Marshal(block.Value)
+	if err != nil {
+		return err
+	}
+
+	_, err = self.set(block.Context(), block.Key, value, self.setFlags(block)...)
+	if errors.Is(err, errNotStored) {
+		return nil
+	}
+	return err
+}
+
+func (self *MemcacheCache) Exists(key string, ctx ...context.Context) bool {
+	if !self.config.Active {
+		return false
+	}
+
+	_, err := self.get(cacher.Context(ctx...), key)
+	return err == nil
+}
+
+// delete sends "md <key> <flags>",a missing key is not an error.
+func (self *MemcacheCache) delete(ctx context.Context, key string, flags ...string) error {
+	_, err := self.do(ctx, key, func(c *conn, k, b64 string) (*response, error) {
+		c.writeLine(append([]string{"md", k + b64}, flags...)...)
+		if err := c.w.Flush(); err != nil {
+			return nil, err
+		}
+
+		r, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+
+		switch r.code {
+		case "HD", "NF":
+			return r, nil
+		case "EX":
+			return nil, cacher.ErrVersionMismatch
+		}
+		return nil, fmt.Errorf("cache: memcache unexpected response %s", r.code)
+	})
+	return err
+}
+
+func (self *MemcacheCache) Delete(key string, ctx ...context.Context) error {
+	return self.delete(cacher.Context(ctx...), key)
+}
+
+// each runs fn on a connection to every server.
+func (self *MemcacheCache) each(ctx context.Context, fn func(c *conn) error) error {
+	self.RLock()
+	servers := make([]*server, 0, len(self.servers))
+	for _, addr := range self.ring.Nodes() {
+		servers = append(servers, self.servers[addr])
+	}
+	closed := self.closed
+	self.RUnlock()
+
+	if closed {
+		return errClosed
+	}
+
+	for _, s := range servers {
+		if err := s.do(ctx, fn); err != nil {
+			return fmt.Errorf("%s: %w", s.addr, err)
+		}
+	}
+	return nil
+}
+
+// Keys lists the keys of every server by "lru_crawler metadump all",
+// memcached 1.4.31 or later is required.
+func (self *MemcacheCache) Keys(ctx ...context.Context) []string {
+	if !self.config.Active {
+		return nil
+	}
+
+	now := self.config.Clock.Now().Unix()
+	var keys []string
+	err := self.each(cacher.Context(ctx...), func(c *conn) error {
+		c.writeLine("lru_crawler", "metadump", "all")
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+
+		for {
+			line, err := c.r.ReadString('\n')
+			if err != nil {
+				return err
+			}
+
+			line = strings.TrimSpace(line)
+			switch {
+			case line == "END":
+				return nil
+			case strings.HasPrefix(line, "ERROR"), strings.HasPrefix(line, "BUSY"):
+				return fmt.Errorf("cache: memcache metadump %s", line)
+			}
+
+			if key, ok := parseMetadump(line, now); ok {
+				keys = append(keys, key)
+			}
+		}
+	})
+	if err != nil {
+		self.config.Logger.Error("cache: memcache keys failed", "error", err)
+	}
+	return keys
+}
+
+// parseMetadump parses a line like "key=foo exp=-1 la=1700000000 cas=2 fetch=no cls=1 size=63".
+func parseMetadump(line string, now int64) (string, bool) {
+	var key string
+	for _, field := range strings.Fields(line) {
+		name, value, _ := strings.Cut(field, "=")
+		switch name {
+		case "key":
+			k, err := url.QueryUnescape(value)
+			if err != nil {
+				return "", false
+			}
+			key = k
+		case "exp":
+			exp, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || (exp >= 0 && exp <= now) {
+				return "", false
+			}
+		}
+	}
+	return key, key != ""
+}
+
+func (self *MemcacheCache) Len() int {
+	return len(self.Keys())
+}
+
+// Clear flushes every server,the keys of other clients are removed too.
+func (self *MemcacheCache) Clear() error {
+	return self.each(context.Background(), func(c *conn) error {
+		c.writeLine("flush_all")
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if line = strings.TrimSpace(line); line != "OK" {
+			return fmt.Errorf("cache: memcache flush_all %s", line)
+		}
+		return nil
+	})
+}
+
+// Close drops the idle connections,the later operations return an error.
+func (self *MemcacheCache) Close() error {
+	self.Lock()
+	defer self.Unlock()
+
+	if self.closed {
+		return nil
+	}
+	self.closed = true
+
+	for _, s := range self.servers {
+		s.close()
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/cachertest"
+	"github.com/volts-dev/cacher/memcache/memcachetest"
+)
+
+// cluster starts n fake servers sharing the clock.
+func cluster(t *testing.T, n int, clock cacher.Clock) (*MemcacheCache, []*memcachetest.Server) {
+	srvs := make([]*memcachetest.Server, n)
+	addrs := make([]string, n)
+	for i := range srvs {
+		srvs[i] = memcachetest.Run(t)
+		srvs[i].SetClock(clock)
+		addrs[i] = srvs[i].Addr()
+	}
+	return New(WithServers(addrs...), cacher.WithClock(clock)), srvs
+}
+
+func TestConformance(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	cachertest.Run(t, func(t *testing.T) cacher.ICacher {
+		chr, _ := cluster(t, 3, clock)
+		return chr
+	}, cachertest.WithSleep(clock.Advance))
+}
+
+func TestDistribution(t *testing.T) {
+	chr, srvs := cluster(t, 3, cacher.SystemClock)
+	defer chr.Close()
+
+	for i := 0; i < 100; i++ {
+		chr.Set(&cacher.CacheBlock{Key: fmt.Sprintf("key%d", i), Value: i})
+	}
+
+	total := 0
+	for i, srv := range srvs {
+		n := len(srv.Keys())
+		if n == 0 {
+			t.Fatalf("server %d owns no keys", i)
+		}
+		total += n
+	}
+	if total != 100 {
+		t.Fatalf("%d keys on the servers", total)
+	}
+}
+
+func TestBinaryKey(t *testing.T) {
+	chr, srvs := cluster(t, 1, cacher.SystemClock)
+	defer chr.Close()
+
+	key := "with space\n和换行"
+	if err := chr.Set(&cacher.CacheBlock{Key: key, Value: "v"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := srvs[0].Get(key); !ok {
+		t.Fatal("key is not decoded by the server")
+	}
+
+	values, err := chr.GetMulti([]string{key, "missing"})
+	if err != nil || values[key] != "v" || len(values) != 1 {
+		t.Fatalf("get multi %v %v", values, err)
+	}
+
+	if keys := chr.Keys(); len(keys) != 1 || keys[0] != key {
+		t.Fatalf("keys %q", keys)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	chr, _ := cluster(t, 3, cacher.SystemClock)
+	defer chr.Close()
+
+	blocks := make([]*cacher.CacheBlock, 20)
+	keys := make([]string, len(blocks))
+	for i := range blocks {
+		keys[i] = fmt.Sprintf("key%d", i)
+		blocks[i] = &cacher.CacheBlock{Key: keys[i], Value: int64(i)}
+	}
+	blocks = append(blocks, &cacher.CacheBlock{Key: "key0", Value: int64(-1), SetOnlyNew: true})
+
+	if err := chr.SetMulti(blocks...); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := chr.GetMulti(append(keys, "missing"))
+	if err != nil || len(values) != len(keys) {
+		t.Fatalf("get multi %d %v", len(values), err)
+	}
+	for i, key := range keys {
+		if values[key] != int64(i) {
+			t.Fatalf("%s is %v", key, values[key])
+		}
+	}
+
+	if err := chr.DeleteMulti(append(keys[:10], "missing")); err != nil {
+		t.Fatal(err)
+	}
+	if n := chr.Len(); n != 10 {
+		t.Fatalf("len %d after delete multi", n)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	chr, _ := cluster(t, 1, clock)
+	defer chr.Close()
+
+	chr.Set(&cacher.CacheBlock{Key: "short", Value: "v", TTL: 10 * time.Second})
+	chr.Set(&cacher.CacheBlock{Key: "forever", Value: "v", TTL: -1})
+	chr.Set(&cacher.CacheBlock{Key: "long", Value: "v", TTL: 60 * 24 * time.Hour})
+
+	if ttl, err := chr.TTL("short"); err != nil || ttl != 10*time.Second {
+		t.Fatalf("ttl of short %v %v", ttl, err)
+	}
+	if ttl, _ := chr.TTL("forever"); ttl != -1 {
+		t.Fatalf("ttl of forever %v", ttl)
+	}
+	// 超过30天的TTL以unix时间发送
+	if ttl, _ := chr.TTL("long"); ttl < 59*24*time.Hour || ttl > 60*24*time.Hour+time.Second {
+		t.Fatalf("ttl of long %v", ttl)
+	}
+
+	if err := chr.Expire("short", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(30 * time.Second)
+	if !chr.Exists("short") {
+		t.Fatal("short is expired after expire")
+	}
+
+	if err := chr.Expire("missing", time.Minute); err != cacher.ErrCacheMiss {
+		t.Fatalf("expire missing %v", err)
+	}
+}
@@ -0,0 +1,506 @@
+// Package memcachetest provides an in-process memcached for hermetic tests of the memcache adapter.
+//
+// usage:
+//
+//	srv := memcachetest.Run(t)
+//	chr := memcache.New(memcache.WithServers(srv.Addr()))
+package memcachetest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+const maxRelativeTTL = 30 * 24 * 3600
+
+type (
+	item struct {
+		value    []byte
+		expireAt time.Time // zero means never expire
+		cas      uint64
+	}
+
+	// Server is a tiny memcached which keeps the items in memory.
+	// it speaks the meta commands mg/ms/md/mn and flush_all,version
+	// and "lru_crawler metadump all" of the text protocol.
+	Server struct {
+		sync.Mutex
+		ln     net.Listener
+		items  map[string]*item
+		conns  map[net.Conn]struct{}
+		wg     sync.WaitGroup
+		closed bool
+		clock  cacher.Clock
+		cas    uint64
+	}
+
+	// request is a parsed meta command.
+	request struct {
+		key   string
+		b64   bool
+		flags []string
+	}
+)
+
+// NewServer starts a server on a random local port.
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		ln:    ln,
+		items: make(map[string]*item),
+		conns: make(map[net.Conn]struct{}),
+		clock: cacher.SystemClock,
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Run starts a server which is closed when the test finishs.
+func Run(t testing.TB) *Server {
+	t.Helper()
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Addr returns the address to dial.
+func (self *Server) Addr() string {
+	return self.ln.Addr().String()
+}
+
+// Close stops the server and drops all connections.
+func (self *Server) Close() {
+	self.Lock()
+	if self.closed {
+		self.Unlock()
+		return
+	}
+	self.closed = true
+	self.ln.Close()
+	for c := range self.conns {
+		c.Close()
+	}
+	self.Unlock()
+
+	self.wg.Wait()
+}
+
+// SetClock replaces the clock which expires the items.
+func (self *Server) SetClock(clock cacher.Clock) {
+	self.Lock()
+	self.clock = clock
+	self.Unlock()
+}
+
+// Keys returns all keys which are not expired in order.
+func (self *Server) Keys() []string {
+	self.Lock()
+	defer self.Unlock()
+
+	keys := make([]string, 0, len(self.items))
+	for key := range self.items {
+		if self.lookup(key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns the raw value of the key.
+func (self *Server) Get(key string) ([]byte, bool) {
+	self.Lock()
+	defer self.Unlock()
+
+	if it := self.lookup(key); it != nil {
+		return it.value, true
+	}
+	return nil, false
+}
+
+// lookup returns the item and drops it if it is expired.
+func (self *Server) lookup(key string) *item {
+	it, has := self.items[key]
+	if !has {
+		return nil
+	}
+
+	if !it.expireAt.IsZero() && !self.clock.Now().Before(it.expireAt) {
+		delete(self.items, key)
+		return nil
+	}
+	return it
+}
+
+// expireAt converts a T flag like memcached,over 30 days is a unix time.
+func (self *Server) expireAt(ttl int64) time.Time {
+	switch {
+	case ttl == 0:
+		return time.Time{}
+	case ttl < 0:
+		return self.clock.Now()
+	case ttl > maxRelativeTTL:
+		return time.Unix(ttl, 0)
+	}
+	return self.clock.Now().Add(time.Duration(ttl) * time.Second)
+}
+
+func (self *Server) serve() {
+	defer self.wg.Done()
+	for {
+		conn, err := self.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		self.Lock()
+		if self.closed {
+			self.Unlock()
+			conn.Close()
+			return
+		}
+		self.conns[conn] = struct{}{}
+		self.Unlock()
+
+		self.wg.Add(1)
+		go self.handle(conn)
+	}
+}
+
+func (self *Server) handle(conn net.Conn) {
+	defer self.wg.Done()
+	defer func() {
+		self.Lock()
+		delete(self.conns, conn)
+		self.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			w.WriteString("ERROR\r\n")
+		} else if err := self.exec(r, w, fields); err != nil {
+			return
+		}
+
+		// 流水线中的请求一起回复
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// exec runs a command,an error means the connection must be closed.
+func (self *Server) exec(r *bufio.Reader, w *bufio.Writer, fields []string) error {
+	switch fields[0] {
+	case "mg":
+		req, err := parseRequest(fields[1:])
+		if err != nil {
+			return clientError(w, err)
+		}
+		self.metaGet(w, req)
+
+	case "ms":
+		if len(fields) < 3 {
+			return clientError(w, fmt.Errorf("bad command line format"))
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil || size < 0 {
+			return clientError(w, fmt.Errorf("bad data chunk"))
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		if string(data[size:]) != "\r\n" {
+			return clientError(w, fmt.Errorf("bad data chunk"))
+		}
+
+		req, err := parseRequest(append(fields[1:2], fields[3:]...))
+		if err != nil {
+			return clientError(w, err)
+		}
+		self.metaSet(w, req, data[:size])
+
+	case "md":
+		req, err := parseRequest(fields[1:])
+		if err != nil {
+			return clientError(w, err)
+		}
+		self.metaDelete(w, req)
+
+	case "mn":
+		w.WriteString("MN\r\n")
+
+	case "flush_all":
+		self.Lock()
+		self.items = make(map[string]*item)
+		self.Unlock()
+		w.WriteString("OK\r\n")
+
+	case "version":
+		w.WriteString("VERSION 1.6.0-memcachetest\r\n")
+
+	case "lru_crawler":
+		if len(fields) != 3 || fields[1] != "metadump" || fields[2] != "all" {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		self.metadump(w)
+
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return nil
+}
+
+// clientError replies CLIENT_ERROR,memcached closes the connection after it.
+func clientError(w *bufio.Writer, err error) error {
+	fmt.Fprintf(w, "CLIENT_ERROR %v\r\n", err)
+	w.Flush()
+	return err
+}
+
+func parseRequest(fields []string) (*request, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("bad command line format")
+	}
+
+	req := &request{key: fields[0], flags: fields[1:]}
+	for _, f := range req.flags {
+		if f == "b" {
+			req.b64 = true
+		}
+	}
+
+	if req.b64 {
+		b, err := base64.StdEncoding.DecodeString(req.key)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding key")
+		}
+		req.key = string(b)
+	}
+
+	if len(req.key) == 0 || len(req.key) > 250 {
+		return nil, fmt.Errorf("bad command line format")
+	}
+	return req, nil
+}
+
+// flag returns the token of the flag.
+func (self *request) flag(name byte) (string, bool) {
+	for _, f := range self.flags {
+		if f[0] == name {
+			return f[1:], true
+		}
+	}
+	return "", false
+}
+
+func (self *request) has(name byte) bool {
+	_, ok := self.flag(name)
+	return ok
+}
+
+func (self *request) number(name byte) (int64, bool) {
+	v, ok := self.flag(name)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	return n, err == nil
+}
+
+// retFlags returns the flags which are echoed or requested by the command.
+func (self *Server) retFlags(req *request, it *item) string {
+	var b strings.Builder
+	for _, f := range req.flags {
+		switch f[0] {
+		case 'c':
+			fmt.Fprintf(&b, " c%d", it.cas)
+		case 't':
+			ttl := int64(-1)
+			if !it.expireAt.IsZero() {
+				ttl = int64((it.expireAt.Sub(self.clock.Now()) + time.Second - 1) / time.Second)
+			}
+			fmt.Fprintf(&b, " t%d", ttl)
+		case 'k':
+			key := req.key
+			if req.b64 {
+				key = base64.StdEncoding.EncodeToString([]byte(key))
+			}
+			b.WriteString(" k" + key)
+		case 'b':
+			if req.has('k') {
+				b.WriteString(" b")
+			}
+		case 'O':
+			b.WriteString(" " + f)
+		}
+	}
+	return b.String()
+}
+
+func (self *Server) metaGet(w *bufio.Writer, req *request) {
+	self.Lock()
+	defer self.Unlock()
+
+	it := self.lookup(req.key)
+	if it == nil {
+		if !req.has('q') {
+			w.WriteString("EN\r\n")
+		}
+		return
+	}
+
+	if ttl, ok := req.number('T'); ok {
+		it.expireAt = self.expireAt(ttl)
+	}
+
+	if req.has('v') {
+		fmt.Fprintf(w, "VA %d%s\r\n", len(it.value), self.retFlags(req, it))
+		w.Write(it.value)
+		w.WriteString("\r\n")
+		return
+	}
+	fmt.Fprintf(w, "HD%s\r\n", self.retFlags(req, it))
+}
+
+func (self *Server) metaSet(w *bufio.Writer, req *request, data []byte) {
+	self.Lock()
+	defer self.Unlock()
+
+	reply := func(code string, it *item) {
+		if code == "HD" && req.has('q') {
+			return
+		}
+		if it != nil {
+			code += self.retFlags(req, it)
+		}
+		w.WriteString(code + "\r\n")
+	}
+
+	it := self.lookup(req.key)
+	mode, _ := req.flag('M')
+	switch mode {
+	case "", "S", "s":
+	case "E", "e":
+		if it != nil {
+			reply("NS", nil)
+			return
+		}
+	case "R", "r":
+		if it == nil {
+			reply("NS", nil)
+			return
+		}
+	case "A", "a", "P", "p":
+		if it == nil {
+			reply("NS", nil)
+			return
+		}
+		if mode == "A" || mode == "a" {
+			data = append(append([]byte{}, it.value...), data...)
+		} else {
+			data = append(append([]byte{}, data...), it.value...)
+		}
+	default:
+		reply("CLIENT_ERROR invalid mode for ms", nil)
+		return
+	}
+
+	if cas, ok := req.number('C'); ok {
+		if it == nil {
+			reply("NF", nil)
+			return
+		}
+		if uint64(cas) != it.cas {
+			reply("EX", nil)
+			return
+		}
+	}
+
+	ttl, _ := req.number('T')
+	self.cas++
+	it = &item{value: append([]byte{}, data...), expireAt: self.expireAt(ttl), cas: self.cas}
+	self.items[req.key] = it
+	reply("HD", it)
+}
+
+func (self *Server) metaDelete(w *bufio.Writer, req *request) {
+	self.Lock()
+	defer self.Unlock()
+
+	quiet := req.has('q')
+	it := self.lookup(req.key)
+	if it == nil {
+		if !quiet {
+			w.WriteString("NF\r\n")
+		}
+		return
+	}
+
+	if cas, ok := req.number('C'); ok && uint64(cas) != it.cas {
+		w.WriteString("EX\r\n")
+		return
+	}
+
+	delete(self.items, req.key)
+	if !quiet {
+		w.WriteString("HD\r\n")
+	}
+}
+
+// metadump lists the items like memcached,the keys are URI encoded.
+func (self *Server) metadump(w *bufio.Writer) {
+	self.Lock()
+	defer self.Unlock()
+
+	now := self.clock.Now()
+	for key := range self.items {
+		it := self.lookup(key)
+		if it == nil {
+			continue
+		}
+
+		exp := int64(-1)
+		if !it.expireAt.IsZero() {
+			exp = it.expireAt.Unix()
+		}
+		fmt.Fprintf(w, "key=%s exp=%d la=%d cas=%d fetch=no cls=1 size=%d\n",
+			url.QueryEscape(key), exp, now.Unix(), it.cas, len(key)+len(it.value))
+	}
+	w.WriteString("END\r\n")
+}
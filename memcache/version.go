@@ -0,0 +1,136 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+const (
+	defaultUpdateRetries = 16
+	updateBackoff        = time.Millisecond
+)
+
+// GetWithVersion returns the value and its CAS of the server.
+func (self *MemcacheCache) GetWithVersion(key string, ctx ...context.Context) (any, uint64, error) {
+	if !self.config.Active {
+		return nil, 0, cacher.ErrInactive
+	}
+
+	r, err := self.get(cacher.Context(ctx...), key, "v", "c")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var value any
+	if err := self.config.Unmarshal(r.value, &value); err != nil {
+		return nil, 0, err
+	}
+	return value, r.cas(), nil
+}
+
+// CompareAndSwap stores the block with the C flag of ms,or with the add mode if expected is 0.
+func (self *MemcacheCache) CompareAndSwap(ctx context.Context, key string, expected uint64, block *cacher.CacheBlock) error {
+	if !self.config.Active {
+		return cacher.ErrInactive
+	}
+
+	value, err := self.config.Marshal(block.Value)
+	if err != nil {
+		return err
+	}
+
+	flags := []string{ttlFlag(block.Ttl(), self.config.Clock.Now()), "c"}
+	if expected == 0 {
+		flags = append(flags, "ME")
+	} else {
+		flags = append(flags, "C"+strconv.FormatUint(expected, 10))
+	}
+
+	r, err := self.set(ctx, key, value, flags...)
+	if errors.Is(err, errNotStored) {
+		return cacher.ErrVersionMismatch
+	}
+	if err != nil {
+		return err
+	}
+
+	block.Version = r.cas()
+	return nil
+}
+
+// Update applies the function with a CAS loop and retries
+// when the key is changed by others meanwhile,the function may run several times.
+func (self *MemcacheCache) Update(ctx context.Context, key string, fn cacher.UpdateFunc) error {
+	if !self.config.Active {
+		return cacher.ErrInactive
+	}
+
+	retries := self.config.UpdateRetries
+	if retries <= 0 {
+		retries = defaultUpdateRetries
+	}
+
+	for i := 0; i < retries; i++ {
+		old, cas, err := self.GetWithVersion(key, ctx)
+		exists := err == nil
+		if err != nil && !errors.Is(err, cacher.ErrCacheMiss) {
+			return err
+		}
+
+		value, ttl, del := fn(old, exists)
+		switch {
+		case del && !exists:
+			return nil
+		case del:
+			err = self.delete(ctx, key, "C"+strconv.FormatUint(cas, 10))
+		default:
+			err = self.CompareAndSwap(ctx, key, cas, &cacher.CacheBlock{Key: key, Value: value, TTL: ttl})
+		}
+
+		if !errors.Is(err, cacher.ErrVersionMismatch) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(updateBackoff * time.Duration(i+1)):
+		}
+	}
+	return fmt.Errorf("cache: update %s gave up after %d retries: %w", key, retries, cacher.ErrVersionMismatch)
+}
+
+// TTL returns the remaining time of the key in seconds,-1 means never expire.
+func (self *MemcacheCache) TTL(key string, ctx ...context.Context) (time.Duration, error) {
+	r, err := self.get(cacher.Context(ctx...), key, "t")
+	if err != nil {
+		return 0, err
+	}
+
+	v, _ := r.flag('t')
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cache: memcache bad ttl %q", v)
+	}
+	if secs < 0 {
+		return -1, nil
+	}
+	return time.Duration(secs) * time.Second, nil
+}
+
+// Expire touches the key with the T flag of mg,a negative TTL means never expire.
+func (self *MemcacheCache) Expire(key string, ttl time.Duration, ctx ...context.Context) error {
+	if ttl < 0 {
+		ttl = 0
+	} else if ttl < time.Second {
+		ttl = time.Second // 最小精度是秒
+	}
+
+	_, err := self.get(cacher.Context(ctx...), key, ttlFlag(ttl, self.config.Clock.Now()))
+	return err
+}
@@ -0,0 +1,460 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+// SyncPolicy tells how often the append only file is synced to the disk.
+type SyncPolicy string
+
+const (
+	SyncAlways   SyncPolicy = "always"   // every write,the safest and slowest
+	SyncEverySec SyncPolicy = "everysec" // at most one second of writes are lost
+	SyncNever    SyncPolicy = "never"    // leave it to the OS
+)
+
+const (
+	aofSet byte = iota + 1
+	aofDelete
+	aofExpire
+	aofClear
+)
+
+const (
+	defaultAOFRewriteSize = 64 << 20
+	aofHeaderLen          = 8 // length and crc32 of the payload
+)
+
+var errAOFCorrupt = errors.New("cache: corrupt aof record")
+
+type (
+	// aof is the append only file,every record is framed by its length and checksum
+	// so a torn write at the end is detected and truncated on replay.
+	aof struct {
+		sync.Mutex
+		path       string
+		policy     SyncPolicy
+		f          *os.File
+		size       int64
+		base       int64 // size after the last rewrite
+		minRewrite int64
+		dirty      bool // written since the last sync
+		rewriting  bool
+		pending    [][]byte // records written while rewriting
+		closed     bool
+	}
+
+	aofRecord struct {
+		op    byte
+		key   string
+		value []byte
+		ttl   time.Duration
+		at    time.Time
+	}
+)
+
+func (self *aofRecord) encode() []byte {
+	payload := make([]byte, 0, 1+3*binary.MaxVarintLen64+len(self.key)+len(self.value))
+	payload = append(payload, self.op)
+	payload = binary.AppendVarint(payload, int64(self.ttl))
+	payload = binary.AppendVarint(payload, self.at.UnixNano())
+	payload = binary.AppendUvarint(payload, uint64(len(self.key)))
+	payload = append(payload, self.key...)
+	payload = append(payload, self.value...)
+
+	b := make([]byte, aofHeaderLen, aofHeaderLen+len(payload))
+	binary.LittleEndian.PutUint32(b, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(b[4:], crc32.ChecksumIEEE(payload))
+	return append(b, payload...)
+}
+
+// readRecord returns io.EOF at the clean end of the file
+// and errAOFCorrupt for a torn or damaged record.
+func readRecord(r *bufio.Reader) (*aofRecord, int64, error) {
+	var header [aofHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, errAOFCorrupt
+	}
+
+	payload := make([]byte, binary.LittleEndian.Uint32(header[:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, errAOFCorrupt
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) || len(payload) == 0 {
+		return nil, 0, errAOFCorrupt
+	}
+
+	rec := &aofRecord{op: payload[0]}
+	b := payload[1:]
+
+	ttl, n := binary.Varint(b)
+	if n <= 0 {
+		return nil, 0, errAOFCorrupt
+	}
+	b = b[n:]
+
+	at, n := binary.Varint(b)
+	if n <= 0 {
+		return nil, 0, errAOFCorrupt
+	}
+	b = b[n:]
+
+	klen, n := binary.Uvarint(b)
+	if n <= 0 || uint64(len(b)-n) < klen {
+		return nil, 0, errAOFCorrupt
+	}
+	b = b[n:]
+
+	rec.ttl = time.Duration(ttl)
+	rec.at = time.Unix(0, at)
+	rec.key = string(b[:klen])
+	rec.value = b[klen:]
+	return rec, int64(aofHeaderLen + len(payload)), nil
+}
+
+// openAOF replays the file,truncates a torn tail and opens it for appending.
+func (self *TMemoryCache) openAOF() error {
+	cfg := self.config
+	size, err := self.replayAOF(cfg.AOFPath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(cfg.AOFPath, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	self.aof = &aof{
+		path:       cfg.AOFPath,
+		policy:     cfg.AOFSync,
+		f:          f,
+		size:       size,
+		base:       size,
+		minRewrite: cfg.AOFRewriteSize,
+	}
+
+	if self.aof.policy == SyncEverySec {
+		go self.aofSyncer()
+	}
+	return nil
+}
+
+// replayAOF applies the records and returns the size of the valid part of the file.
+func (self *TMemoryCache) replayAOF(path string) (int64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	ctx := context.Background()
+	r := bufio.NewReader(f)
+	var size int64
+	count := 0
+	for {
+		rec, n, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			self.config.Logger.Warn("cache: aof is truncated", "path", path, "offset", size, "error", err)
+			break
+		}
+
+		if err := self.apply(ctx, rec); err != nil {
+			return 0, err
+		}
+		size += n
+		count++
+	}
+
+	// 删除重放后已过期的缓存
+	now := self.config.Clock.Now()
+	for key, ele := range self.blocks {
+		if expired(ele.Value.(*cacher.CacheBlock), now) {
+			self.config.GcList.Remove(ele)
+			delete(self.blocks, key)
+		}
+	}
+
+	self.config.Logger.Debug("cache: aof replayed", "path", path, "records", count, "len", len(self.blocks))
+	return size, nil
+}
+
+// apply replays a record,the aof is not open yet so nothing is logged.
+func (self *TMemoryCache) apply(ctx context.Context, rec *aofRecord) error {
+	switch rec.op {
+	case aofSet:
+		var value any
+		if err := self.config.Unmarshal(rec.value, &value); err != nil {
+			return err
+		}
+
+		block := &cacher.CacheBlock{Key: rec.key, Value: value, TTL: rec.ttl}
+		return self.restore(ctx, block, rec.at, self.config.Clock.Now())
+
+	case aofDelete:
+		return self.DeleteCtx(ctx, rec.key)
+
+	case aofExpire:
+		if ele, has := self.blocks[rec.key]; has {
+			block := ele.Value.(*cacher.CacheBlock)
+			block.TTL = rec.ttl
+			block.LastAccess = rec.at
+		}
+
+	case aofClear:
+		return self.ClearCtx(ctx)
+	}
+	return nil
+}
+
+func (self *TMemoryCache) logSet(block *cacher.CacheBlock, now time.Time) error {
+	if self.aof == nil {
+		return nil
+	}
+
+	value, err := self.config.Marshal(block.Value)
+	if err != nil {
+		return err
+	}
+	return self.logAOF(&aofRecord{op: aofSet, key: block.Key, value: value, ttl: block.TTL, at: now})
+}
+
+func (self *TMemoryCache) logDelete(key string) error {
+	if self.aof == nil {
+		return nil
+	}
+	return self.logAOF(&aofRecord{op: aofDelete, key: key})
+}
+
+func (self *TMemoryCache) logExpire(key string, ttl time.Duration, now time.Time) error {
+	if self.aof == nil {
+		return nil
+	}
+	return self.logAOF(&aofRecord{op: aofExpire, key: key, ttl: ttl, at: now})
+}
+
+func (self *TMemoryCache) logClear() error {
+	if self.aof == nil {
+		return nil
+	}
+	return self.logAOF(&aofRecord{op: aofClear})
+}
+
+// logAOF appends the record,it is called with the lock of the cacher held
+// so the records are in the same order as the writes.
+func (self *TMemoryCache) logAOF(rec *aofRecord) error {
+	a := self.aof
+	b := rec.encode()
+
+	a.Lock()
+	defer a.Unlock()
+
+	if a.closed {
+		return nil
+	}
+
+	if _, err := a.f.Write(b); err != nil {
+		self.config.Logger.Error("cache: aof write failed", "path", a.path, "error", err)
+		return err
+	}
+	a.size += int64(len(b))
+
+	if a.rewriting {
+		a.pending = append(a.pending, b)
+	}
+
+	if a.policy == SyncAlways {
+		if err := a.f.Sync(); err != nil {
+			return err
+		}
+	} else {
+		a.dirty = true
+	}
+
+	if !a.rewriting && a.size >= a.minRewrite && a.size >= 2*a.base {
+		go func() {
+			if err := self.RewriteAOF(); err != nil {
+				self.config.Logger.Error("cache: aof rewrite failed", "path", a.path, "error", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// RewriteAOF compacts the append only file by rewriting it from the live caches,
+// the writes during the rewrite are appended to the new file before it replaces the old one.
+func (self *TMemoryCache) RewriteAOF() error {
+	a := self.aof
+	if a == nil {
+		return nil
+	}
+
+	now := self.config.Clock.Now()
+
+	// 复制缓存和开始记录新的写入必须在同一把锁内
+	self.RLock()
+	a.Lock()
+	if a.rewriting || a.closed {
+		a.Unlock()
+		self.RUnlock()
+		return nil
+	}
+	a.rewriting = true
+	a.Unlock()
+
+	self.config.GcListLock.RLock()
+	blocks := make([]*cacher.CacheBlock, 0, self.config.GcList.Len())
+	for ele := self.config.GcList.Back(); ele != nil; ele = ele.Prev() {
+		if block, ok := ele.Value.(*cacher.CacheBlock); ok && block.Key != "" && !expired(block, now) {
+			blocks = append(blocks, block.Clone())
+		}
+	}
+	self.config.GcListLock.RUnlock()
+	self.RUnlock()
+
+	f, err := self.writeAOF(a.path, blocks)
+	a.Lock()
+	defer a.Unlock()
+
+	if err == nil && a.closed {
+		err = errors.New("cache: aof is closed")
+	}
+
+	if err == nil {
+		err = self.swapAOF(a, f)
+	}
+
+	if err != nil && f != nil {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	a.rewriting = false
+	a.pending = nil
+	return err
+}
+
+// writeAOF writes the blocks to a temporary file next to the path.
+func (self *TMemoryCache) writeAOF(path string, blocks []*cacher.CacheBlock) (*os.File, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, block := range blocks {
+		value, err := self.config.Marshal(block.Value)
+		if err != nil {
+			return f, err
+		}
+
+		rec := &aofRecord{op: aofSet, key: block.Key, value: value, ttl: block.TTL, at: block.LastAccess}
+		if _, err := w.Write(rec.encode()); err != nil {
+			return f, err
+		}
+	}
+	return f, w.Flush()
+}
+
+// swapAOF appends the pending records and replaces the file,the aof must be locked.
+func (self *TMemoryCache) swapAOF(a *aof, f *os.File) error {
+	for _, b := range a.pending {
+		if _, err := f.Write(b); err != nil {
+			return err
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.Name(), a.path); err != nil {
+		return err
+	}
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	a.f.Close()
+	a.f = f
+	a.size = size
+	a.base = size
+	a.dirty = false
+	self.config.Logger.Debug("cache: aof rewritten", "path", a.path, "size", size)
+	return nil
+}
+
+// aofSyncer syncs the file every second until the cacher is closed.
+func (self *TMemoryCache) aofSyncer() {
+	for {
+		select {
+		case <-self.config.Clock.After(time.Second):
+		case <-self.exit:
+			return
+		}
+
+		a := self.aof
+		a.Lock()
+		if a.dirty && !a.closed {
+			if err := a.f.Sync(); err != nil {
+				self.config.Logger.Error("cache: aof sync failed", "path", a.path, "error", err)
+			}
+			a.dirty = false
+		}
+		a.Unlock()
+	}
+}
+
+// closeAOF syncs and closes the file,the later writes are not logged.
+func (self *TMemoryCache) closeAOF() error {
+	a := self.aof
+	if a == nil {
+		return nil
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+
+	if err := a.f.Sync(); err != nil {
+		a.f.Close()
+		return err
+	}
+	return a.f.Close()
+}
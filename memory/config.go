@@ -23,6 +23,19 @@ type (
 		prefix     string
 		Size       int // 最大上限缓存
 		GC         bool
+		Logger     cacher.Logger
+		Clock      cacher.Clock
+
+		// the snapshot is loaded by New and written every SnapshotInterval and on Close
+		SnapshotPath     string        `field:"snapshot_path"`
+		SnapshotInterval time.Duration `field:"snapshot_interval"`
+		Marshal          MarshalFunc   // codec of the values in the snapshot,default is gob
+		Unmarshal        UnmarshalFunc
+
+		// the writes are logged to the append only file and replayed by New
+		AOFPath        string     `field:"aof_path"`
+		AOFSync        SyncPolicy `field:"aof_sync"`
+		AOFRewriteSize int64      `field:"aof_rewrite_size"` // compact the file once it reaches the size and doubles since the last rewrite
 	}
 )
 
@@ -58,3 +71,35 @@ func WithExpire(ticker int) cacher.Option {
 		cfg.SetByField("expire", v)
 	}
 }
+
+// WithSnapshot loads the snapshot file on New and writes it on Close,
+// and every interval if the interval is positive.
+func WithSnapshot(path string, interval time.Duration) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("snapshot_path", path)
+		cfg.SetByField("snapshot_interval", interval)
+	}
+}
+
+// WithCodec sets how the values are encoded in the snapshot.
+func WithCodec(marshal MarshalFunc, unmarshal UnmarshalFunc) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("marshal", marshal)
+		cfg.SetByField("unmarshal", unmarshal)
+	}
+}
+
+// WithAOF logs the writes to the append only file and replays it on New.
+func WithAOF(path string, policy SyncPolicy) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("aof_path", path)
+		cfg.SetByField("aof_sync", policy)
+	}
+}
+
+// WithAOFRewriteSize sets the minimum size of the append only file to be compacted.
+func WithAOFRewriteSize(size int64) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("aof_rewrite_size", size)
+	}
+}
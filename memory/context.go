@@ -0,0 +1,290 @@
+package memory
+
+import (
+	"container/list"
+	"context"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+const (
+	minLockWait = 5 * time.Microsecond
+	maxLockWait = time.Millisecond
+
+	// check the context every n keys while walking the cache
+	ctxCheckEvery = 1024
+)
+
+// acquire waits for the lock until the context is done.
+// a context which can never be done just blocks on the lock.
+func acquire(ctx context.Context, try func() bool, lock func()) error {
+	if ctx.Done() == nil {
+		lock()
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if try() {
+		return nil
+	}
+
+	wait := minLockWait
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if try() {
+			return nil
+		}
+
+		if wait < maxLockWait {
+			wait *= 2
+		}
+		timer.Reset(wait)
+	}
+}
+
+func (self *TMemoryCache) rlock(ctx context.Context) error {
+	return acquire(ctx, self.TryRLock, self.RLock)
+}
+
+func (self *TMemoryCache) lock(ctx context.Context) error {
+	return acquire(ctx, self.TryLock, self.Lock)
+}
+
+func (self *TMemoryCache) lockList(ctx context.Context) error {
+	return acquire(ctx, self.config.GcListLock.TryLock, self.config.GcListLock.Lock)
+}
+
+// GetCtx gets the cache and gives up once the context is done.
+// the blocks are read and written only under the lock of the cacher,
+// Get takes the write lock since it refreshes LastAccess.
+func (self *TMemoryCache) GetCtx(ctx context.Context, key string) (any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	if err := self.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer self.Unlock()
+
+	ele, ok := self.blocks[key]
+	if !ok {
+		return nil, cacher.ErrCacheMiss
+	}
+
+	block := ele.Value.(*cacher.CacheBlock)
+	now := self.config.Clock.Now()
+	if err := self.lockList(ctx); err != nil {
+		return nil, err
+	}
+	defer self.config.GcListLock.Unlock()
+
+	if expired(block, now) {
+		self.config.GcList.Remove(ele)
+		delete(self.blocks, key)
+		return nil, cacher.ErrCacheMiss
+	}
+
+	block.LastAccess = now
+	self.config.GcList.MoveToFront(ele)
+	return block.Value, nil
+}
+
+// SetCtx puts the cache to memory and gives up once the context is done.
+func (self *TMemoryCache) SetCtx(ctx context.Context, block *cacher.CacheBlock) error {
+	if !self.config.Active {
+		return nil
+	}
+
+	if err := self.lock(ctx); err != nil {
+		return err
+	}
+	defer self.Unlock()
+
+	now := self.config.Clock.Now()
+	ele, has := self.lookup(block.Key, now)
+
+	switch {
+	case has && block.SetOnlyNew:
+		return nil
+	case !has && block.SetOnlyExist:
+		return nil
+	}
+
+	return self.store(ctx, block, ele, now)
+}
+
+// lookup returns the element of the key and whether it is alive,
+// an expired element is returned to be reused. the lock must be held.
+func (self *TMemoryCache) lookup(key string, now time.Time) (*list.Element, bool) {
+	ele, has := self.blocks[key]
+	if has && expired(ele.Value.(*cacher.CacheBlock), now) {
+		has = false
+	}
+	return ele, has
+}
+
+// store puts the block into the element or a new one and assigns it a new version.
+// the lock must be held.
+func (self *TMemoryCache) store(ctx context.Context, block *cacher.CacheBlock, ele *list.Element, now time.Time) error {
+	block.LastAccess = now
+	if ele != nil {
+		if err := self.logSet(block, now); err != nil {
+			return err
+		}
+		block.Version = self.nextVersion()
+		ele.Value = block
+		return nil
+	}
+
+	if err := self.lockList(ctx); err != nil {
+		return err
+	}
+	defer self.config.GcListLock.Unlock()
+
+	if self.config.GcList.Len() >= self.config.Size {
+		self.config.Logger.Warn("cache: memory is full", "key", block.Key, "size", self.config.Size)
+		return nil
+	}
+
+	if err := self.logSet(block, now); err != nil {
+		return err
+	}
+	block.Version = self.nextVersion()
+	self.blocks[block.Key] = self.config.GcList.PushFront(block)
+	return nil
+}
+
+func (self *TMemoryCache) ExistsCtx(ctx context.Context, key string) (bool, error) {
+	if !self.config.Active {
+		return false, nil
+	}
+
+	if err := self.rlock(ctx); err != nil {
+		return false, err
+	}
+	_, has := self.lookup(key, self.config.Clock.Now())
+	self.RUnlock()
+
+	return has, nil
+}
+
+// DeleteCtx deletes the cache,deleting a missing key is not an error.
+func (self *TMemoryCache) DeleteCtx(ctx context.Context, key string) error {
+	if err := self.lock(ctx); err != nil {
+		return err
+	}
+	defer self.Unlock()
+
+	ele, ok := self.blocks[key]
+	if !ok {
+		return nil
+	}
+
+	if err := self.logDelete(key); err != nil {
+		return err
+	}
+
+	if err := self.lockList(ctx); err != nil {
+		return err
+	}
+	self.config.GcList.Remove(ele)
+	self.config.GcListLock.Unlock()
+
+	delete(self.blocks, key)
+	return nil
+}
+
+func (self *TMemoryCache) KeysCtx(ctx context.Context) ([]string, error) {
+	if !self.config.Active {
+		return nil, nil
+	}
+
+	if err := self.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer self.RUnlock()
+
+	keys := make([]string, 0, len(self.blocks))
+	for k := range self.blocks {
+		if len(keys)%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+func (self *TMemoryCache) LenCtx(ctx context.Context) (int, error) {
+	if err := self.rlock(ctx); err != nil {
+		return 0, err
+	}
+	defer self.RUnlock()
+
+	return len(self.blocks), nil
+}
+
+// ClearCtx deletes all caches once both locks are held in time.
+func (self *TMemoryCache) ClearCtx(ctx context.Context) error {
+	if err := self.lock(ctx); err != nil {
+		return err
+	}
+	defer self.Unlock()
+
+	if err := self.logClear(); err != nil {
+		return err
+	}
+
+	if err := self.lockList(ctx); err != nil {
+		return err
+	}
+	self.config.GcList.Init() // 初始化列表
+	self.config.GcListLock.Unlock()
+
+	self.blocks = make(map[string]*list.Element)
+	return nil
+}
+
+// CloseCtx stops the gc,writes the snapshot file,closes the aof if any and deletes all caches.
+func (self *TMemoryCache) CloseCtx(ctx context.Context) error {
+	var err error
+	self.closeOnce.Do(func() {
+		close(self.exit)
+
+		if self.config.SnapshotPath != "" {
+			if err = self.SnapshotFile(self.config.SnapshotPath); err != nil {
+				self.config.Logger.Error("cache: snapshot failed", "path", self.config.SnapshotPath, "error", err)
+			}
+		}
+
+		// 关闭后的清空不能写入AOF
+		if aofErr := self.closeAOF(); aofErr != nil && err == nil {
+			err = aofErr
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+	return self.ClearCtx(ctx)
+}
+
+// expired reports whether the block outlives its TTL since the last access.
+func expired(block *cacher.CacheBlock, now time.Time) bool {
+	ttl := block.Ttl()
+	return ttl > 0 && now.After(block.LastAccess.Add(ttl))
+}
@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+// a hash is stored as a map which is copied by every write,
+// so the maps returned by the reads are never changed.
+
+// hashOf returns the hash held by the element.
+func hashOf(value any) (map[string]any, error) {
+	hash, ok := value.(map[string]any)
+	if !ok {
+		return nil, cacher.ErrWrongType
+	}
+	return hash, nil
+}
+
+func (self *TMemoryCache) HGet(key, field string, ctx ...context.Context) (any, error) {
+	value, err := self.GetCtx(cacher.Context(ctx...), key)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := hashOf(value)
+	if err != nil {
+		return nil, err
+	}
+
+	v, has := hash[field]
+	if !has {
+		return nil, cacher.ErrCacheMiss
+	}
+	return v, nil
+}
+
+// HGetAll returns a copy of the fields.
+func (self *TMemoryCache) HGetAll(key string, ctx ...context.Context) (map[string]any, error) {
+	value, err := self.GetCtx(cacher.Context(ctx...), key)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := hashOf(value)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]any, len(hash))
+	for field, v := range hash {
+		fields[field] = v
+	}
+	return fields, nil
+}
+
+// HSet sets the fields and resets the TTL of the hash.
+func (self *TMemoryCache) HSet(key string, fields map[string]any, ttl time.Duration, ctx ...context.Context) error {
+	if !self.config.Active {
+		return nil
+	}
+
+	c := cacher.Context(ctx...)
+	if err := self.lock(c); err != nil {
+		return err
+	}
+	defer self.Unlock()
+
+	now := self.config.Clock.Now()
+	ele, has := self.lookup(key, now)
+
+	hash := make(map[string]any, len(fields))
+	if has {
+		old, err := hashOf(ele.Value.(*cacher.CacheBlock).Value)
+		if err != nil {
+			return err
+		}
+		for field, v := range old {
+			hash[field] = v
+		}
+	}
+	for field, v := range fields {
+		hash[field] = v
+	}
+
+	return self.store(c, &cacher.CacheBlock{Key: key, Value: hash, TTL: ttl}, ele, now)
+}
+
+// HDel deletes the fields and keeps the TTL,the key is deleted with its last field.
+func (self *TMemoryCache) HDel(key string, fields []string, ctx ...context.Context) error {
+	c := cacher.Context(ctx...)
+	if err := self.lock(c); err != nil {
+		return err
+	}
+	defer self.Unlock()
+
+	now := self.config.Clock.Now()
+	ele, has := self.lookup(key, now)
+	if !has {
+		return nil
+	}
+
+	block := ele.Value.(*cacher.CacheBlock)
+	old, err := hashOf(block.Value)
+	if err != nil {
+		return err
+	}
+
+	hash := make(map[string]any, len(old))
+	for field, v := range old {
+		hash[field] = v
+	}
+	for _, field := range fields {
+		delete(hash, field)
+	}
+
+	if len(hash) > 0 {
+		// 保持原来的开始时间,剩余TTL不变
+		return self.store(c, &cacher.CacheBlock{Key: key, Value: hash, TTL: block.TTL}, ele, block.LastAccess)
+	}
+
+	if err := self.logDelete(key); err != nil {
+		return err
+	}
+	if err := self.lockList(c); err != nil {
+		return err
+	}
+	self.config.GcList.Remove(ele)
+	self.config.GcListLock.Unlock()
+
+	delete(self.blocks, key)
+	return nil
+}
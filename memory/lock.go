@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+type (
+	// Locker hands out locks which live in the process only.
+	Locker struct {
+		*cacher.Locker
+	}
+
+	lockStore struct {
+		sync.Mutex
+		clock cacher.Clock
+		locks map[string]*lockEntry
+	}
+
+	lockEntry struct {
+		token    string
+		expireAt time.Time
+	}
+)
+
+// NewLocker returns a locker for single process use and tests.
+func NewLocker(clock ...cacher.Clock) *Locker {
+	store := &lockStore{
+		clock: cacher.SystemClock,
+		locks: make(map[string]*lockEntry),
+	}
+	if len(clock) > 0 && clock[0] != nil {
+		store.clock = clock[0]
+	}
+
+	locker := cacher.NewLocker(store)
+	locker.Clock = store.clock
+	return &Locker{Locker: locker}
+}
+
+// lookup returns the lock if it is not expired,the lock must be held.
+func (self *lockStore) lookup(name string) *lockEntry {
+	e, has := self.locks[name]
+	if !has {
+		return nil
+	}
+
+	if !self.clock.Now().Before(e.expireAt) {
+		delete(self.locks, name)
+		return nil
+	}
+	return e
+}
+
+func (self *lockStore) Acquire(ctx context.Context, name, token string, ttl time.Duration) (bool, error) {
+	self.Lock()
+	defer self.Unlock()
+
+	if self.lookup(name) != nil {
+		return false, nil
+	}
+
+	self.locks[name] = &lockEntry{token: token, expireAt: self.clock.Now().Add(ttl)}
+	return true, nil
+}
+
+func (self *lockStore) Extend(ctx context.Context, name, token string, ttl time.Duration) (bool, error) {
+	self.Lock()
+	defer self.Unlock()
+
+	e := self.lookup(name)
+	if e == nil || e.token != token {
+		return false, nil
+	}
+
+	e.expireAt = self.clock.Now().Add(ttl)
+	return true, nil
+}
+
+func (self *lockStore) Release(ctx context.Context, name, token string) (bool, error) {
+	self.Lock()
+	defer self.Unlock()
+
+	e := self.lookup(name)
+	if e == nil || e.token != token {
+		return false, nil
+	}
+
+	delete(self.locks, name)
+	return true, nil
+}
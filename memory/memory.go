@@ -11,6 +11,7 @@ import (
 	"unsafe"
 
 	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/internal/codec"
 )
 
 var Memory = cacher.Register("Memory", func() cacher.ICacher {
@@ -61,6 +62,10 @@ type (
 		Every  int //废弃 run an expiration check Every clock time
 
 		blockPool sync.Pool
+		exit      chan struct{} // closed to stop the gc
+		closeOnce sync.Once
+		version   uint64 // version of the last write
+		aof       *aof   // nil unless AOFPath is set
 	}
 )
 
@@ -74,15 +79,52 @@ func New(opts ...cacher.Option) *TMemoryCache {
 	}
 	cfg.Init(opts...)
 
+	if cfg.Logger == nil {
+		cfg.Logger = cacher.DefaultLogger()
+	}
+
+	if cfg.Clock == nil {
+		cfg.Clock = cacher.SystemClock
+	}
+
+	if cfg.Marshal == nil || cfg.Unmarshal == nil {
+		cfg.Marshal, cfg.Unmarshal = codec.GobMarshal, codec.GobUnmarshal
+	}
+
 	c := &TMemoryCache{
 		config: cfg,
 		//dur:     cacher.INTERVAL_TIME * time.Second,
 		//expired: cacher.EXPIRED_TIME * time.Second,
 		blocks: make(map[string]*list.Element),
+		exit:   make(chan struct{}),
 	}
 
 	c.blockPool.New = func() any { return &cacher.CacheBlock{} }
 
+	if cfg.SnapshotPath != "" {
+		if err := c.RestoreFile(cfg.SnapshotPath); err != nil {
+			cfg.Logger.Error("cache: restore snapshot failed", "path", cfg.SnapshotPath, "error", err)
+		}
+
+		if cfg.SnapshotInterval > 0 {
+			go c.snapshotter()
+		}
+	}
+
+	if cfg.AOFPath != "" {
+		if cfg.AOFSync == "" {
+			cfg.AOFSync = SyncEverySec
+		}
+
+		if cfg.AOFRewriteSize <= 0 {
+			cfg.AOFRewriteSize = defaultAOFRewriteSize
+		}
+
+		if err := c.openAOF(); err != nil {
+			cfg.Logger.Error("cache: open aof failed", "path", cfg.AOFPath, "error", err)
+		}
+	}
+
 	/* Interval等于0不回收 */
 	if cfg.Interval > 0 {
 		err := c.gc()
@@ -115,50 +157,28 @@ func (self *TMemoryCache) ___New(fn func() interface{}) {
 // Get cache from memory.
 // return slice
 func (self *TMemoryCache) Keys(ctx ...context.Context) []string {
-	if self.config.Active {
-		self.RLock()
-		defer self.RUnlock()
-
-		len := len(self.blocks)
-		keys := make([]string, len)
-		idx := 0
-		for k := range self.blocks {
-			if idx >= len {
-				break
-			}
-			keys[idx] = k
-			idx++
-		}
-
-		return keys
-	}
-
-	return nil
+	keys, _ := self.KeysCtx(cacher.Context(ctx...))
+	return keys
 }
 
 func (self *TMemoryCache) Close() error {
-	return self.Clear()
+	return self.CloseCtx(context.Background())
 }
 
 // delete all cache in memory.
 func (self *TMemoryCache) Clear() error {
-	self.config.GcListLock.Lock()
-	self.config.GcList.Init() // 初始化列表
-	self.config.GcListLock.Unlock()
-
-	self.Lock()
-	self.blocks = make(map[string]*list.Element)
-	self.Unlock()
-	return nil
+	return self.ClearCtx(context.Background())
 }
 
 // get first one
 func (self *TMemoryCache) Front() *cacher.CacheBlock {
 	if self.config.Active {
+		self.Lock()
+		defer self.Unlock()
 		self.config.GcListLock.RLock()
 		block := self.config.GcList.Front().Value.(*cacher.CacheBlock)
 		self.config.GcListLock.RUnlock()
-		block.LastAccess = time.Now()
+		block.LastAccess = self.config.Clock.Now()
 		return block
 	}
 
@@ -167,10 +187,12 @@ func (self *TMemoryCache) Front() *cacher.CacheBlock {
 
 func (self *TMemoryCache) Back() *cacher.CacheBlock {
 	if self.config.Active {
+		self.Lock()
+		defer self.Unlock()
 		self.config.GcListLock.RLock()
 		block := self.config.GcList.Back().Value.(*cacher.CacheBlock)
 		self.config.GcListLock.RUnlock()
-		block.LastAccess = time.Now()
+		block.LastAccess = self.config.Clock.Now()
 		return block
 	}
 
@@ -209,64 +231,14 @@ type emptyInterface struct {
 // Get cache from memory.
 // if non-existed or expired, return nil.
 func (self *TMemoryCache) Get(name string, ctx ...context.Context) (value any, err error) {
-	if !self.config.Active {
-		return nil, cacher.ErrInactive
-	}
-
-	self.RLock()
-	ele, ok := self.blocks[name]
-	self.RUnlock()
-
-	if ok && ele != nil {
-		if block, ok := ele.Value.(*cacher.CacheBlock); ok {
-			block.LastAccess = time.Now()
-
-			self.config.GcListLock.Lock()
-			self.config.GcList.MoveToFront(ele)
-			self.config.GcListLock.Unlock()
-
-			// 实现任何类型复制
-			/*
-				// TODO 需要更多验证稳定性
-				// FIXME 无法复制结构体内的字符串
-
-				dst := (*emptyAny)(unsafe.Pointer(&value)).val
-				src := (*emptyAny)(unsafe.Pointer(&block.Value)).val
-				*(*emptyAny)(unsafe.Pointer(dst)) = *(*emptyAny)(unsafe.Pointer(src))
-			*/
-			//err := copier.Copy(value, block.Value)
-			return block.Value, nil
-		}
-	}
-
-	return nil, cacher.ErrCacheMiss
+	return self.GetCtx(cacher.Context(ctx...), name)
 }
 
 // Put cache to memory.
 // if expired is 0, it will be cleaned by next gc operation ( default gc clock is 1 minute).
 // expired is -1 mean never expire
 func (self *TMemoryCache) Set(block *cacher.CacheBlock) error {
-	if !self.config.Active || self.config.GcList.Len() >= self.config.Size {
-		return nil
-	}
-	block.LastAccess = time.Now()
-
-	self.Lock()
-
-	ele, has := self.blocks[block.Key]
-	if has {
-
-		ele.Value = block
-	} else {
-		self.config.GcListLock.Lock()
-		item := self.config.GcList.PushFront(block) //之前  self.config.GcList.PushBack(block)
-		self.config.GcListLock.Unlock()
-
-		self.blocks[block.Key] = item
-	}
-
-	self.Unlock()
-	return nil
+	return self.SetCtx(block.Context(), block)
 }
 
 // 删除第一个元素
@@ -323,7 +295,12 @@ func (self *TMemoryCache) Pop() any {
 
 // put to last of list
 func (self *TMemoryCache) Push(value any) error {
-	if !self.config.Active || self.config.GcList.Len() >= self.config.Size {
+	if !self.config.Active {
+		return nil
+	}
+
+	if self.config.GcList.Len() >= self.config.Size {
+		self.config.Logger.Warn("cache: memory is full", "size", self.config.Size)
 		return nil
 	}
 	/*if block.Key == "" {
@@ -334,7 +311,7 @@ func (self *TMemoryCache) Push(value any) error {
 	}*/
 
 	block := self.blockPool.Get().(*cacher.CacheBlock)
-	block.LastAccess = time.Now()
+	block.LastAccess = self.config.Clock.Now()
 	block.Value = value
 
 	self.config.GcListLock.Lock()
@@ -353,38 +330,45 @@ func (self *TMemoryCache) remove_list(ele *list.Element) {
 	self.config.GcListLock.Unlock()
 }
 
-func (self *TMemoryCache) remove_block(name string) {
+// remove_block removes the element of the block found by the gc,
+// unless a Set reused the element or replaced the key meanwhile,
+// or the block was accessed again when only an expired one may be removed.
+func (self *TMemoryCache) remove_block(iter *list.Element, block *cacher.CacheBlock, expiredOnly bool) bool {
 	self.Lock()
-	delete(self.blocks, name)
-	self.Unlock()
+	defer self.Unlock()
+
+	if iter.Value != block || (expiredOnly && !expired(block, self.config.Clock.Now())) {
+		return false
+	}
+
+	if ele, has := self.blocks[block.Key]; has {
+		if ele != iter {
+			return false
+		}
+		delete(self.blocks, block.Key)
+		self.logDelete(block.Key)
+	}
+
+	self.remove_list(iter)
+	return true
 }
 
 // / Delete cache in memory.event a err
 func (self *TMemoryCache) Delete(key string, ctx ...context.Context) (err error) {
-	self.RLock()
-	ele, ok := self.blocks[key]
-	self.RUnlock()
-
-	if ok {
-		self.remove_list(ele)
-		self.remove_block(key)
-		//fmt.Print("aa ", name, ok)
-	}
-	return fmt.Errorf("delete key %s is not exist!", key)
+	return self.DeleteCtx(cacher.Context(ctx...), key)
 }
 
-// Increase cache counter in memory.
+// Increase cache counter in memory under the lock,so it is atomic with Update.
 // it supports int,int64,int32,uint,uint64,uint32.
 func (self *TMemoryCache) Incr(key string) error {
-	self.RLock()
-	ele, ok := self.blocks[key]
-	self.RUnlock()
+	self.Lock()
+	defer self.Unlock()
 
+	ele, ok := self.lookup(key, self.config.Clock.Now())
 	if !ok {
 		return fmt.Errorf("Incr key %s is not exist!", key)
 	}
 	itm := ele.Value.(*cacher.CacheBlock)
-	itm.LastAccess.Add(cacher.DELAY_TIME * time.Second)
 	switch itm.Value.(type) {
 	case int:
 		itm.Value = itm.Value.(int) + 1
@@ -401,14 +385,14 @@ func (self *TMemoryCache) Incr(key string) error {
 	default:
 		return errors.New("item val is not int int64 int32")
 	}
-	return nil
+	itm.Version = self.nextVersion()
+	return self.logSet(itm, self.config.Clock.Now())
 }
 
 // Count of cache size
 func (self *TMemoryCache) Len() int {
-	self.RLock()
-	defer self.RUnlock()
-	return len(self.blocks)
+	n, _ := self.LenCtx(context.Background())
+	return n
 }
 
 // max of cache size
@@ -422,15 +406,14 @@ func (self *TMemoryCache) Size(max ...int) int {
 
 // Decrease counter in memory.
 func (self *TMemoryCache) Decr(key string) error {
-	self.RLock()
-	ele, ok := self.blocks[key]
-	self.RUnlock()
+	self.Lock()
+	defer self.Unlock()
 
+	ele, ok := self.lookup(key, self.config.Clock.Now())
 	if !ok {
 		return errors.New("key not exist")
 	}
 	itm := ele.Value.(*cacher.CacheBlock)
-	itm.LastAccess.Add(cacher.DELAY_TIME * time.Second)
 	switch itm.Value.(type) {
 	case int:
 		itm.Value = itm.Value.(int) - 1
@@ -459,27 +442,94 @@ func (self *TMemoryCache) Decr(key string) error {
 	default:
 		return errors.New("item val is not int int64 int32")
 	}
-	return nil
+	itm.Version = self.nextVersion()
+	return self.logSet(itm, self.config.Clock.Now())
 }
 
-// check cache exist in memory.
-func (self *TMemoryCache) Exists(name string, ctx ...context.Context) bool {
-	if self.config.Active {
-		self.RLock()
-		ele, _ := self.blocks[name]
-		self.RUnlock()
+// get multi caches from memory.missing keys are omitted.
+func (self *TMemoryCache) GetMulti(keys []string, ctx ...context.Context) (map[string]any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	values := make(map[string]any, len(keys))
+	for _, key := range keys {
+		if value, err := self.Get(key, ctx...); err == nil {
+			values[key] = value
+		}
+	}
 
-		if ele != nil {
-			// # 更新访问日期
-			//if block, allowed := ele.Value.(*cacher.CacheBlock); allowed && block != nil {
-			//	block.LastAccess.Add(cacher.DELAY_TIME * time.Second)
-			//}
-			return true
+	return values, nil
+}
 
+func (self *TMemoryCache) SetMulti(blocks ...*cacher.CacheBlock) error {
+	for _, block := range blocks {
+		if err := self.Set(block); err != nil {
+			return err
 		}
+	}
+
+	return nil
+}
 
+// delete multi caches,the missing keys are ignored.
+func (self *TMemoryCache) DeleteMulti(keys []string, ctx ...context.Context) error {
+	c := cacher.Context(ctx...)
+	for _, key := range keys {
+		if err := self.DeleteCtx(c, key); err != nil {
+			return err
+		}
 	}
-	return false
+
+	return nil
+}
+
+// TTL returns the remaining time before the cache expires.
+// -1 mean never expire
+func (self *TMemoryCache) TTL(key string, ctx ...context.Context) (time.Duration, error) {
+	if err := self.rlock(cacher.Context(ctx...)); err != nil {
+		return 0, err
+	}
+	defer self.RUnlock()
+
+	now := self.config.Clock.Now()
+	ele, ok := self.lookup(key, now)
+	if !ok {
+		return 0, cacher.ErrCacheMiss
+	}
+
+	block := ele.Value.(*cacher.CacheBlock)
+	ttl := block.Ttl()
+	if ttl <= 0 {
+		return -1, nil
+	}
+
+	return block.LastAccess.Add(ttl).Sub(now), nil
+}
+
+// Expire resets the TTL of the cache.
+func (self *TMemoryCache) Expire(key string, ttl time.Duration, ctx ...context.Context) error {
+	if err := self.lock(cacher.Context(ctx...)); err != nil {
+		return err
+	}
+	defer self.Unlock()
+
+	now := self.config.Clock.Now()
+	ele, ok := self.lookup(key, now)
+	if !ok {
+		return cacher.ErrCacheMiss
+	}
+
+	block := ele.Value.(*cacher.CacheBlock)
+	block.TTL = ttl
+	block.LastAccess = now
+	return self.logExpire(key, ttl, now)
+}
+
+// check cache exist in memory.
+func (self *TMemoryCache) Exists(name string, ctx ...context.Context) bool {
+	ok, _ := self.ExistsCtx(cacher.Context(ctx...), name)
+	return ok
 }
 
 // start memory cache. it will check expiration in every clock time.
@@ -506,13 +556,19 @@ func (self *TMemoryCache) vaccuum() {
 	)
 
 	for {
-		<-time.After(self.config.Interval)
+		select {
+		case <-self.config.Clock.After(self.config.Interval):
+		case <-self.exit:
+			return
+		}
 
 		//fmt.Println("tick")
 		if !self.config.Active || self.config.GcList.Len() == 0 {
 			continue
 		}
 
+		start := time.Now()
+		outdated, evicted := 0, 0
 		list = make(TIndexList, 0)
 		// STEP:遍历GC表
 		self.config.GcListLock.RLock()
@@ -528,37 +584,46 @@ func (self *TMemoryCache) vaccuum() {
 			self.config.GcListLock.RUnlock()
 
 			/* stack类非block类定时*/
-			if block, ok = iter.Value.(*cacher.CacheBlock); !ok {
+			self.RLock()
+			block, ok = iter.Value.(*cacher.CacheBlock)
+			var (
+				TTL time.Duration
+				due bool
+				dur time.Duration
+			)
+			if ok {
+				TTL = block.Ttl()
+				now := self.config.Clock.Now()
+				due = TTL > 0 && expired(block, now)
+				dur = now.Sub(block.LastAccess)
+			}
+			self.RUnlock()
+
+			if !ok {
 				self.remove_list(iter)
 				iter = next
 				continue
 			}
 
 			// -1 永不过期
-			TTL := block.Ttl()
 			if TTL <= 0 {
 				iter = next
 				continue
 			}
 
 			// STEP:删除过期
-			//dur := time.Now().Sub(block.LastAccess)
-			//fmt.Println("expired %v ", block.expired, dur, self.expired)
-			//if dur >= block.TTL || dur >= self.expired {
-			if time.Now().After(block.LastAccess.Add(TTL)) {
-				//iter = iter.Next() // # before remove
-				self.remove_list(iter)
-				self.remove_block(block.Key)
-				//fmt.Print("aa ", block.key, ok)
+			if due {
+				if self.remove_block(iter, block, true) {
+					self.config.Logger.Debug("cache: expired", "key", block.Key, "ttl", TTL)
+					outdated++
+				}
 				iter = next
 				continue
-			} else {
-				dur := time.Since(block.LastAccess)
-				//if dur < TTL/3 || dur < self.expired/3 {
-				if dur < TTL/3 {
-					// #因为设置会插入到前端，对即将到期的进行标记
-					list = append(list, TIndex{iter, block, dur})
-				}
+			}
+
+			// #因为设置会插入到前端，对即将到期的进行标记
+			if dur < TTL/3 {
+				list = append(list, TIndex{iter, block, dur})
 			}
 
 			// #jump to next
@@ -571,17 +636,26 @@ func (self *TMemoryCache) vaccuum() {
 
 			for _, idex := range list {
 				if over > 0 {
-					self.remove_list(idex.ele)
-					self.remove_block(idex.block.Key)
+					if self.remove_block(idex.ele, idex.block, false) {
+						self.config.Logger.Debug("cache: evicted", "key", idex.block.Key, "size", self.config.Size)
+						evicted++
 
-					//#继续
-					over--
+						//#继续
+						over--
+					}
 					continue
 				}
 
 				break
 			}
 		}
+
+		self.config.Logger.Debug("cache: gc",
+			"expired", outdated,
+			"evicted", evicted,
+			"len", self.config.GcList.Len(),
+			"duration", time.Since(start),
+		)
 	}
 }
 
@@ -601,7 +675,7 @@ func (self *TMemoryCache) __Expired(name string) bool {
 		return false
 	}
 
-	if time.Since(itm.LastAccess) >= itm.TTL {
+	if self.config.Clock.Now().Sub(itm.LastAccess) >= itm.TTL {
 		/*self.Lock()
 		delete(self.blocks, name)
 		self.Unlock()*/
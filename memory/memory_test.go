@@ -1,14 +1,101 @@
 package memory
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/cachertest"
 	"github.com/volts-dev/utils"
 )
 
+func TestConformance(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	cachertest.Run(t, func(t *testing.T) cacher.ICacher {
+		return New(cacher.WithClock(clock))
+	}, cachertest.WithSleep(clock.Advance))
+}
+
+func TestGC(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	chr := New(cacher.WithClock(clock), WithInterval(30))
+	defer chr.Close()
+
+	chr.Set(&cacher.CacheBlock{Key: "short", Value: 1, TTL: time.Minute})
+	chr.Set(&cacher.CacheBlock{Key: "long", Value: 2, TTL: time.Hour})
+
+	clock.BlockUntil(1) // gc is waiting
+	clock.Advance(2 * time.Minute)
+	clock.BlockUntil(1) // gc is done
+
+	if chr.Len() != 1 || chr.Exists("short") {
+		t.Fatalf("gc should remove the expired cache, keys %v", chr.Keys())
+	}
+
+	if ttl, err := chr.TTL("long"); err != nil || ttl != time.Hour-2*time.Minute {
+		t.Fatalf("ttl %v %v", ttl, err)
+	}
+}
+
+func TestGCReusedElement(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	chr := New(cacher.WithClock(clock), WithInterval(0))
+	defer chr.Close()
+
+	chr.Set(&cacher.CacheBlock{Key: "key", Value: "old", TTL: time.Minute})
+	ele := chr.blocks["key"]
+	block := ele.Value.(*cacher.CacheBlock)
+	clock.Advance(2 * time.Minute)
+
+	// gc看到过期后,Set重用了这个元素
+	chr.Set(&cacher.CacheBlock{Key: "key", Value: "new", TTL: time.Minute})
+	if chr.remove_block(ele, block, true) {
+		t.Fatal("reused element is removed")
+	}
+	if v, err := chr.Get("key"); err != nil || v != "new" {
+		t.Fatalf("get %v %v", v, err)
+	}
+}
+
+func TestCounterUpdate(t *testing.T) {
+	const workers, count = 8, 100
+	chr := New(WithInterval(0))
+	defer chr.Close()
+	chr.Set(&cacher.CacheBlock{Key: "n", Value: 0, TTL: -1})
+
+	// Incr和Update在同一个锁下,计数不会丢失
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				if w%2 == 0 {
+					chr.Incr("n")
+					continue
+				}
+				chr.Update(context.Background(), "n", func(old any, has bool) (any, time.Duration, bool) {
+					return old.(int) + 1, -1, false
+				})
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if v, err := chr.Get("n"); err != nil || v != workers*count {
+		t.Fatalf("counter %v %v", v, err)
+	}
+}
+
 func TestStackCache(t *testing.T) {
 	c := NewStack(WithExpire(30), WithSize(2))
 	c.Push("你")
@@ -17,6 +104,298 @@ func TestStackCache(t *testing.T) {
 	fmt.Println(c.Pop(), c.Pop(), c.Pop())
 }
 
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	chr := New(WithSize(1), cacher.WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	chr.Set(&cacher.CacheBlock{Key: "A", Value: 1})
+	chr.Set(&cacher.CacheBlock{Key: "B", Value: 2})
+
+	if out := buf.String(); !strings.Contains(out, "level=WARN") || !strings.Contains(out, "key=B") {
+		t.Fatalf("expected a warning about the full cache, got %q", out)
+	}
+}
+
+func TestContext(t *testing.T) {
+	chr := New()
+	chr.Set(&cacher.CacheBlock{Key: "A", Value: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := chr.GetCtx(ctx, "A"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+
+	// 锁被占用时等待到超时
+	chr.Lock()
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := chr.ClearCtx(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	chr.Unlock()
+
+	if v, err := chr.GetCtx(context.Background(), "A"); err != nil || v != 1 {
+		t.Fatalf("get %v %v", v, err)
+	}
+
+	var _ cacher.IContextCacher = chr
+	if cacher.WithContext(chr) != cacher.IContextCacher(chr) {
+		t.Fatal("native context api is not used")
+	}
+}
+
+func TestLocker(t *testing.T) {
+	ctx := context.Background()
+	clock := cachertest.NewClock(time.Now())
+	l := NewLocker(clock)
+
+	lease, err := l.TryLock(ctx, "job", 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.TryLock(ctx, "job", time.Second); err != cacher.ErrLockHeld {
+		t.Fatalf("expected ErrLockHeld, got %v", err)
+	}
+
+	// 自动续期让锁超过TTL依然有效
+	for i := 0; i < 5; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+	}
+	clock.BlockUntil(1)
+	if _, err := l.TryLock(ctx, "job", time.Second); err != cacher.ErrLockHeld {
+		t.Fatalf("renewed lock: expected ErrLockHeld, got %v", err)
+	}
+
+	if err := lease.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-lease.Done()
+	if err := lease.Unlock(ctx); err != cacher.ErrLockReleased {
+		t.Fatalf("unlock twice: expected ErrLockReleased, got %v", err)
+	}
+
+	// 未续期的锁过期后被他人获得
+	l.AutoRenew = false
+	stale, err := l.TryLock(ctx, "job", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Second)
+
+	done := make(chan *cacher.Lease)
+	go func() {
+		lease, err := l.Lock(ctx, "job", time.Minute)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- lease
+	}()
+	lease = <-done
+
+	if err := stale.Extend(ctx, time.Second); err != cacher.ErrLockLost {
+		t.Fatalf("extend: expected ErrLockLost, got %v", err)
+	}
+	if err := stale.Unlock(ctx); err != cacher.ErrLockLost {
+		t.Fatalf("unlock: expected ErrLockLost, got %v", err)
+	}
+
+	// Lock 等待锁被释放
+	go func() {
+		lease, err := l.Lock(ctx, "job", time.Minute)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- lease
+	}()
+	clock.BlockUntil(1)
+	lease.Unlock(ctx)
+	clock.Advance(l.Retry)
+	if lease = <-done; lease == nil || lease.Err() != nil {
+		t.Fatal("lock is not acquired after unlock")
+	}
+}
+
+// gatedStore holds the renewal in Extend until the gate is opened.
+type gatedStore struct {
+	*lockStore
+	entered  chan struct{}
+	gate     chan struct{}
+	released chan struct{}
+}
+
+func (self *gatedStore) Extend(ctx context.Context, name, token string, ttl time.Duration) (bool, error) {
+	self.entered <- struct{}{}
+	<-self.gate
+	return self.lockStore.Extend(ctx, name, token, ttl)
+}
+
+func (self *gatedStore) Release(ctx context.Context, name, token string) (bool, error) {
+	ok, err := self.lockStore.Release(ctx, name, token)
+	self.released <- struct{}{}
+	return ok, err
+}
+
+func TestUnlockDuringRenew(t *testing.T) {
+	ctx := context.Background()
+	clock := cachertest.NewClock(time.Now())
+	store := &gatedStore{
+		lockStore: &lockStore{clock: clock, locks: make(map[string]*lockEntry)},
+		entered:   make(chan struct{}),
+		gate:      make(chan struct{}),
+		released:  make(chan struct{}, 1),
+	}
+	l := cacher.NewLocker(store)
+	l.Clock = clock
+
+	lease, err := l.TryLock(ctx, "job", 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	<-store.entered
+
+	errc := make(chan error)
+	go func() {
+		errc <- lease.Unlock(ctx)
+	}()
+
+	// 续期进行中时不能释放锁
+	select {
+	case <-store.released:
+		t.Fatal("the lock is released while the renewal is running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(store.gate)
+
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if err := lease.Err(); err != cacher.ErrLockReleased {
+		t.Fatalf("expected ErrLockReleased, got %v", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	chr := New(cacher.WithClock(clock), WithInterval(0))
+	chr.Set(&cacher.CacheBlock{Key: "A", Value: "a", TTL: time.Minute})
+	chr.Set(&cacher.CacheBlock{Key: "B", Value: 1, TTL: 10 * time.Second})
+	chr.Set(&cacher.CacheBlock{Key: "C", Value: []string{"c"}, TTL: -1})
+	chr.Set(&cacher.CacheBlock{Key: "D", Value: "d", TTL: time.Second})
+	clock.Advance(2 * time.Second)
+
+	var buf bytes.Buffer
+	if err := chr.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// B 在停机期间过期
+	clock.Advance(10 * time.Second)
+	restored := New(cacher.WithClock(clock), WithInterval(0))
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := restored.Keys(); len(keys) != 2 {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	if v, err := restored.Get("C"); err != nil || fmt.Sprint(v) != "[c]" {
+		t.Fatalf("get C %v %v", v, err)
+	}
+	if ttl, _ := restored.TTL("A"); ttl != 48*time.Second {
+		t.Fatalf("remaining ttl of A %v", ttl)
+	}
+	if back := restored.Back(); back.Key != "A" {
+		t.Fatalf("gc order is not kept,the oldest is %s", back.Key)
+	}
+
+	if err := restored.Restore(strings.NewReader("garbage")); err == nil {
+		t.Fatal("restored garbage")
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	path := t.TempDir() + "/cache.snapshot"
+	chr := New(WithSnapshot(path, 0))
+	chr.Set(&cacher.CacheBlock{Key: "A", Value: "a"})
+	if err := chr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 再次关闭不能用空缓存覆盖快照
+	chr.Close()
+
+	chr = New(WithSnapshot(path, 0))
+	defer chr.Close()
+	if v, err := chr.Get("A"); err != nil || v != "a" {
+		t.Fatalf("get A %v %v", v, err)
+	}
+}
+
+func TestAOF(t *testing.T) {
+	path := t.TempDir() + "/cache.aof"
+	clock := cachertest.NewClock(time.Now())
+	open := func() *TMemoryCache {
+		return New(WithAOF(path, SyncAlways), WithInterval(0), cacher.WithClock(clock))
+	}
+
+	chr := open()
+	chr.Set(&cacher.CacheBlock{Key: "A", Value: "a"})
+	chr.Set(&cacher.CacheBlock{Key: "B", Value: "b"})
+	chr.Set(&cacher.CacheBlock{Key: "C", Value: "c", TTL: 5 * time.Second})
+	chr.Set(&cacher.CacheBlock{Key: "N", Value: 1})
+	chr.Delete("B")
+	chr.Expire("A", -1)
+	chr.Incr("N")
+
+	// 模拟崩溃:不关闭,并在文件末尾写入半条记录
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{42, 0, 0, 0, 1})
+	f.Close()
+
+	clock.Advance(10 * time.Second)
+	chr = open()
+	keys := chr.Keys()
+	sort.Strings(keys)
+	if fmt.Sprint(keys) != "[A N]" {
+		t.Fatalf("replayed keys %v", keys)
+	}
+	if v, _ := chr.Get("N"); v != 2 {
+		t.Fatalf("replayed counter %v", v)
+	}
+	if ttl, _ := chr.TTL("A"); ttl != -1 {
+		t.Fatalf("replayed ttl %v", ttl)
+	}
+
+	for i := 0; i < 100; i++ {
+		chr.Set(&cacher.CacheBlock{Key: "A", Value: i})
+	}
+	before, _ := os.Stat(path)
+	if err := chr.RewriteAOF(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Fatalf("aof is not compacted %d >= %d", after.Size(), before.Size())
+	}
+
+	// 重写后的写入追加到新文件
+	chr.Delete("N")
+	if err := chr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	chr = open()
+	defer chr.Close()
+	if v, _ := chr.Get("A"); v != 99 || chr.Exists("N") {
+		t.Fatalf("replayed after rewrite %v %v", v, chr.Keys())
+	}
+}
+
 func TestWithStruct(t *testing.T) {
 	type A struct {
 		Int    int
@@ -37,7 +416,7 @@ func TestConfig(t *testing.T) {
 	cfg.Init(
 		WithInterval(15),
 	)
-	fmt.Println(cfg)
+	fmt.Println(&cfg)
 }
 
 func TestStd(t *testing.T) {
@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+const snapshotMagic = "cacher-memory-snapshot/1"
+
+var errSnapshotFormat = errors.New("cache: not a memory snapshot")
+
+type (
+	MarshalFunc   func(any) ([]byte, error)
+	UnmarshalFunc func([]byte, *any) error
+
+	snapshotHeader struct {
+		Magic string
+		Taken time.Time
+	}
+
+	snapshotEntry struct {
+		Key        string
+		Value      []byte
+		TTL        time.Duration // the TTL of the block
+		Remaining  time.Duration // when the snapshot was taken,negative means never expire
+		LastAccess time.Time
+	}
+)
+
+// Snapshot writes all caches which are not expired to w,
+// the oldest ones first so Restore keeps the order of the gc list.
+func (self *TMemoryCache) Snapshot(w io.Writer) error {
+	now := self.config.Clock.Now()
+
+	// 只在锁内复制,编码在锁外进行
+	self.RLock()
+	self.config.GcListLock.RLock()
+	blocks := make([]*cacher.CacheBlock, 0, self.config.GcList.Len())
+	for ele := self.config.GcList.Back(); ele != nil; ele = ele.Prev() {
+		block, ok := ele.Value.(*cacher.CacheBlock)
+		if !ok || block.Key == "" || expired(block, now) {
+			continue // stack items have no key
+		}
+		blocks = append(blocks, block.Clone())
+	}
+	self.config.GcListLock.RUnlock()
+	self.RUnlock()
+
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(&snapshotHeader{Magic: snapshotMagic, Taken: now}); err != nil {
+		return err
+	}
+
+	for _, block := range blocks {
+		value, err := self.config.Marshal(block.Value)
+		if err != nil {
+			return fmt.Errorf("cache: snapshot %s: %w", block.Key, err)
+		}
+
+		entry := snapshotEntry{
+			Key:        block.Key,
+			Value:      value,
+			TTL:        block.TTL,
+			Remaining:  -1,
+			LastAccess: block.LastAccess,
+		}
+		if ttl := block.Ttl(); ttl > 0 {
+			entry.Remaining = block.LastAccess.Add(ttl).Sub(now)
+		}
+
+		if err := enc.Encode(&entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Restore loads a snapshot into the cache and skips the entries
+// which have expired since the snapshot was taken.
+// the restored caches overwrite the existing ones.
+func (self *TMemoryCache) Restore(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil || header.Magic != snapshotMagic {
+		return errSnapshotFormat
+	}
+
+	ctx := context.Background()
+	restored, skipped := 0, 0
+	for {
+		var entry snapshotEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		now := self.config.Clock.Now()
+		if entry.Remaining >= 0 && !now.Before(header.Taken.Add(entry.Remaining)) {
+			skipped++
+			continue
+		}
+
+		var value any
+		if err := self.config.Unmarshal(entry.Value, &value); err != nil {
+			return fmt.Errorf("cache: restore %s: %w", entry.Key, err)
+		}
+
+		block := &cacher.CacheBlock{Key: entry.Key, Value: value, TTL: entry.TTL}
+		if err := self.restore(ctx, block, entry.LastAccess, now); err != nil {
+			return err
+		}
+		restored++
+	}
+
+	self.config.Logger.Debug("cache: restored", "restored", restored, "skipped", skipped)
+	return nil
+}
+
+func (self *TMemoryCache) restore(ctx context.Context, block *cacher.CacheBlock, lastAccess, now time.Time) error {
+	if err := self.lock(ctx); err != nil {
+		return err
+	}
+	defer self.Unlock()
+
+	ele, _ := self.lookup(block.Key, now)
+	if err := self.store(ctx, block, ele, now); err != nil {
+		return err
+	}
+	block.LastAccess = lastAccess
+	return nil
+}
+
+// SnapshotFile writes the snapshot to a temporary file and renames it to path,
+// so a crash never leaves a broken snapshot behind.
+func (self *TMemoryCache) SnapshotFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // 重命名成功后无效
+
+	w := bufio.NewWriter(f)
+	if err := self.Snapshot(w); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// RestoreFile loads the snapshot at path,a missing file is not an error.
+func (self *TMemoryCache) RestoreFile(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return self.Restore(bufio.NewReader(f))
+}
+
+// snapshotter writes the snapshot file every SnapshotInterval until the cacher is closed.
+func (self *TMemoryCache) snapshotter() {
+	for {
+		select {
+		case <-self.config.Clock.After(self.config.SnapshotInterval):
+		case <-self.exit:
+			return
+		}
+
+		if err := self.SnapshotFile(self.config.SnapshotPath); err != nil {
+			self.config.Logger.Error("cache: snapshot failed", "path", self.config.SnapshotPath, "error", err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/volts-dev/cacher"
+)
+
+func (self *TMemoryCache) nextVersion() uint64 {
+	return atomic.AddUint64(&self.version, 1)
+}
+
+// GetWithVersion returns the cache with the version of its last write,
+// the read lock is enough since every write of a block holds the write lock.
+func (self *TMemoryCache) GetWithVersion(key string, ctx ...context.Context) (any, uint64, error) {
+	if !self.config.Active {
+		return nil, 0, cacher.ErrInactive
+	}
+
+	c := cacher.Context(ctx...)
+	if err := self.rlock(c); err != nil {
+		return nil, 0, err
+	}
+	defer self.RUnlock()
+
+	ele, has := self.lookup(key, self.config.Clock.Now())
+	if !has {
+		return nil, 0, cacher.ErrCacheMiss
+	}
+
+	block := ele.Value.(*cacher.CacheBlock)
+	return block.Value, block.Version, nil
+}
+
+// CompareAndSwap replaces the cache only if its version is still the expected one,
+// the expected version 0 means the key must not exist.
+func (self *TMemoryCache) CompareAndSwap(ctx context.Context, key string, expected uint64, block *cacher.CacheBlock) error {
+	if !self.config.Active {
+		return cacher.ErrInactive
+	}
+
+	if err := self.lock(ctx); err != nil {
+		return err
+	}
+	defer self.Unlock()
+
+	now := self.config.Clock.Now()
+	ele, has := self.lookup(key, now)
+
+	var current uint64
+	if has {
+		current = ele.Value.(*cacher.CacheBlock).Version
+	}
+
+	if current != expected {
+		return cacher.ErrVersionMismatch
+	}
+
+	bb := block.Clone()
+	bb.Key = key
+	if err := self.store(ctx, bb, ele, now); err != nil {
+		return err
+	}
+
+	block.Version = bb.Version
+	return nil
+}
+
+// Update applies the function to the cache under the lock of the cacher,
+// so the function must be quick and must not call the cacher.
+func (self *TMemoryCache) Update(ctx context.Context, key string, fn cacher.UpdateFunc) error {
+	if !self.config.Active {
+		return cacher.ErrInactive
+	}
+
+	if err := self.lock(ctx); err != nil {
+		return err
+	}
+	defer self.Unlock()
+
+	now := self.config.Clock.Now()
+	ele, has := self.lookup(key, now)
+
+	var old any
+	if has {
+		old = ele.Value.(*cacher.CacheBlock).Value
+	}
+
+	value, ttl, del := fn(old, has)
+	if del {
+		if ele != nil {
+			if err := self.logDelete(key); err != nil {
+				return err
+			}
+			if err := self.lockList(ctx); err != nil {
+				return err
+			}
+			self.config.GcList.Remove(ele)
+			self.config.GcListLock.Unlock()
+			delete(self.blocks, key)
+		}
+		return nil
+	}
+
+	return self.store(ctx, &cacher.CacheBlock{Key: key, Value: value, TTL: ttl}, ele, now)
+}
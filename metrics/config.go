@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"github.com/volts-dev/cacher"
+)
+
+type (
+	Option func(*Config)
+
+	Config struct {
+		cacher.Config
+		Name     string     // instance name label
+		Registry Registerer // registry the cacher reports to
+		Buckets  []float64  // latency histogram buckets in seconds
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+// WithName sets the instance label which distinguishs cachers of the same type.
+func WithName(name string) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("name", name)
+	}
+}
+
+// WithRegistry reports to the given registry instead of the DefaultRegistry.
+func WithRegistry(reg Registerer) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("registry", reg)
+	}
+}
+
+// WithBuckets overrides the upper bounds of the latency histograms.
+func WithBuckets(buckets ...float64) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("buckets", buckets)
+	}
+}
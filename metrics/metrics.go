@@ -0,0 +1,247 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+const (
+	OpGet    = "get"
+	OpSet    = "set"
+	OpExists = "exists"
+	OpDelete = "delete"
+	OpKeys   = "keys"
+	OpLen    = "len"
+	OpClear  = "clear"
+	OpClose  = "close"
+)
+
+var (
+	// operations which are counted
+	operations = []string{OpGet, OpSet, OpExists, OpDelete, OpKeys, OpLen, OpClear, OpClose}
+
+	// DefaultBuckets are the latency histogram upper bounds in seconds.
+	DefaultBuckets = []float64{.00005, .0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+)
+
+type (
+	// MetricsCache wraps a cacher and records every operation.
+	MetricsCache struct {
+		cacher.ICacher
+		config *Config
+		ops    map[string]*opStats
+		hits   uint64
+		misses uint64
+	}
+
+	opStats struct {
+		calls   uint64
+		errors  uint64
+		sum     uint64 // nanoseconds
+		buckets []float64
+		counts  []uint64 // non-cumulative,the last one is +Inf
+	}
+
+	// Histogram is a cumulative latency histogram.
+	Histogram struct {
+		Bounds []float64 `json:"bounds"` // upper bounds, +Inf is implied
+		Counts []uint64  `json:"counts"` // cumulative counts, the last one is +Inf
+		Count  uint64    `json:"count"`
+		Sum    float64   `json:"sum"` // seconds
+	}
+
+	// OpStats is a snapshot of one operation.
+	OpStats struct {
+		Calls   uint64    `json:"calls"`
+		Errors  uint64    `json:"errors"`
+		Latency Histogram `json:"latency"`
+	}
+
+	// Stats is a snapshot of a MetricsCache.
+	Stats struct {
+		Cacher   string             `json:"cacher"`
+		Instance string             `json:"instance"`
+		Hits     uint64             `json:"hits"`
+		Misses   uint64             `json:"misses"`
+		HitRatio float64            `json:"hit_ratio"`
+		Size     int                `json:"size"`
+		Ops      map[string]OpStats `json:"ops"`
+	}
+)
+
+// New wraps the cacher and registers it to the registry.
+func New(chr cacher.ICacher, opts ...cacher.Option) *MetricsCache {
+	cfg := &Config{}
+	cfg.Init(opts...)
+
+	if cfg.Registry == nil {
+		cfg.Registry = DefaultRegistry
+	}
+
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = DefaultBuckets
+	}
+
+	buckets := append([]float64(nil), cfg.Buckets...)
+	sort.Float64s(buckets)
+
+	c := &MetricsCache{
+		ICacher: chr,
+		config:  cfg,
+		ops:     make(map[string]*opStats, len(operations)),
+	}
+
+	for _, op := range operations {
+		c.ops[op] = &opStats{
+			buckets: buckets,
+			counts:  make([]uint64, len(buckets)+1),
+		}
+	}
+
+	cfg.Registry.Register(c)
+	return c
+}
+
+func (self *MetricsCache) Init(opts ...cacher.Option) {
+	self.ICacher.Init(opts...)
+}
+
+// Name returns the instance label.
+func (self *MetricsCache) Name() string {
+	return self.config.Name
+}
+
+// Unwrap returns the underlying cacher.
+func (self *MetricsCache) Unwrap() cacher.ICacher {
+	return self.ICacher
+}
+
+func (self *MetricsCache) Get(key string, ctx ...context.Context) (value any, err error) {
+	start := time.Now()
+	value, err = self.ICacher.Get(key, ctx...)
+	switch {
+	case err == nil:
+		atomic.AddUint64(&self.hits, 1)
+		self.observe(OpGet, start, nil)
+	case errors.Is(err, cacher.ErrCacheMiss):
+		atomic.AddUint64(&self.misses, 1)
+		self.observe(OpGet, start, nil)
+	default:
+		self.observe(OpGet, start, err)
+	}
+	return
+}
+
+func (self *MetricsCache) Set(block *cacher.CacheBlock) error {
+	start := time.Now()
+	err := self.ICacher.Set(block)
+	self.observe(OpSet, start, err)
+	return err
+}
+
+func (self *MetricsCache) Exists(key string, ctx ...context.Context) bool {
+	start := time.Now()
+	ok := self.ICacher.Exists(key, ctx...)
+	self.observe(OpExists, start, nil)
+	return ok
+}
+
+func (self *MetricsCache) Delete(key string, ctx ...context.Context) error {
+	start := time.Now()
+	err := self.ICacher.Delete(key, ctx...)
+	self.observe(OpDelete, start, err)
+	return err
+}
+
+func (self *MetricsCache) Keys(ctx ...context.Context) []string {
+	start := time.Now()
+	keys := self.ICacher.Keys(ctx...)
+	self.observe(OpKeys, start, nil)
+	return keys
+}
+
+func (self *MetricsCache) Len() int {
+	start := time.Now()
+	n := self.ICacher.Len()
+	self.observe(OpLen, start, nil)
+	return n
+}
+
+func (self *MetricsCache) Clear() error {
+	start := time.Now()
+	err := self.ICacher.Clear()
+	self.observe(OpClear, start, err)
+	return err
+}
+
+// Close closes the underlying cacher and unregisters it from the registry.
+func (self *MetricsCache) Close() error {
+	start := time.Now()
+	err := self.ICacher.Close()
+	self.observe(OpClose, start, err)
+	self.config.Registry.Unregister(self)
+	return err
+}
+
+// Stats returns a snapshot of the counters.the size is read from the underlying cacher.
+func (self *MetricsCache) Stats() Stats {
+	stats := Stats{
+		Cacher:   self.ICacher.String(),
+		Instance: self.config.Name,
+		Hits:     atomic.LoadUint64(&self.hits),
+		Misses:   atomic.LoadUint64(&self.misses),
+		Size:     self.ICacher.Len(),
+		Ops:      make(map[string]OpStats, len(self.ops)),
+	}
+
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+
+	for name, op := range self.ops {
+		stats.Ops[name] = op.snapshot()
+	}
+
+	return stats
+}
+
+func (self *MetricsCache) observe(op string, start time.Time, err error) {
+	stats := self.ops[op]
+	dur := time.Since(start)
+
+	atomic.AddUint64(&stats.calls, 1)
+	if err != nil {
+		atomic.AddUint64(&stats.errors, 1)
+	}
+	atomic.AddUint64(&stats.sum, uint64(dur))
+
+	sec := dur.Seconds()
+	idx := sort.SearchFloat64s(stats.buckets, sec) // first bound >= sec
+	atomic.AddUint64(&stats.counts[idx], 1)
+}
+
+func (self *opStats) snapshot() OpStats {
+	h := Histogram{
+		Bounds: self.buckets,
+		Counts: make([]uint64, len(self.counts)),
+		Sum:    float64(atomic.LoadUint64(&self.sum)) / float64(time.Second),
+	}
+
+	var cumulative uint64
+	for i := range self.counts {
+		cumulative += atomic.LoadUint64(&self.counts[i])
+		h.Counts[i] = cumulative
+	}
+	h.Count = cumulative
+
+	return OpStats{
+		Calls:   atomic.LoadUint64(&self.calls),
+		Errors:  atomic.LoadUint64(&self.errors),
+		Latency: h,
+	}
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/memory"
+)
+
+func TestPrometheus(t *testing.T) {
+	reg := NewRegistry()
+	chr := New(memory.New(), WithName("session"), WithRegistry(reg))
+	chr.Set(&cacher.CacheBlock{Key: "A", Value: "a"})
+	chr.Get("A")
+	chr.Get("B")
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != contentType {
+		t.Fatalf("content type %q", ct)
+	}
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		`cacher_operations_total{cacher="memory",instance="session",op="get"} 2`,
+		`cacher_operations_total{cacher="memory",instance="session",op="set"} 1`,
+		`cacher_operation_duration_seconds_count{cacher="memory",instance="session",op="get"} 2`,
+		`cacher_operation_duration_seconds_bucket{cacher="memory",instance="session",op="set",le="+Inf"} 1`,
+		`cacher_hits_total{cacher="memory",instance="session"} 1`,
+		`cacher_misses_total{cacher="memory",instance="session"} 1`,
+		`cacher_hit_ratio{cacher="memory",instance="session"} 0.5`,
+		`cacher_size{cacher="memory",instance="session"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %s\n%s", line, body)
+		}
+	}
+
+	chr.Close()
+	if len(reg.Stats()) != 0 {
+		t.Fatal("closed cacher is still registered")
+	}
+}
+
+func TestExpvar(t *testing.T) {
+	reg := NewRegistry()
+	chr := New(memory.New(), WithName("expvar"), WithRegistry(reg), WithBuckets(0.5, 0.1))
+	chr.Set(&cacher.CacheBlock{Key: "A", Value: 1})
+	chr.Get("A")
+
+	reg.Publish("cacher_test")
+	var stats []Stats
+	if err := json.Unmarshal([]byte(expvar.Get("cacher_test").String()), &stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 1 || stats[0].Hits != 1 || stats[0].Size != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	get := stats[0].Ops[OpGet].Latency
+	if len(get.Bounds) != 2 || get.Bounds[0] != 0.1 || get.Count != 1 {
+		t.Fatalf("unexpected histogram %+v", get)
+	}
+}
@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultRegistry is used by cachers which are created without WithRegistry.
+var DefaultRegistry = NewRegistry()
+
+type (
+	// Registerer collects the cachers to export.
+	// it must be an interface since the options are copied into the config by value.
+	Registerer interface {
+		Register(*MetricsCache)
+		Unregister(*MetricsCache)
+	}
+
+	// Registry holds the cachers which are exported together.
+	Registry struct {
+		sync.RWMutex
+		cachers []*MetricsCache
+	}
+)
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (self *Registry) Register(c *MetricsCache) {
+	self.Lock()
+	defer self.Unlock()
+
+	for _, exist := range self.cachers {
+		if exist == c {
+			return
+		}
+	}
+	self.cachers = append(self.cachers, c)
+}
+
+func (self *Registry) Unregister(c *MetricsCache) {
+	self.Lock()
+	defer self.Unlock()
+
+	for i, exist := range self.cachers {
+		if exist == c {
+			self.cachers = append(self.cachers[:i], self.cachers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Stats returns the snapshots of all registered cachers.
+func (self *Registry) Stats() []Stats {
+	self.RLock()
+	cachers := append([]*MetricsCache(nil), self.cachers...)
+	self.RUnlock()
+
+	stats := make([]Stats, len(cachers))
+	for i, c := range cachers {
+		stats[i] = c.Stats()
+	}
+
+	return stats
+}
+
+// Publish exports the registry as an expvar variable.
+// like expvar.Publish it panics if the name is already registered.
+func (self *Registry) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return self.Stats()
+	}))
+}
+
+// ServeHTTP serves the Prometheus text exposition format.
+func (self *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	self.WritePrometheus(w)
+}
+
+// WritePrometheus writes all metrics in Prometheus text exposition format.
+func (self *Registry) WritePrometheus(w io.Writer) error {
+	stats := self.Stats()
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Cacher != stats[j].Cacher {
+			return stats[i].Cacher < stats[j].Cacher
+		}
+		return stats[i].Instance < stats[j].Instance
+	})
+
+	b := bufio.NewWriter(w)
+
+	family(b, "cacher_operations_total", "counter", "Total number of cacher operations.")
+	eachOp(stats, func(s Stats, op string, o OpStats) {
+		sample(b, "cacher_operations_total", labels(s, "op", op), float64(o.Calls))
+	})
+
+	family(b, "cacher_operation_errors_total", "counter", "Total number of failed cacher operations.")
+	eachOp(stats, func(s Stats, op string, o OpStats) {
+		sample(b, "cacher_operation_errors_total", labels(s, "op", op), float64(o.Errors))
+	})
+
+	family(b, "cacher_operation_duration_seconds", "histogram", "Latency of cacher operations.")
+	eachOp(stats, func(s Stats, op string, o OpStats) {
+		h := o.Latency
+		for i, bound := range h.Bounds {
+			sample(b, "cacher_operation_duration_seconds_bucket", labels(s, "op", op, "le", formatFloat(bound)), float64(h.Counts[i]))
+		}
+		sample(b, "cacher_operation_duration_seconds_bucket", labels(s, "op", op, "le", "+Inf"), float64(h.Count))
+		sample(b, "cacher_operation_duration_seconds_sum", labels(s, "op", op), h.Sum)
+		sample(b, "cacher_operation_duration_seconds_count", labels(s, "op", op), float64(h.Count))
+	})
+
+	family(b, "cacher_hits_total", "counter", "Total number of Get hits.")
+	for _, s := range stats {
+		sample(b, "cacher_hits_total", labels(s), float64(s.Hits))
+	}
+
+	family(b, "cacher_misses_total", "counter", "Total number of Get misses.")
+	for _, s := range stats {
+		sample(b, "cacher_misses_total", labels(s), float64(s.Misses))
+	}
+
+	family(b, "cacher_hit_ratio", "gauge", "Ratio of Get hits to hits and misses.")
+	for _, s := range stats {
+		sample(b, "cacher_hit_ratio", labels(s), s.HitRatio)
+	}
+
+	family(b, "cacher_size", "gauge", "Number of cached items.")
+	for _, s := range stats {
+		sample(b, "cacher_size", labels(s), float64(s.Size))
+	}
+
+	return b.Flush()
+}
+
+func eachOp(stats []Stats, fn func(Stats, string, OpStats)) {
+	for _, s := range stats {
+		for _, op := range operations {
+			fn(s, op, s.Ops[op])
+		}
+	}
+}
+
+func family(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func sample(w io.Writer, name, labels string, value float64) {
+	fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatFloat(value))
+}
+
+func labels(s Stats, pairs ...string) string {
+	var b strings.Builder
+	b.WriteString(`cacher="` + escape(s.Cacher) + `",instance="` + escape(s.Instance) + `"`)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		b.WriteString("," + pairs[i] + `="` + escape(pairs[i+1]) + `"`)
+	}
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,301 @@
+package cacher
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	GetFunc         func(ctx context.Context, key string) (any, error)
+	SetFunc         func(block *CacheBlock) error
+	ExistsFunc      func(ctx context.Context, key string) bool
+	DeleteFunc      func(ctx context.Context, key string) error
+	KeysFunc        func(ctx context.Context) []string
+	LenFunc         func() int
+	ErrFunc         func() error
+	CounterFunc     func(key string) error
+	GetMultiFunc    func(ctx context.Context, keys []string) (map[string]any, error)
+	SetMultiFunc    func(blocks []*CacheBlock) error
+	DeleteMultiFunc func(ctx context.Context, keys []string) error
+	TTLFunc         func(ctx context.Context, key string) (time.Duration, error)
+	ExpireFunc      func(ctx context.Context, key string, ttl time.Duration) error
+
+	// Middleware hooks the operations of a cacher.
+	// every hook receives the next handler and returns a new one,
+	// nil hooks pass the operation through untouched.
+	Middleware struct {
+		Get    func(next GetFunc) GetFunc
+		Set    func(next SetFunc) SetFunc
+		Exists func(next ExistsFunc) ExistsFunc
+		Delete func(next DeleteFunc) DeleteFunc
+		Keys   func(next KeysFunc) KeysFunc
+		Len    func(next LenFunc) LenFunc
+		Clear  func(next ErrFunc) ErrFunc
+		Close  func(next ErrFunc) ErrFunc
+
+		// hooks of the optional interfaces,only used if the base cacher implements them.
+		Incr        func(next CounterFunc) CounterFunc
+		Decr        func(next CounterFunc) CounterFunc
+		GetMulti    func(next GetMultiFunc) GetMultiFunc
+		SetMulti    func(next SetMultiFunc) SetMultiFunc
+		DeleteMulti func(next DeleteMultiFunc) DeleteMultiFunc
+		TTL         func(next TTLFunc) TTLFunc
+		Expire      func(next ExpireFunc) ExpireFunc
+	}
+
+	// ChainCache is a cacher wrapped by middlewares.
+	ChainCache struct {
+		base ICacher
+
+		get    GetFunc
+		set    SetFunc
+		exists ExistsFunc
+		delete DeleteFunc
+		keys   KeysFunc
+		len    LenFunc
+		clear  ErrFunc
+		close  ErrFunc
+
+		incr        CounterFunc
+		decr        CounterFunc
+		getMulti    GetMultiFunc
+		setMulti    SetMultiFunc
+		deleteMulti DeleteMultiFunc
+		ttl         TTLFunc
+		expire      ExpireFunc
+	}
+
+	chainCounter struct{ chain *ChainCache }
+	chainBatcher struct{ chain *ChainCache }
+	chainTTLer   struct{ chain *ChainCache }
+)
+
+// Chain wraps the base cacher with the middlewares.
+// the first middleware is the outermost one, so its hooks run first.
+// the returned cacher implements ICounter, IBatcher and ITTLer
+// exactly when the base cacher does.
+func Chain(base ICacher, mws ...Middleware) ICacher {
+	c := &ChainCache{
+		base:   base,
+		get:    func(ctx context.Context, key string) (any, error) { return base.Get(key, ctx) },
+		set:    base.Set,
+		exists: func(ctx context.Context, key string) bool { return base.Exists(key, ctx) },
+		delete: func(ctx context.Context, key string) error { return base.Delete(key, ctx) },
+		keys:   func(ctx context.Context) []string { return base.Keys(ctx) },
+		len:    base.Len,
+		clear:  base.Clear,
+		close:  base.Close,
+	}
+
+	counter, isCounter := base.(ICounter)
+	if isCounter {
+		c.incr = counter.Incr
+		c.decr = counter.Decr
+	}
+
+	batcher, isBatcher := base.(IBatcher)
+	if isBatcher {
+		c.getMulti = func(ctx context.Context, keys []string) (map[string]any, error) { return batcher.GetMulti(keys, ctx) }
+		c.setMulti = func(blocks []*CacheBlock) error { return batcher.SetMulti(blocks...) }
+		c.deleteMulti = func(ctx context.Context, keys []string) error { return batcher.DeleteMulti(keys, ctx) }
+	}
+
+	ttler, isTTLer := base.(ITTLer)
+	if isTTLer {
+		c.ttl = func(ctx context.Context, key string) (time.Duration, error) { return ttler.TTL(key, ctx) }
+		c.expire = func(ctx context.Context, key string, ttl time.Duration) error { return ttler.Expire(key, ttl, ctx) }
+	}
+
+	// 由内向外包装
+	for i := len(mws) - 1; i >= 0; i-- {
+		c.use(&mws[i])
+	}
+
+	counterPart, batcherPart, ttlerPart := chainCounter{c}, chainBatcher{c}, chainTTLer{c}
+	switch {
+	case isCounter && isBatcher && isTTLer:
+		return struct {
+			*ChainCache
+			chainCounter
+			chainBatcher
+			chainTTLer
+		}{c, counterPart, batcherPart, ttlerPart}
+	case isCounter && isBatcher:
+		return struct {
+			*ChainCache
+			chainCounter
+			chainBatcher
+		}{c, counterPart, batcherPart}
+	case isCounter && isTTLer:
+		return struct {
+			*ChainCache
+			chainCounter
+			chainTTLer
+		}{c, counterPart, ttlerPart}
+	case isBatcher && isTTLer:
+		return struct {
+			*ChainCache
+			chainBatcher
+			chainTTLer
+		}{c, batcherPart, ttlerPart}
+	case isCounter:
+		return struct {
+			*ChainCache
+			chainCounter
+		}{c, counterPart}
+	case isBatcher:
+		return struct {
+			*ChainCache
+			chainBatcher
+		}{c, batcherPart}
+	case isTTLer:
+		return struct {
+			*ChainCache
+			chainTTLer
+		}{c, ttlerPart}
+	}
+
+	return c
+}
+
+func (self *ChainCache) use(mw *Middleware) {
+	if mw.Get != nil {
+		self.get = mw.Get(self.get)
+	}
+	if mw.Set != nil {
+		self.set = mw.Set(self.set)
+	}
+	if mw.Exists != nil {
+		self.exists = mw.Exists(self.exists)
+	}
+	if mw.Delete != nil {
+		self.delete = mw.Delete(self.delete)
+	}
+	if mw.Keys != nil {
+		self.keys = mw.Keys(self.keys)
+	}
+	if mw.Len != nil {
+		self.len = mw.Len(self.len)
+	}
+	if mw.Clear != nil {
+		self.clear = mw.Clear(self.clear)
+	}
+	if mw.Close != nil {
+		self.close = mw.Close(self.close)
+	}
+
+	if self.incr != nil {
+		if mw.Incr != nil {
+			self.incr = mw.Incr(self.incr)
+		}
+		if mw.Decr != nil {
+			self.decr = mw.Decr(self.decr)
+		}
+	}
+
+	if self.getMulti != nil {
+		if mw.GetMulti != nil {
+			self.getMulti = mw.GetMulti(self.getMulti)
+		}
+		if mw.SetMulti != nil {
+			self.setMulti = mw.SetMulti(self.setMulti)
+		}
+		if mw.DeleteMulti != nil {
+			self.deleteMulti = mw.DeleteMulti(self.deleteMulti)
+		}
+	}
+
+	if self.ttl != nil {
+		if mw.TTL != nil {
+			self.ttl = mw.TTL(self.ttl)
+		}
+		if mw.Expire != nil {
+			self.expire = mw.Expire(self.expire)
+		}
+	}
+}
+
+// Unwrap returns the base cacher.
+func (self *ChainCache) Unwrap() ICacher {
+	return self.base
+}
+
+func (self *ChainCache) String() string {
+	return self.base.String()
+}
+
+func (self *ChainCache) Init(opts ...Option) {
+	self.base.Init(opts...)
+}
+
+func (self *ChainCache) Active(open ...bool) bool {
+	return self.base.Active(open...)
+}
+
+func (self *ChainCache) Get(key string, ctx ...context.Context) (any, error) {
+	return self.get(Context(ctx...), key)
+}
+
+func (self *ChainCache) Set(block *CacheBlock) error {
+	return self.set(block)
+}
+
+func (self *ChainCache) Exists(key string, ctx ...context.Context) bool {
+	return self.exists(Context(ctx...), key)
+}
+
+func (self *ChainCache) Delete(key string, ctx ...context.Context) error {
+	return self.delete(Context(ctx...), key)
+}
+
+func (self *ChainCache) Keys(ctx ...context.Context) []string {
+	return self.keys(Context(ctx...))
+}
+
+func (self *ChainCache) Len() int {
+	return self.len()
+}
+
+func (self *ChainCache) Clear() error {
+	return self.clear()
+}
+
+func (self *ChainCache) Close() error {
+	return self.close()
+}
+
+func (self chainCounter) Incr(key string) error {
+	return self.chain.incr(key)
+}
+
+func (self chainCounter) Decr(key string) error {
+	return self.chain.decr(key)
+}
+
+func (self chainBatcher) GetMulti(keys []string, ctx ...context.Context) (map[string]any, error) {
+	return self.chain.getMulti(Context(ctx...), keys)
+}
+
+func (self chainBatcher) SetMulti(blocks ...*CacheBlock) error {
+	return self.chain.setMulti(blocks)
+}
+
+func (self chainBatcher) DeleteMulti(keys []string, ctx ...context.Context) error {
+	return self.chain.deleteMulti(Context(ctx...), keys)
+}
+
+func (self chainTTLer) TTL(key string, ctx ...context.Context) (time.Duration, error) {
+	return self.chain.ttl(Context(ctx...), key)
+}
+
+func (self chainTTLer) Expire(key string, ttl time.Duration, ctx ...context.Context) error {
+	return self.chain.expire(Context(ctx...), key, ttl)
+}
+
+// Context returns the first context or the background context.
+func Context(ctx ...context.Context) context.Context {
+	if len(ctx) > 0 && ctx[0] != nil {
+		return ctx[0]
+	}
+	return context.Background()
+}
@@ -0,0 +1,94 @@
+package cacher_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/memory"
+)
+
+func prefix(p string) cacher.Middleware {
+	return cacher.Middleware{
+		Get: func(next cacher.GetFunc) cacher.GetFunc {
+			return func(ctx context.Context, key string) (any, error) {
+				return next(ctx, p+key)
+			}
+		},
+		Set: func(next cacher.SetFunc) cacher.SetFunc {
+			return func(block *cacher.CacheBlock) error {
+				bb := block.Clone()
+				bb.Key = p + bb.Key
+				return next(bb)
+			}
+		},
+		Incr: func(next cacher.CounterFunc) cacher.CounterFunc {
+			return func(key string) error {
+				return next(p + key)
+			}
+		},
+	}
+}
+
+func TestChain(t *testing.T) {
+	base := memory.New()
+	var order []string
+	trace := func(name string) cacher.Middleware {
+		return cacher.Middleware{
+			Get: func(next cacher.GetFunc) cacher.GetFunc {
+				return func(ctx context.Context, key string) (any, error) {
+					order = append(order, name+":"+key)
+					return next(ctx, key)
+				}
+			},
+		}
+	}
+
+	chr := cacher.Chain(base, trace("outer"), prefix("app:"), trace("inner"))
+	chr.Set(&cacher.CacheBlock{Key: "A", Value: 1})
+
+	if !base.Exists("app:A") {
+		t.Fatalf("key is not rewritten %v", base.Keys())
+	}
+
+	v, err := chr.Get("A")
+	if err != nil || v != 1 {
+		t.Fatalf("get %v %v", v, err)
+	}
+
+	if len(order) != 2 || order[0] != "outer:A" || order[1] != "inner:app:A" {
+		t.Fatalf("unexpected order %v", order)
+	}
+
+	counter, ok := chr.(cacher.ICounter)
+	if !ok {
+		t.Fatal("counter is not passed through")
+	}
+	if err := counter.Incr("A"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := base.Get("app:A"); v != 2 {
+		t.Fatalf("incr %v", v)
+	}
+
+	if _, ok := chr.(cacher.IBatcher); !ok {
+		t.Fatal("batcher is not passed through")
+	}
+	if _, ok := chr.(cacher.ITTLer); !ok {
+		t.Fatal("ttler is not passed through")
+	}
+}
+
+type plain struct {
+	cacher.ICacher
+}
+
+func TestChainOptional(t *testing.T) {
+	chr := cacher.Chain(plain{memory.New()}, prefix("app:"))
+	if _, ok := chr.(cacher.ICounter); ok {
+		t.Fatal("counter must not be exposed when the base lacks it")
+	}
+	if _, ok := chr.(cacher.IBatcher); ok {
+		t.Fatal("batcher must not be exposed when the base lacks it")
+	}
+}
@@ -0,0 +1,90 @@
+// Package noop is a cacher which stores nothing,every Get misses and every Set succeeds.
+// it disables the caching of an environment without changing the call sites:
+//
+//	chr, _ := cacher.New("noop")
+package noop
+
+import (
+	"context"
+
+	"github.com/volts-dev/cacher"
+)
+
+var Noop = cacher.Register("Noop", func() cacher.ICacher {
+	return New()
+})
+
+type (
+	Config struct {
+		cacher.Config
+		Active bool
+	}
+
+	NoopCache struct {
+		config *Config
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+func New(opts ...cacher.Option) *NoopCache {
+	cfg := &Config{
+		Active: true,
+	}
+	cfg.Init(opts...)
+
+	return &NoopCache{config: cfg}
+}
+
+func (self *NoopCache) Init(opts ...cacher.Option) {
+	self.config.Init(opts...)
+}
+
+func (self *NoopCache) String() string {
+	return "noop"
+}
+
+func (self *NoopCache) Active(on ...bool) bool {
+	if len(on) > 0 {
+		self.config.Active = on[0]
+	}
+
+	return self.config.Active
+}
+
+func (self *NoopCache) Get(key string, ctx ...context.Context) (any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+	return nil, cacher.ErrCacheMiss
+}
+
+func (self *NoopCache) Set(block *cacher.CacheBlock) error {
+	return nil
+}
+
+func (self *NoopCache) Exists(key string, ctx ...context.Context) bool {
+	return false
+}
+
+func (self *NoopCache) Delete(key string, ctx ...context.Context) error {
+	return nil
+}
+
+func (self *NoopCache) Keys(ctx ...context.Context) []string {
+	return nil
+}
+
+func (self *NoopCache) Len() int {
+	return 0
+}
+
+func (self *NoopCache) Clear() error {
+	return nil
+}
+
+func (self *NoopCache) Close() error {
+	return nil
+}
@@ -0,0 +1,32 @@
+package noop
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/volts-dev/cacher"
+)
+
+func TestNoop(t *testing.T) {
+	chr, err := cacher.New("noop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chr.Set(&cacher.CacheBlock{Key: "key", Value: "value"}); err != nil {
+		t.Fatalf("set %v", err)
+	}
+
+	if _, err := chr.Get("key"); !errors.Is(err, cacher.ErrCacheMiss) {
+		t.Fatalf("get %v", err)
+	}
+
+	if chr.Exists("key") || chr.Len() != 0 || len(chr.Keys()) != 0 {
+		t.Fatal("noop is not empty")
+	}
+
+	chr.Active(false)
+	if _, err := chr.Get("key"); !errors.Is(err, cacher.ErrInactive) {
+		t.Fatalf("get when inactive %v", err)
+	}
+}
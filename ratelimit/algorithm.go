@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"strconv"
+	"strings"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+// the scripts are called with ARGV[1] now in microseconds, ARGV[2] n
+// and return {allowed, remaining, retry in microseconds}.
+// string.format keeps the big timestamps from being written as floats.
+const (
+	// ARGV[3] limit ARGV[4] ttl of the window
+	fixedWindowSrc = `local n, limit, ttl = tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+local count = tonumber(redis.call("get", KEYS[1]) or "0")
+if count + n > limit then
+	return {0, limit - count, ttl}
+end
+redis.call("set", KEYS[1], count + n, "px", math.max(1, math.ceil(ttl / 1000)))
+return {1, limit - count - n, 0}`
+
+	// ARGV[3] limit ARGV[4] window
+	slidingWindowSrc = `local now, n, limit, window = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+local log = {}
+for t in string.gmatch(redis.call("get", KEYS[1]) or "", "%d+") do
+	t = tonumber(t)
+	if t > now - window then
+		table.insert(log, t)
+	end
+end
+if #log + n > limit then
+	return {0, limit - #log, log[#log + n - limit] + window - now}
+end
+for i = 1, n do
+	table.insert(log, now)
+end
+for i = 1, #log do
+	log[i] = string.format("%d", log[i])
+end
+redis.call("set", KEYS[1], table.concat(log, " "), "px", math.max(1, math.ceil(window / 1000)))
+return {1, limit - #log, 0}`
+
+	// ARGV[3] emission interval ARGV[4] tolerance
+	gcraSrc = `local now, n, interval, tolerance = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+local tat = math.max(tonumber(redis.call("get", KEYS[1]) or "0"), now)
+local new_tat = tat + n * interval
+if now < new_tat - tolerance then
+	return {0, math.floor((tolerance - (tat - now)) / interval), new_tat - tolerance - now}
+end
+redis.call("set", KEYS[1], string.format("%d", new_tat), "px", math.max(1, math.ceil((new_tat - now) / 1000)))
+return {1, math.floor((tolerance - (new_tat - now)) / interval), 0}`
+)
+
+var (
+	fixedWindowScript   = redis.NewScript(fixedWindowSrc)
+	slidingWindowScript = redis.NewScript(slidingWindowSrc)
+	gcraScript          = redis.NewScript(gcraSrc)
+)
+
+type (
+	fixedWindow struct {
+		limit  int64
+		window int64
+	}
+
+	slidingWindow struct {
+		limit  int64
+		window int64
+	}
+
+	// gcra is the generic cell rate algorithm,
+	// the state is the theoretical arrival time of the next request.
+	gcra struct {
+		interval  int64 // between two requests at the steady rate
+		tolerance int64 // interval * burst
+	}
+)
+
+func (self *fixedWindow) name() string {
+	return "fixed"
+}
+
+func (self *fixedWindow) key(key string, now int64) string {
+	return key + ":" + strconv.FormatInt(now/self.window, 10)
+}
+
+func (self *fixedWindow) script() *redis.Script {
+	return fixedWindowScript
+}
+
+func (self *fixedWindow) args(now int64) []any {
+	return []any{self.limit, self.window - now%self.window}
+}
+
+func (self *fixedWindow) step(state string, now int64, n int64) (string, int64, reply) {
+	count, _ := strconv.ParseInt(state, 10, 64)
+	ttl := self.window - now%self.window
+	if count+n > self.limit {
+		return state, ttl, reply{remaining: self.limit - count, retry: ttl}
+	}
+	return strconv.FormatInt(count+n, 10), ttl, reply{allowed: true, remaining: self.limit - count - n}
+}
+
+func (self *slidingWindow) name() string {
+	return "sliding"
+}
+
+func (self *slidingWindow) key(key string, now int64) string {
+	return key
+}
+
+func (self *slidingWindow) script() *redis.Script {
+	return slidingWindowScript
+}
+
+func (self *slidingWindow) args(now int64) []any {
+	return []any{self.limit, self.window}
+}
+
+func (self *slidingWindow) step(state string, now int64, n int64) (string, int64, reply) {
+	var log []string
+	for _, field := range strings.Fields(state) {
+		if t, err := strconv.ParseInt(field, 10, 64); err == nil && t > now-self.window {
+			log = append(log, field)
+		}
+	}
+
+	count := int64(len(log))
+	if count+n > self.limit {
+		// 等待足够多的旧请求滑出窗口
+		oldest, _ := strconv.ParseInt(log[count+n-self.limit-1], 10, 64)
+		return strings.Join(log, " "), self.window, reply{remaining: self.limit - count, retry: oldest + self.window - now}
+	}
+
+	for i := int64(0); i < n; i++ {
+		log = append(log, strconv.FormatInt(now, 10))
+	}
+	return strings.Join(log, " "), self.window, reply{allowed: true, remaining: self.limit - count - n}
+}
+
+func (self *gcra) name() string {
+	return "gcra"
+}
+
+func (self *gcra) key(key string, now int64) string {
+	return key
+}
+
+func (self *gcra) script() *redis.Script {
+	return gcraScript
+}
+
+func (self *gcra) args(now int64) []any {
+	return []any{self.interval, self.tolerance}
+}
+
+func (self *gcra) step(state string, now int64, n int64) (string, int64, reply) {
+	tat, _ := strconv.ParseInt(state, 10, 64)
+	if tat < now {
+		tat = now
+	}
+
+	next := tat + n*self.interval
+	if now < next-self.tolerance {
+		return strconv.FormatInt(tat, 10), max(tat-now, 1), reply{
+			remaining: (self.tolerance - (tat - now)) / self.interval,
+			retry:     next - self.tolerance - now,
+		}
+	}
+	return strconv.FormatInt(next, 10), next - now, reply{allowed: true, remaining: (self.tolerance - (next - now)) / self.interval}
+}
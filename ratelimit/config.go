@@ -0,0 +1,26 @@
+package ratelimit
+
+import (
+	"github.com/volts-dev/cacher"
+)
+
+type (
+	Option func(*Config)
+
+	Config struct {
+		cacher.Config
+		Name  string       // namespace of the keys,default is the name of the algorithm
+		Clock cacher.Clock // the time sent to the backend
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+// WithName puts the keys under "ratelimit:<name>:" so limiters can share a cacher.
+func WithName(name string) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("name", name)
+	}
+}
@@ -0,0 +1,183 @@
+// Package ratelimit limits the rate of requests with the state kept in a cacher.
+//
+// the redis cacher runs every step in a Lua script,
+// other cachers must implement cacher.IUpdater,e.g. the memory cacher runs it under its lock.
+//
+// usage:
+//
+//	limiter := ratelimit.NewTokenBucket(chr, 10, time.Second, 20)
+//	res, err := limiter.Allow(ctx, userID)
+//	if err == nil && !res.Allowed {
+//		w.Header().Set("Retry-After", fmt.Sprint(int(res.RetryAfter.Seconds())+1))
+//	}
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/volts-dev/cacher"
+)
+
+// the state is kept under it,in front of the prefix of the redis cacher
+const keyPrefix = "ratelimit:"
+
+var (
+	// ErrExceedsLimit is returned if more requests are asked at once than the limit allows.
+	ErrExceedsLimit = errors.New("ratelimit: n exceeds the limit")
+
+	// ErrNoBackend is returned if the cacher can neither run scripts nor Update atomically.
+	ErrNoBackend = errors.New("ratelimit: the cacher supports neither scripts nor Update")
+)
+
+type (
+	// Result is the decision of a limiter.
+	Result struct {
+		Allowed    bool
+		Limit      int
+		Remaining  int           // requests left in the current window or bucket
+		RetryAfter time.Duration // when the request would be allowed,0 if allowed
+	}
+
+	// Limiter limits the requests per key.
+	Limiter struct {
+		config *Config
+		chr    cacher.ICacher
+		algo   algorithm
+		limit  int
+	}
+
+	// scripter is implemented by the redis cacher.
+	scripter interface {
+		EvalIn(ctx context.Context, namespace string, script *redis.Script, keys []string, args ...any) (any, error)
+	}
+
+	// algorithm is a step on the state of a key,which is a string so it survives every codec.
+	// the times are unix microseconds.
+	algorithm interface {
+		name() string
+		// key of the state,e.g. fixed windows are keyed by the window.
+		key(key string, now int64) string
+		script() *redis.Script
+		// args of the script after now and n
+		args(now int64) []any
+		// step is the Go version of the script,it returns the next state and its TTL.
+		step(state string, now int64, n int64) (next string, ttl int64, r reply)
+	}
+
+	// reply of a step,it is also what the scripts return.
+	reply struct {
+		allowed   bool
+		remaining int64
+		retry     int64 // microseconds
+	}
+)
+
+func newLimiter(chr cacher.ICacher, algo algorithm, limit int, opts []cacher.Option) *Limiter {
+	cfg := &Config{}
+	cfg.Init(opts...)
+
+	if cfg.Name == "" {
+		cfg.Name = algo.name()
+	}
+
+	if cfg.Clock == nil {
+		cfg.Clock = cacher.SystemClock
+	}
+
+	return &Limiter{
+		config: cfg,
+		chr:    chr,
+		algo:   algo,
+		limit:  limit,
+	}
+}
+
+// NewFixedWindow allows limit requests per window,the windows are aligned to the clock.
+func NewFixedWindow(chr cacher.ICacher, limit int, window time.Duration, opts ...cacher.Option) *Limiter {
+	return newLimiter(chr, &fixedWindow{limit: int64(limit), window: window.Microseconds()}, limit, opts)
+}
+
+// NewSlidingWindow allows limit requests in any window,it logs the time of every request.
+func NewSlidingWindow(chr cacher.ICacher, limit int, window time.Duration, opts ...cacher.Option) *Limiter {
+	return newLimiter(chr, &slidingWindow{limit: int64(limit), window: window.Microseconds()}, limit, opts)
+}
+
+// NewTokenBucket allows rate requests per period with bursts up to burst requests,
+// it is implemented by GCRA which keeps only a timestamp per key.
+// burst defaults to rate.
+func NewTokenBucket(chr cacher.ICacher, rate int, period time.Duration, burst int, opts ...cacher.Option) *Limiter {
+	if burst <= 0 {
+		burst = rate
+	}
+
+	interval := period.Microseconds() / int64(rate)
+	return newLimiter(chr, &gcra{interval: interval, tolerance: interval * int64(burst)}, burst, opts)
+}
+
+// Allow is AllowN(ctx, key, 1).
+func (self *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	return self.AllowN(ctx, key, 1)
+}
+
+// AllowN reports whether n requests may happen now and records them if so.
+func (self *Limiter) AllowN(ctx context.Context, key string, n int) (Result, error) {
+	res := Result{Limit: self.limit}
+	if n > self.limit {
+		return res, ErrExceedsLimit
+	}
+
+	now := self.config.Clock.Now().UnixMicro()
+	key = self.algo.key(self.config.Name+":"+key, now)
+
+	var r reply
+	switch backend := self.chr.(type) {
+	case scripter:
+		v, err := backend.EvalIn(ctx, keyPrefix, self.algo.script(), []string{key}, append([]any{now, n}, self.algo.args(now)...)...)
+		if err != nil {
+			return res, err
+		}
+		if r, err = parseReply(v); err != nil {
+			return res, err
+		}
+
+	case cacher.IUpdater:
+		err := backend.Update(ctx, keyPrefix+key, func(old any, exists bool) (any, time.Duration, bool) {
+			state, _ := old.(string)
+			next, ttl, rr := self.algo.step(state, now, int64(n))
+			r = rr
+			// the steps only depend on the time,so keeping the state longer is harmless
+			return next, max(time.Duration(ttl)*time.Microsecond, time.Second), false
+		})
+		if err != nil {
+			return res, err
+		}
+
+	default:
+		return res, ErrNoBackend
+	}
+
+	res.Allowed = r.allowed
+	res.Remaining = int(r.remaining)
+	res.RetryAfter = time.Duration(r.retry) * time.Microsecond
+	return res, nil
+}
+
+// parseReply reads the {allowed, remaining, retry} array returned by the scripts.
+func parseReply(v any) (reply, error) {
+	values, ok := v.([]any)
+	if !ok || len(values) != 3 {
+		return reply{}, fmt.Errorf("ratelimit: unexpected reply %v", v)
+	}
+
+	ints := make([]int64, len(values))
+	for i, value := range values {
+		if ints[i], ok = value.(int64); !ok {
+			return reply{}, fmt.Errorf("ratelimit: unexpected reply %v", v)
+		}
+	}
+	return reply{allowed: ints[0] == 1, remaining: ints[1], retry: ints[2]}, nil
+}
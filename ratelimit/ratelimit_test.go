@@ -0,0 +1,219 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/cachertest"
+	"github.com/volts-dev/cacher/memory"
+	"github.com/volts-dev/cacher/redis"
+	"github.com/volts-dev/cacher/redis/redistest"
+)
+
+// 对齐到窗口的开始,让固定窗口的测试稳定
+var start = time.Unix(1699999980, 0)
+
+func allow(t *testing.T, l *Limiter, want bool, remaining int) Result {
+	t.Helper()
+	res, err := l.Allow(context.Background(), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed != want || res.Remaining != remaining {
+		t.Fatalf("got allowed %v remaining %d,want %v %d", res.Allowed, res.Remaining, want, remaining)
+	}
+	return res
+}
+
+// testLimiters runs the algorithms against the cachers returned by the factory.
+func testLimiters(t *testing.T, factory func(t *testing.T, clock cacher.Clock) cacher.ICacher) {
+	t.Run("FixedWindow", func(t *testing.T) {
+		clock := cachertest.NewClock(start)
+		l := NewFixedWindow(factory(t, clock), 2, time.Minute, cacher.WithClock(clock))
+
+		allow(t, l, true, 1)
+		clock.Advance(30 * time.Second)
+		allow(t, l, true, 0)
+		if res := allow(t, l, false, 0); res.RetryAfter != 30*time.Second {
+			t.Fatalf("retry after %v", res.RetryAfter)
+		}
+
+		clock.Advance(30 * time.Second)
+		allow(t, l, true, 1)
+	})
+
+	t.Run("SlidingWindow", func(t *testing.T) {
+		clock := cachertest.NewClock(start)
+		l := NewSlidingWindow(factory(t, clock), 2, time.Minute, cacher.WithClock(clock))
+
+		allow(t, l, true, 1)
+		clock.Advance(30 * time.Second)
+		allow(t, l, true, 0)
+		if res := allow(t, l, false, 0); res.RetryAfter != 30*time.Second {
+			t.Fatalf("retry after %v", res.RetryAfter)
+		}
+
+		// 只有第一个请求滑出了窗口
+		clock.Advance(30 * time.Second)
+		allow(t, l, true, 0)
+		allow(t, l, false, 0)
+	})
+
+	t.Run("TokenBucket", func(t *testing.T) {
+		clock := cachertest.NewClock(start)
+		l := NewTokenBucket(factory(t, clock), 10, time.Second, 3, cacher.WithClock(clock))
+
+		allow(t, l, true, 2)
+		allow(t, l, true, 1)
+		allow(t, l, true, 0)
+		if res := allow(t, l, false, 0); res.RetryAfter != 100*time.Millisecond {
+			t.Fatalf("retry after %v", res.RetryAfter)
+		}
+
+		clock.Advance(100 * time.Millisecond)
+		allow(t, l, true, 0)
+		clock.Advance(time.Second)
+		allow(t, l, true, 2)
+	})
+
+	t.Run("ExceedsLimit", func(t *testing.T) {
+		l := NewFixedWindow(factory(t, cacher.SystemClock), 2, time.Minute)
+		if _, err := l.AllowN(context.Background(), "user", 3); err != ErrExceedsLimit {
+			t.Fatalf("expected ErrExceedsLimit, got %v", err)
+		}
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testLimiters(t, func(t *testing.T, clock cacher.Clock) cacher.ICacher {
+		chr := memory.New(cacher.WithClock(clock))
+		t.Cleanup(func() { chr.Close() })
+		return chr
+	})
+}
+
+func TestRedis(t *testing.T) {
+	testLimiters(t, func(t *testing.T, clock cacher.Clock) cacher.ICacher {
+		srv := redistest.Run(t)
+		srv.SetClock(clock)
+
+		rdb := goredis.NewClient(&goredis.Options{Addr: srv.Addr()})
+		t.Cleanup(func() { rdb.Close() })
+		return redis.New(redis.WithRedis(rdb))
+	})
+}
+
+func TestRedisWindowExpiry(t *testing.T) {
+	clock := cachertest.NewClock(start.Add(20 * time.Second))
+	srv := redistest.Run(t)
+	srv.SetClock(clock)
+	rdb := goredis.NewClient(&goredis.Options{Addr: srv.Addr()})
+	defer rdb.Close()
+
+	l := NewFixedWindow(redis.New(redis.WithRedis(rdb)), 2, time.Minute, cacher.WithClock(clock))
+	allow(t, l, true, 1)
+
+	// 计数在窗口结束时过期,而不是一个完整窗口之后
+	keys := srv.Keys()
+	if len(keys) != 1 {
+		t.Fatalf("keys %v", keys)
+	}
+	if ttl := rdb.PTTL(context.Background(), keys[0]).Val(); ttl != 40*time.Second {
+		t.Fatalf("ttl of the window %v", ttl)
+	}
+}
+
+func TestRedisNamespace(t *testing.T) {
+	clock := cachertest.NewClock(start)
+	srv := redistest.Run(t)
+	srv.SetClock(clock)
+	rdb := goredis.NewClient(&goredis.Options{Addr: srv.Addr()})
+	defer rdb.Close()
+
+	chr := redis.New(redis.WithRedis(rdb), redis.WithPrefix("app:"))
+	l := NewFixedWindow(chr, 2, time.Minute, cacher.WithClock(clock))
+	allow(t, l, true, 1)
+
+	// 限流状态不在缓存的前缀下,清空缓存不会重置限额
+	if keys := chr.Keys(); len(keys) != 0 {
+		t.Fatalf("limiter state is listed as cache keys %q", keys)
+	}
+	if err := chr.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	allow(t, l, true, 0)
+
+	if keys := srv.Keys(); len(keys) != 1 || !strings.HasPrefix(keys[0], "ratelimit:app:") {
+		t.Fatalf("keys %q", keys)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	const workers, limit = 16, 50
+
+	for name, newLimiter := range map[string]func(chr cacher.ICacher, clock cacher.Clock) *Limiter{
+		"FixedWindow": func(chr cacher.ICacher, clock cacher.Clock) *Limiter {
+			return NewFixedWindow(chr, limit, time.Hour, cacher.WithClock(clock))
+		},
+		"SlidingWindow": func(chr cacher.ICacher, clock cacher.Clock) *Limiter {
+			return NewSlidingWindow(chr, limit, time.Hour, cacher.WithClock(clock))
+		},
+		"TokenBucket": func(chr cacher.ICacher, clock cacher.Clock) *Limiter {
+			return NewTokenBucket(chr, 1, time.Hour, limit, cacher.WithClock(clock))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := cachertest.NewClock(start)
+			chr := memory.New(cacher.WithClock(clock))
+			defer chr.Close()
+			l := newLimiter(chr, clock)
+
+			// 读取限流状态的键,让-race检查Update与其他操作的锁
+			done := make(chan struct{})
+			go func() {
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					for _, key := range chr.Keys() {
+						chr.Get(key)
+						chr.Exists(key)
+					}
+				}
+			}()
+			defer close(done)
+
+			var allowed int64
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < limit; i++ {
+						res, err := l.Allow(context.Background(), "user")
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						if res.Allowed {
+							atomic.AddInt64(&allowed, 1)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			if allowed != limit {
+				t.Fatalf("allowed %d want %d", allowed, limit)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Package readonly wraps a cacher so its data can be read but not changed,
+// e.g. to serve a frozen dataset. the mutations return cacher.ErrReadOnly.
+//
+//	chr, _ := cacher.New("readonly")
+//	chr.Init(readonly.WithCacher(frozen))
+//
+// the wrapped cacher belongs to the caller,the view never closes or deactivates it.
+package readonly
+
+import (
+	"context"
+	"errors"
+
+	"github.com/volts-dev/cacher"
+)
+
+var ReadOnly = cacher.Register("ReadOnly", func() cacher.ICacher {
+	return New()
+})
+
+type (
+	Config struct {
+		cacher.Config
+		Active bool
+		Cacher cacher.ICacher // the wrapped cacher,nothing is found without it
+	}
+
+	ReadOnlyCache struct {
+		config *Config
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+// WithCacher sets the wrapped cacher.
+func WithCacher(chr cacher.ICacher) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("cacher", chr)
+	}
+}
+
+func New(opts ...cacher.Option) *ReadOnlyCache {
+	cfg := &Config{
+		Active: true,
+	}
+	cfg.Init(opts...)
+
+	return &ReadOnlyCache{config: cfg}
+}
+
+// Wrap returns the read only view of the cacher.
+func Wrap(chr cacher.ICacher) *ReadOnlyCache {
+	return New(WithCacher(chr))
+}
+
+func (self *ReadOnlyCache) Init(opts ...cacher.Option) {
+	self.config.Init(opts...)
+}
+
+func (self *ReadOnlyCache) String() string {
+	if self.config.Cacher == nil {
+		return "readonly"
+	}
+	return "readonly(" + self.config.Cacher.String() + ")"
+}
+
+// Active switches the view only,the wrapped cacher keeps its state.
+func (self *ReadOnlyCache) Active(on ...bool) bool {
+	if len(on) > 0 {
+		self.config.Active = on[0]
+	}
+
+	return self.config.Active
+}
+
+func (self *ReadOnlyCache) Get(key string, ctx ...context.Context) (any, error) {
+	if self.config.Cacher == nil {
+		return nil, cacher.ErrCacheMiss
+	}
+	return self.config.Cacher.Get(key, ctx...)
+}
+
+func (self *ReadOnlyCache) Set(block *cacher.CacheBlock) error {
+	return cacher.ErrReadOnly
+}
+
+func (self *ReadOnlyCache) Exists(key string, ctx ...context.Context) bool {
+	if self.config.Cacher == nil {
+		return false
+	}
+	return self.config.Cacher.Exists(key, ctx...)
+}
+
+func (self *ReadOnlyCache) Delete(key string, ctx ...context.Context) error {
+	return cacher.ErrReadOnly
+}
+
+func (self *ReadOnlyCache) Keys(ctx ...context.Context) []string {
+	if self.config.Cacher == nil {
+		return nil
+	}
+	return self.config.Cacher.Keys(ctx...)
+}
+
+func (self *ReadOnlyCache) Len() int {
+	if self.config.Cacher == nil {
+		return 0
+	}
+	return self.config.Cacher.Len()
+}
+
+func (self *ReadOnlyCache) Clear() error {
+	return cacher.ErrReadOnly
+}
+
+// Close does nothing,the wrapped cacher is closed by its owner.
+func (self *ReadOnlyCache) Close() error {
+	return nil
+}
+
+// GetMulti reads the keys in one batch if the wrapped cacher supports it.
+func (self *ReadOnlyCache) GetMulti(keys []string, ctx ...context.Context) (map[string]any, error) {
+	if batcher, ok := self.config.Cacher.(cacher.IBatcher); ok {
+		return batcher.GetMulti(keys, ctx...)
+	}
+
+	values := make(map[string]any, len(keys))
+	for _, key := range keys {
+		value, err := self.Get(key, ctx...)
+		if errors.Is(err, cacher.ErrCacheMiss) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (self *ReadOnlyCache) SetMulti(blocks ...*cacher.CacheBlock) error {
+	return cacher.ErrReadOnly
+}
+
+func (self *ReadOnlyCache) DeleteMulti(keys []string, ctx ...context.Context) error {
+	return cacher.ErrReadOnly
+}
@@ -0,0 +1,61 @@
+package readonly
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/memory"
+)
+
+func TestReadOnly(t *testing.T) {
+	frozen := memory.New()
+	frozen.Set(&cacher.CacheBlock{Key: "key", Value: "value"})
+
+	chr, err := cacher.New("readonly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chr.Get("key"); !errors.Is(err, cacher.ErrCacheMiss) {
+		t.Fatalf("get without a cacher %v", err)
+	}
+
+	chr.Init(WithCacher(frozen))
+	if v, err := chr.Get("key"); err != nil || v != "value" {
+		t.Fatalf("get %v %v", v, err)
+	}
+	if !chr.Exists("key") || chr.Len() != 1 {
+		t.Fatal("key is not visible")
+	}
+
+	for name, err := range map[string]error{
+		"set":    chr.Set(&cacher.CacheBlock{Key: "key", Value: "other"}),
+		"delete": chr.Delete("key"),
+		"clear":  chr.Clear(),
+	} {
+		if !errors.Is(err, cacher.ErrReadOnly) {
+			t.Fatalf("%s: expected ErrReadOnly, got %v", name, err)
+		}
+	}
+
+	values, err := chr.(cacher.IBatcher).GetMulti([]string{"key", "missing"})
+	if err != nil || len(values) != 1 {
+		t.Fatalf("get multi %v %v", values, err)
+	}
+
+	if v, _ := frozen.Get("key"); v != "value" {
+		t.Fatalf("frozen cacher is changed to %v", v)
+	}
+
+	// 视图的开关和关闭不影响被包装的缓存
+	chr.Active(false)
+	if chr.Active() || !frozen.Active() {
+		t.Fatal("active is forwarded to the wrapped cacher")
+	}
+	if err := chr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := frozen.Get("key"); err != nil || v != "value" {
+		t.Fatalf("wrapped cacher is closed %v %v", v, err)
+	}
+}
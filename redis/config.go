@@ -2,18 +2,14 @@ package redis
 
 import (
 	"context"
+	"time"
 
 	"github.com/volts-dev/cacher"
 )
 
 const (
-	compressionThreshold = 64
-	timeLen              = 4
-)
-
-const (
-	noCompression = 0x0
-	s2Compression = 0x1
+	clearBatch       = 512 // keys deleted per DEL by Clear
+	defaultBatchSize = 128 // commands per pipeline of the auto batch
 )
 
 type (
@@ -26,15 +22,22 @@ type (
 		cacher.Config
 		Active       bool
 		SecretKey    []byte
-		LocalCache   cacher.ICacher
-		prefix       string
-		cli          rediser
+		LocalCache   cacher.ICacher `field:"local_cache"`
+		Prefix       string         // every key is stored under the prefix,e.g. "app:"
+		Client       rediser        `field:"cli"` // must be exported to be set by WithRedis
 		context      context.Context
 		StatsEnabled bool
 		hits         uint64
 		misses       uint64
 		Marshal      MarshalFunc
 		Unmarshal    UnmarshalFunc
+		Logger       cacher.Logger
+		// UpdateRetries limits the optimistic transactions of Update.
+		UpdateRetries int `field:"update_retries"`
+		// BatchWindow enables the auto batch,the concurrent writes within the window
+		// are sent in one pipeline of at most BatchSize commands.
+		BatchWindow time.Duration `field:"batch_window"`
+		BatchSize   int           `field:"batch_size"`
 	}
 )
 
@@ -53,3 +56,27 @@ func WithLocalCacher(chr cacher.ICacher) cacher.Option {
 		cfg.SetByField("local_cache", chr)
 	}
 }
+
+// WithUpdateRetries limits how many times Update retries a conflicting transaction.
+func WithUpdateRetries(n int) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("update_retries", n)
+	}
+}
+
+// WithAutoBatch coalesces the concurrent Set and Delete calls within the window into one pipeline
+// of at most size commands,every caller still waits for its own result.
+// it raises the throughput of busy services at the cost of up to window latency per write.
+func WithAutoBatch(window time.Duration, size int) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("batch_window", window)
+		cfg.SetByField("batch_size", size)
+	}
+}
+
+// WithPrefix stores every key under the prefix,Clear only deletes the keys under it.
+func WithPrefix(prefix string) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("prefix", prefix)
+	}
+}
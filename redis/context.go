@@ -0,0 +1,118 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/volts-dev/cacher"
+)
+
+func (self *RedisCache) GetCtx(ctx context.Context, key string) (any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	return self.get(key, false, ctx)
+}
+
+func (self *RedisCache) SetCtx(ctx context.Context, block *cacher.CacheBlock) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	bb := block.Clone()
+	bb.Ctx = ctx
+	return self.Set(bb)
+}
+
+func (self *RedisCache) ExistsCtx(ctx context.Context, key string) (bool, error) {
+	_, err := self.GetCtx(ctx, key)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, cacher.ErrCacheMiss), errors.Is(err, cacher.ErrInactive):
+		return false, nil
+	}
+	return false, err
+}
+
+func (self *RedisCache) DeleteCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return self.Delete(key, ctx)
+}
+
+// KeysCtx scans the keys under the prefix and returns them without it.
+// the keys scanned before an error are returned with it.
+func (self *RedisCache) KeysCtx(ctx context.Context) ([]string, error) {
+	if self.config.Client == nil {
+		if self.config.LocalCache == nil {
+			return nil, errRedisLocalCacheNil
+		}
+		return self.config.LocalCache.Keys(ctx), nil
+	}
+
+	keys, err := self.scan(ctx)
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, self.config.Prefix)
+	}
+	return keys, err
+}
+
+// scan returns the stored keys under the prefix.
+func (self *RedisCache) scan(ctx context.Context) ([]string, error) {
+	var cursor uint64
+	var keys, lst []string
+	for {
+		var err error
+		lst, cursor, err = self.config.Client.Scan(ctx, cursor, escapeGlob(self.config.Prefix)+"*", 0).Result()
+		if err != nil {
+			return keys, err
+		}
+
+		keys = append(keys, lst...)
+
+		if cursor == 0 { // no more keys
+			break
+		}
+	}
+
+	return keys, nil
+}
+
+// escapeGlob escapes the special characters of a SCAN pattern.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (self *RedisCache) LenCtx(ctx context.Context) (int, error) {
+	keys, err := self.KeysCtx(ctx)
+	return len(keys), err
+}
+
+func (self *RedisCache) ClearCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return self.Clear()
+}
+
+func (self *RedisCache) CloseCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return self.Close()
+}
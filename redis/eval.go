@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"context"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/volts-dev/cacher"
+)
+
+// Eval runs the script by EVALSHA and falls back to EVAL,
+// the keys are put under the prefix of the cacher.
+func (self *RedisCache) Eval(ctx context.Context, script *redis.Script, keys []string, args ...any) (any, error) {
+	return self.EvalIn(ctx, "", script, keys, args...)
+}
+
+// EvalIn is Eval with the keys put under the namespace in front of the prefix,
+// other packages keep their state by it out of Keys and Clear of the cacher,e.g. the rate limiters.
+func (self *RedisCache) EvalIn(ctx context.Context, namespace string, script *redis.Script, keys []string, args ...any) (any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	if self.config.Client == nil {
+		return nil, errRedisNil
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = namespace + self.getKey(key)
+	}
+	return script.Run(ctx, self.config.Client, prefixed, args...).Result()
+}
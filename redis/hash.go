@@ -0,0 +1,175 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/volts-dev/cacher"
+)
+
+var errNoHash = errors.New("cache: redis client does not support hashes")
+
+// hasher is implemented by the clients which support hashes,
+// e.g. redis.Client,redis.ClusterClient and redis.Ring.
+type hasher interface {
+	HGet(ctx context.Context, key, field string) *redis.StringCmd
+	HGetAll(ctx context.Context, key string) *redis.StringStringMapCmd
+	HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd
+}
+
+// hashClient returns the client if it supports hashes.
+// the hashes bypass the local cache,a write drops the key from it.
+func (self *RedisCache) hashClient() (hasher, error) {
+	if self.config.Client == nil {
+		return nil, errRedisLocalCacheNil
+	}
+
+	client, ok := self.config.Client.(hasher)
+	if !ok {
+		return nil, errNoHash
+	}
+	return client, nil
+}
+
+// hashErr returns ErrWrongType for a key which holds a plain value.
+func hashErr(err error) error {
+	if err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE") {
+		return cacher.ErrWrongType
+	}
+	return err
+}
+
+// HGet returns the value of the field,every field is encoded by Marshal on its own.
+func (self *RedisCache) HGet(key, field string, ctx ...context.Context) (any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	client, err := self.hashClient()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := client.HGet(cacher.Context(ctx...), self.getKey(key), field).Bytes()
+	if err == redis.Nil {
+		return nil, cacher.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, hashErr(err)
+	}
+
+	var value any
+	if err := self.config.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (self *RedisCache) HGetAll(key string, ctx ...context.Context) (map[string]any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	client, err := self.hashClient()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := client.HGetAll(cacher.Context(ctx...), self.getKey(key)).Result()
+	if err != nil {
+		return nil, hashErr(err)
+	}
+	if len(raw) == 0 {
+		return nil, cacher.ErrCacheMiss
+	}
+
+	fields := make(map[string]any, len(raw))
+	for field, s := range raw {
+		var value any
+		if err := self.config.Unmarshal([]byte(s), &value); err != nil {
+			return nil, err
+		}
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+// HSet sets the fields and the TTL of the hash in one WATCH/MULTI transaction,
+// a key holding a plain value is left untouched.
+func (self *RedisCache) HSet(key string, fields map[string]any, ttl time.Duration, ctx ...context.Context) error {
+	if !self.config.Active || len(fields) == 0 {
+		return nil
+	}
+
+	if _, err := self.hashClient(); err != nil {
+		return err
+	}
+	self.DeleteFromLocalCache(key)
+
+	args := make([]any, 0, len(fields)*2)
+	for field, value := range fields {
+		b, err := self.config.Marshal(value)
+		if err != nil {
+			return err
+		}
+		args = append(args, field, b)
+	}
+
+	ttl = (&cacher.CacheBlock{Key: key, TTL: ttl}).Ttl() // 0 mean never expire
+	c, k := cacher.Context(ctx...), self.getKey(key)
+
+	// 先检查类型,否则HSET失败后EXEC仍会修改普通键的TTL
+	txf := func(tx *redis.Tx) error {
+		typ, err := tx.Type(c, k).Result()
+		if err != nil {
+			return err
+		}
+		if typ != "hash" && typ != "none" {
+			return cacher.ErrWrongType
+		}
+
+		_, err = tx.TxPipelined(c, func(pipe redis.Pipeliner) error {
+			pipe.HSet(c, k, args...)
+			if ttl > 0 {
+				pipe.PExpire(c, k, ttl)
+			} else {
+				pipe.Persist(c, k)
+			}
+			return nil
+		})
+		return err
+	}
+
+	var err error
+	for i := 0; i < defaultUpdateRetries; i++ {
+		if err = self.config.Client.Watch(c, txf, k); !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil && err != cacher.ErrWrongType {
+		self.config.Logger.Error("cache: redis hset failed", "key", key, "error", err)
+	}
+	return hashErr(err)
+}
+
+// HDel deletes the fields and keeps the TTL of the hash.
+func (self *RedisCache) HDel(key string, fields []string, ctx ...context.Context) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	client, err := self.hashClient()
+	if err != nil {
+		return err
+	}
+	self.DeleteFromLocalCache(key)
+
+	err = client.HDel(cacher.Context(ctx...), self.getKey(key), fields...).Err()
+	if err != nil {
+		self.config.Logger.Error("cache: redis hdel failed", "key", key, "error", err)
+	}
+	return hashErr(err)
+}
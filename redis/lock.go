@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/volts-dev/cacher"
+)
+
+const lockPrefix = "lock:"
+
+const (
+	// KEYS[1] lock ARGV[1] token ARGV[2] ttl in milliseconds
+	extendSrc = `if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`
+
+	// KEYS[1] lock ARGV[1] token
+	releaseSrc = `if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`
+)
+
+var (
+	extendScript  = redis.NewScript(extendSrc)
+	releaseScript = redis.NewScript(releaseSrc)
+)
+
+type (
+	// Locker hands out distributed locks kept by the redis client of a RedisCache.
+	Locker struct {
+		*cacher.Locker
+	}
+
+	lockStore struct {
+		cache *RedisCache
+	}
+)
+
+// NewLocker returns a locker sharing the client and the prefix of the cacher.
+func NewLocker(chr *RedisCache) *Locker {
+	locker := cacher.NewLocker(&lockStore{cache: chr})
+	locker.Logger = chr.config.Logger
+	return &Locker{Locker: locker}
+}
+
+// key puts the lock in front of the prefix of the cacher,
+// so Keys and Clear of the cacher do not touch the locks.
+func (self *lockStore) key(name string) string {
+	return lockPrefix + self.cache.getKey(name)
+}
+
+// Acquire runs SET NX PX with the random token.
+func (self *lockStore) Acquire(ctx context.Context, name, token string, ttl time.Duration) (bool, error) {
+	if self.cache.config.Client == nil {
+		return false, errRedisNil
+	}
+	return self.cache.config.Client.SetNX(ctx, self.key(name), token, ttl).Result()
+}
+
+// Extend resets the TTL only if the lock still holds the token.
+func (self *lockStore) Extend(ctx context.Context, name, token string, ttl time.Duration) (bool, error) {
+	if self.cache.config.Client == nil {
+		return false, errRedisNil
+	}
+
+	n, err := extendScript.Run(ctx, self.cache.config.Client, []string{self.key(name)}, token, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// Release deletes the lock only if it still holds the token.
+func (self *lockStore) Release(ctx context.Context, name, token string) (bool, error) {
+	if self.cache.config.Client == nil {
+		return false, errRedisNil
+	}
+
+	n, err := releaseScript.Run(ctx, self.cache.config.Client, []string{self.key(name)}, token).Int()
+	return n == 1, err
+}
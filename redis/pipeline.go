@@ -0,0 +1,212 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+var errBatchClosed = errors.New("cache: redis auto batch is closed")
+
+type (
+	// pipeliner is implemented by the clients which can send a pipeline,
+	// e.g. redis.Client,redis.ClusterClient and redis.Ring.
+	pipeliner interface {
+		Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+	}
+
+	// writer is implemented by both a client and a pipeline.
+	writer interface {
+		Set(ctx context.Context, key string, value interface{}, ttl time.Duration) *redis.StatusCmd
+		SetXX(ctx context.Context, key string, value interface{}, ttl time.Duration) *redis.BoolCmd
+		SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) *redis.BoolCmd
+		Del(ctx context.Context, keys ...string) *redis.IntCmd
+	}
+
+	// command queues itself to a writer and returns the result.
+	command func(w writer) redis.Cmder
+
+	// queued is a command waiting for the next pipeline.
+	queued struct {
+		cmd  command
+		done chan error
+	}
+
+	// autoBatch coalesces the concurrent writes into pipelines,a pipeline is sent
+	// once it holds size commands or window passed since its first command.
+	autoBatch struct {
+		sync.RWMutex // held by the writers while queueing,so none is queued after the close
+		closed       bool
+		client       pipeliner
+		window       time.Duration
+		size         int
+		queue        chan *queued
+		stop         chan struct{}
+		stopped      chan struct{}
+	}
+)
+
+func newAutoBatch(client pipeliner, window time.Duration, size int) *autoBatch {
+	if size < 1 {
+		size = defaultBatchSize
+	}
+
+	b := &autoBatch{
+		client:  client,
+		window:  window,
+		size:    size,
+		queue:   make(chan *queued, size),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// do queues the command and waits for its result,a canceled caller stops waiting
+// but the command may still be sent.
+func (self *autoBatch) do(ctx context.Context, cmd command) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	self.RLock()
+	if self.closed {
+		self.RUnlock()
+		return errBatchClosed
+	}
+
+	q := &queued{cmd: cmd, done: make(chan error, 1)}
+	select {
+	case self.queue <- q:
+	case <-ctx.Done():
+		self.RUnlock()
+		return ctx.Err()
+	}
+	self.RUnlock()
+
+	select {
+	case err := <-q.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (self *autoBatch) run() {
+	defer close(self.stopped)
+
+	for {
+		var batch []*queued
+		select {
+		case q := <-self.queue:
+			batch = append(batch, q)
+		case <-self.stop:
+			self.drain()
+			return
+		}
+
+		timer := time.NewTimer(self.window)
+	collect:
+		for len(batch) < self.size {
+			select {
+			case q := <-self.queue:
+				batch = append(batch, q)
+			case <-timer.C:
+				break collect
+			case <-self.stop:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		self.flush(batch)
+	}
+}
+
+// drain sends the commands queued before the stop.
+func (self *autoBatch) drain() {
+	for {
+		var batch []*queued
+	collect:
+		for len(batch) < self.size {
+			select {
+			case q := <-self.queue:
+				batch = append(batch, q)
+			default:
+				break collect
+			}
+		}
+
+		if len(batch) == 0 {
+			return
+		}
+		self.flush(batch)
+	}
+}
+
+// flush sends the batch in one pipeline and delivers the result of every command.
+func (self *autoBatch) flush(batch []*queued) {
+	cmds := make([]redis.Cmder, len(batch))
+	// 各命令的错误单独返回,管道本身的错误会写入每个命令
+	self.client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for i, q := range batch {
+			cmds[i] = q.cmd(pipe)
+		}
+		return nil
+	})
+
+	for i, q := range batch {
+		q.done <- cmds[i].Err()
+	}
+}
+
+// close sends the queued commands and stops the loop.
+func (self *autoBatch) close() {
+	self.Lock()
+	self.closed = true
+	self.Unlock()
+
+	close(self.stop)
+	<-self.stopped
+}
+
+// write runs the command directly or through the auto batch if it is enabled.
+func (self *RedisCache) write(ctx context.Context, cmd command) error {
+	if b := self.autoBatch(); b != nil {
+		if err := b.do(ctx, cmd); err != errBatchClosed {
+			return err
+		}
+	}
+	return cmd(self.config.Client).Err()
+}
+
+// autoBatch returns the auto batch,which is started by the first write.
+// it is nil if the batching is disabled,the client can not pipeline or the cacher is closed.
+func (self *RedisCache) autoBatch() *autoBatch {
+	if self.config.BatchWindow <= 0 {
+		return nil
+	}
+
+	self.RLock()
+	b, closed := self.batch, self.closed
+	self.RUnlock()
+	if b != nil || closed {
+		return b
+	}
+
+	client, ok := self.config.Client.(pipeliner)
+	if !ok {
+		return nil
+	}
+
+	self.Lock()
+	defer self.Unlock()
+	if self.batch == nil && !self.closed {
+		self.batch = newAutoBatch(client, self.config.BatchWindow, self.config.BatchSize)
+	}
+	return self.batch
+}
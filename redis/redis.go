@@ -3,19 +3,18 @@ package redis
 import (
 	"context"
 	"errors"
-	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	redis "github.com/go-redis/redis/v8"
-	"github.com/klauspost/compress/s2"
-	"github.com/vmihailenco/msgpack"
 	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/internal/codec"
 )
 
 var (
 	errRedisLocalCacheNil = errors.New("cache: both Redis and LocalCache are nil")
+	errNoPrefix           = errors.New("cache: redis clear needs a prefix,set it by WithPrefix")
 )
 
 var Redis = cacher.Register("Redis", func() cacher.ICacher {
@@ -30,11 +29,15 @@ type (
 		Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
 		Get(ctx context.Context, key string) *redis.StringCmd
 		Del(ctx context.Context, keys ...string) *redis.IntCmd
+		Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error
+		redis.Scripter
 	}
 
 	RedisCache struct {
 		sync.RWMutex
 		config *Config
+		batch  *autoBatch
+		closed bool
 	}
 )
 
@@ -44,6 +47,11 @@ func New(opts ...cacher.Option) *RedisCache {
 	}
 
 	cfg.Init(opts...)
+
+	if cfg.Logger == nil {
+		cfg.Logger = cacher.DefaultLogger()
+	}
+
 	cacher := &RedisCache{
 		config: cfg,
 	}
@@ -75,34 +83,17 @@ func (self *RedisCache) Active(on ...bool) bool {
 }
 
 func (self *RedisCache) Keys(ctx ...context.Context) []string {
-	var c context.Context
-	if len(ctx) > 0 {
-		c = ctx[0]
-	} else {
-		c = context.Background()
-	}
-	var cursor uint64
-	var keys, lst []string
-	for {
-		var err error
-		lst, cursor, err = self.config.cli.Scan(c, cursor, "prefix:*", 0).Result()
-		if err != nil {
-			panic(err)
-		}
-
-		keys = append(keys, lst...)
-
-		if cursor == 0 { // no more keys
-			break
-		}
+	keys, err := self.KeysCtx(cacher.Context(ctx...))
+	if err != nil {
+		self.config.Logger.Error("cache: redis scan failed", "error", err)
 	}
-
 	return keys
 }
 
 // Count of cache size
 func (self *RedisCache) Len() int {
-	return 0
+	n, _ := self.LenCtx(context.Background())
+	return n
 }
 
 // Exists reports whether value for the given key exists.
@@ -112,11 +103,11 @@ func (self *RedisCache) Exists(key string, ctx ...context.Context) bool {
 }
 
 func (self *RedisCache) getKey(key string) string {
-	return self.config.prefix + key
+	return self.config.Prefix + key
 }
 
 func (self *RedisCache) getValue(ctx context.Context, sid string) (string, error) {
-	cmd := self.config.cli.Get(ctx, self.getKey(sid))
+	cmd := self.config.Client.Get(ctx, self.getKey(sid))
 	if err := cmd.Err(); err != nil {
 		if err == redis.Nil {
 			return "", nil
@@ -143,19 +134,14 @@ func (self *RedisCache) Get(key string, ctx ...context.Context) (any, error) {
 }
 
 func (self *RedisCache) get(key string, skipLocalCache bool, ctx ...context.Context) (value any, err error) {
-	var c context.Context
-	if ctx != nil {
-		c = ctx[0]
-	} else {
-		c = context.Background()
-	}
-
-	b, err := self.getBytes(c, key, true)
+	b, err := self.getBytes(cacher.Context(ctx...), key, skipLocalCache)
 	if err != nil {
 		return nil, err
 	}
 
-	self.config.Unmarshal(b, &value)
+	if err := self.config.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
 	return
 }
 
@@ -164,7 +150,7 @@ func (self *RedisCache) Set(block *cacher.CacheBlock) error {
 		return nil
 	}
 
-	b, err := self.marshal(block.Value)
+	b, err := self.config.Marshal(block.Value)
 	if err != nil {
 		return err
 	}
@@ -172,48 +158,52 @@ func (self *RedisCache) Set(block *cacher.CacheBlock) error {
 	bb := block.Clone()
 	bb.Value = b
 	if self.config.LocalCache != nil && !block.SkipLocalCache {
-		self.config.LocalCache.Set(bb)
+		if err := self.config.LocalCache.Set(bb); err != nil {
+			self.config.Logger.Warn("cache: local cache set failed", "key", block.Key, "error", err)
+		}
 	}
 
-	if self.config.cli == nil {
+	if self.config.Client == nil {
 		if self.config.LocalCache == nil {
 			return errRedisLocalCacheNil
 		}
 		return nil
 	}
 
-	ttl := block.Ttl()
-	if ttl == 0 {
-		return nil
-	}
+	ttl := block.Ttl() // a negative TTL is stored without expiration,not skipped
+	err = self.write(block.Context(), func(w writer) redis.Cmder {
+		switch {
+		case block.SetOnlyExist:
+			return w.SetXX(block.Context(), self.getKey(block.Key), b, ttl)
+		case block.SetOnlyNew:
+			return w.SetNX(block.Context(), self.getKey(block.Key), b, ttl)
+		}
+		return w.Set(block.Context(), self.getKey(block.Key), b, ttl)
+	})
 
-	if block.SetOnlyExist {
-		return self.config.cli.SetXX(block.Context(), block.Key, b, ttl).Err()
-	}
-	if block.SetOnlyNew {
-		return self.config.cli.SetNX(block.Context(), block.Key, b, ttl).Err()
+	if err != nil {
+		self.config.Logger.Error("cache: redis set failed", "key", block.Key, "error", err)
 	}
-	return self.config.cli.Set(block.Context(), block.Key, b, ttl).Err()
+	return err
 }
 
 func (self *RedisCache) getBytes(ctx context.Context, key string, skipLocalCache bool) ([]byte, error) {
 	if !skipLocalCache && self.config.LocalCache != nil {
-		//var buf []byte
-		buf, err := self.config.LocalCache.Get(key)
-		if err != nil {
-			return nil, err
+		if buf, err := self.config.LocalCache.Get(key); err == nil {
+			if b, ok := buf.([]byte); ok {
+				return b, nil
+			}
 		}
-		return buf.([]byte), nil
 	}
 
-	if self.config.cli == nil {
+	if self.config.Client == nil {
 		if self.config.LocalCache == nil {
 			return nil, errRedisLocalCacheNil
 		}
 		return nil, cacher.ErrCacheMiss
 	}
 
-	b, err := self.config.cli.Get(ctx, key).Bytes()
+	b, err := self.config.Client.Get(ctx, self.getKey(key)).Bytes()
 	if err != nil {
 		if self.config.StatsEnabled {
 			atomic.AddUint64(&self.config.misses, 1)
@@ -221,6 +211,7 @@ func (self *RedisCache) getBytes(ctx context.Context, key string, skipLocalCache
 		if err == redis.Nil {
 			return nil, cacher.ErrCacheMiss
 		}
+		self.config.Logger.Error("cache: redis get failed", "key", key, "error", err)
 		return nil, err
 	}
 
@@ -237,12 +228,56 @@ func (self *RedisCache) getBytes(ctx context.Context, key string, skipLocalCache
 	return b, nil
 }
 
+// Clear deletes the keys under the prefix from both redis and the local cache.
+// without a prefix only the local cache is cleared and errNoPrefix is returned,
+// since the whole database may be shared with others.
 func (self *RedisCache) Clear() error {
-	return self.config.LocalCache.Clear()
+	if self.config.LocalCache != nil {
+		self.config.LocalCache.Clear()
+	}
+
+	if self.config.Client == nil {
+		return nil
+	}
+
+	if self.config.Prefix == "" {
+		return errNoPrefix
+	}
+
+	ctx := context.Background()
+	keys, err := self.scan(ctx)
+	if err != nil {
+		self.config.Logger.Error("cache: redis scan failed", "error", err)
+		return err
+	}
+
+	for len(keys) > 0 {
+		n := min(len(keys), clearBatch)
+		if err := self.config.Client.Del(ctx, keys[:n]...).Err(); err != nil {
+			self.config.Logger.Error("cache: redis clear failed", "error", err)
+			return err
+		}
+		keys = keys[n:]
+	}
+
+	return nil
 }
 
+// Close sends the queued writes and closes the local cache,the data in redis is kept.
+// the client is owned by the caller and is not closed.
 func (self *RedisCache) Close() error {
-	return self.Clear()
+	self.Lock()
+	b := self.batch
+	self.batch, self.closed = nil, true
+	self.Unlock()
+	if b != nil {
+		b.close()
+	}
+
+	if self.config.LocalCache == nil {
+		return nil
+	}
+	return self.config.LocalCache.Close()
 }
 
 func (self *RedisCache) Delete(key string, ctx ...context.Context) error {
@@ -250,20 +285,20 @@ func (self *RedisCache) Delete(key string, ctx ...context.Context) error {
 		self.config.LocalCache.Delete(key)
 	}
 
-	if self.config.cli == nil {
+	if self.config.Client == nil {
 		if self.config.LocalCache == nil {
 			return errRedisLocalCacheNil
 		}
 		return nil
 	}
 
-	var c context.Context
-	if ctx != nil {
-		c = ctx[0]
-	} else {
-		c = context.Background()
+	c := cacher.Context(ctx...)
+	err := self.write(c, func(w writer) redis.Cmder {
+		return w.Del(c, self.getKey(key))
+	})
+	if err != nil {
+		self.config.Logger.Error("cache: redis delete failed", "key", key, "error", err)
 	}
-	_, err := self.config.cli.Del(c, key).Result()
 	return err
 }
 
@@ -273,72 +308,13 @@ func (self *RedisCache) DeleteFromLocalCache(key string) {
 	}
 }
 
+// marshal encodes every value with msgpack so that Get can restore its type,
+// strings and bytes included. values written raw by other clients can not be read,
+// set Marshal and Unmarshal to share the keys with them.
 func (self *RedisCache) marshal(value interface{}) ([]byte, error) {
-	switch value := value.(type) {
-	case nil:
-		return nil, nil
-	case []byte:
-		return value, nil
-	case string:
-		return []byte(value), nil
-	}
-
-	b, err := msgpack.Marshal(value)
-	if err != nil {
-		return nil, err
-	}
-
-	return compress(b), nil
+	return codec.Marshal(value)
 }
 
 func (self *RedisCache) unmarshal(b []byte, value interface{}) error {
-	if len(b) == 0 {
-		return nil
-	}
-
-	switch value := value.(type) {
-	case nil:
-		return nil
-	case *[]byte:
-		clone := make([]byte, len(b))
-		copy(clone, b)
-		*value = clone
-		return nil
-	case *string:
-		*value = string(b)
-		return nil
-	}
-
-	switch c := b[len(b)-1]; c {
-	case noCompression:
-		b = b[:len(b)-1]
-	case s2Compression:
-		b = b[:len(b)-1]
-
-		var err error
-		b, err = s2.Decode(nil, b)
-		if err != nil {
-			return err
-		}
-	default:
-		return fmt.Errorf("unknown compression method: %x", c)
-	}
-
-	return msgpack.Unmarshal(b, value)
-}
-
-func compress(data []byte) []byte {
-	if len(data) < compressionThreshold {
-		n := len(data) + 1
-		b := make([]byte, n, n+timeLen)
-		copy(b, data)
-		b[len(b)-1] = noCompression
-		return b
-	}
-
-	n := s2.MaxEncodedLen(len(data)) + 1
-	b := make([]byte, n, n+timeLen)
-	b = s2.Encode(b, data)
-	b = append(b, s2Compression)
-	return b
+	return codec.Unmarshal(b, value)
 }
@@ -1,16 +1,25 @@
 package redis
 
 import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/cachertest"
+	"github.com/volts-dev/cacher/memory"
+	"github.com/volts-dev/cacher/redis/redistest"
 )
 
 func TestBase(t *testing.T) {
 	Key := "Test"
+	srv := redistest.Run(t)
 	rdb := redis.NewClient(&redis.Options{
-		Addr: "127.0.0.1:6379",
+		Addr: srv.Addr(),
 	})
 	r := New(
 		WithRedis(rdb),
@@ -30,3 +39,348 @@ func TestBase(t *testing.T) {
 	}
 	t.Log(s)
 }
+
+func TestConformance(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	cachertest.Run(t, func(t *testing.T) cacher.ICacher {
+		srv := redistest.Run(t)
+		srv.SetClock(clock)
+		rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+		t.Cleanup(func() { rdb.Close() })
+		return New(WithRedis(rdb), WithPrefix("test:"))
+	}, cachertest.WithSleep(clock.Advance))
+}
+
+func TestLocalCache(t *testing.T) {
+	srv := redistest.Run(t)
+	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer rdb.Close()
+
+	local := memory.New()
+	r := New(WithRedis(rdb), WithLocalCacher(local))
+	r.Set(&cacher.CacheBlock{Key: "A", Value: "a"})
+
+	if _, ok := srv.Get("A"); !ok || !local.Exists("A") {
+		t.Fatal("value should be written to both redis and the local cache")
+	}
+
+	// 本地缓存失效后从Redis读取并回填
+	local.Delete("A")
+	if v, err := r.Get("A"); err != nil || v != "a" {
+		t.Fatalf("get %v %v", v, err)
+	}
+	if !local.Exists("A") {
+		t.Fatal("local cache is not refilled")
+	}
+
+	srv.FlushAll()
+	if v, err := r.Get("A"); err != nil || v != "a" {
+		t.Fatalf("local cache should serve the value, got %v %v", v, err)
+	}
+	if _, err := r.GetSkippingLocalCache("A"); err != cacher.ErrCacheMiss {
+		t.Fatalf("expected a miss, got %v", err)
+	}
+}
+
+func TestClear(t *testing.T) {
+	srv := redistest.Run(t)
+	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer rdb.Close()
+
+	other := New(WithRedis(rdb))
+	other.Set(&cacher.CacheBlock{Key: "other", Value: "v"})
+
+	// 没有前缀时不能清空整个库
+	if err := other.Clear(); err != errNoPrefix {
+		t.Fatalf("clear without a prefix %v", err)
+	}
+	if _, ok := srv.Get("other"); !ok {
+		t.Fatal("clear without a prefix deletes the database")
+	}
+
+	r := New(WithRedis(rdb), WithPrefix("app:*"))
+	r.Set(&cacher.CacheBlock{Key: "a", Value: "v"})
+	if _, ok := srv.Get("app:*a"); !ok {
+		t.Fatal("key is not stored under the prefix")
+	}
+	if keys := r.Keys(); len(keys) != 1 || keys[0] != "a" {
+		t.Fatalf("keys %q", keys)
+	}
+
+	if err := r.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 0 {
+		t.Fatal("keys under the prefix are kept")
+	}
+	if _, ok := srv.Get("other"); !ok {
+		t.Fatal("clear deletes the keys out of the prefix")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	srv := redistest.Run(t)
+	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer rdb.Close()
+
+	// WithRedis只能设置导出的Client字段
+	chr, err := cacher.New("redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chr.Init(WithRedis(rdb))
+	if err := chr.Set(&cacher.CacheBlock{Key: "A", Value: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.Get("A"); !ok {
+		t.Fatal("client set by Init is not used")
+	}
+}
+
+func TestWireFormat(t *testing.T) {
+	srv := redistest.Run(t)
+	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer rdb.Close()
+
+	r := New(WithRedis(rdb))
+	for key, value := range map[string]any{
+		"string": "a",
+		"bytes":  []byte("b"),
+		"int":    int64(1),
+		"map":    map[string]any{"k": "v"},
+	} {
+		if err := r.Set(&cacher.CacheBlock{Key: key, Value: value}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.Get(key)
+		if err != nil || fmt.Sprint(got) != fmt.Sprint(value) || fmt.Sprintf("%T", got) != fmt.Sprintf("%T", value) {
+			t.Fatalf("%s is read as %v(%T) %v", key, got, got, err)
+		}
+	}
+
+	// 字符串也经过msgpack编码,以便还原类型
+	if raw, _ := srv.Get("string"); string(raw) == "a" {
+		t.Fatal("string is stored raw")
+	}
+
+	// 其他客户端写入的原始值不能被误读
+	rdb.Set(context.Background(), "raw", "hello", 0)
+	if v, err := r.Get("raw"); err == nil {
+		t.Fatalf("raw value is read as %v", v)
+	}
+}
+
+func TestNegativeTTL(t *testing.T) {
+	srv := redistest.Run(t)
+	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer rdb.Close()
+
+	// 负的TTL表示永不过期,而不是跳过写入
+	r := New(WithRedis(rdb))
+	if err := r.Set(&cacher.CacheBlock{Key: "forever", Value: "v", TTL: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := rdb.PTTL(context.Background(), "forever").Result(); err != nil || ttl != -1 {
+		t.Fatalf("pttl of forever %v %v", ttl, err)
+	}
+
+	r.Set(&cacher.CacheBlock{Key: "default", Value: "v"})
+	if ttl, _ := rdb.PTTL(context.Background(), "default").Result(); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("pttl of default %v", ttl)
+	}
+}
+
+func TestHSetWrongType(t *testing.T) {
+	srv := redistest.Run(t)
+	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer rdb.Close()
+
+	r := New(WithRedis(rdb))
+	r.Set(&cacher.CacheBlock{Key: "plain", Value: "v", TTL: time.Minute})
+	r.Set(&cacher.CacheBlock{Key: "forever", Value: "v", TTL: -1})
+
+	// 失败的HSET不能改变普通键的TTL
+	if err := r.HSet("plain", map[string]any{"f": "v"}, time.Hour); err != cacher.ErrWrongType {
+		t.Fatalf("hset on a plain key %v", err)
+	}
+	if err := r.HSet("forever", map[string]any{"f": "v"}, time.Hour); err != cacher.ErrWrongType {
+		t.Fatalf("hset on a plain key %v", err)
+	}
+
+	ctx := context.Background()
+	if ttl, _ := rdb.PTTL(ctx, "plain").Result(); ttl <= 59*time.Second || ttl > time.Minute {
+		t.Fatalf("ttl of plain is changed to %v", ttl)
+	}
+	if ttl, _ := rdb.PTTL(ctx, "forever").Result(); ttl != -1 {
+		t.Fatalf("ttl of forever is changed to %v", ttl)
+	}
+}
+
+func TestClose(t *testing.T) {
+	srv := redistest.Run(t)
+	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer rdb.Close()
+
+	local := memory.New()
+	r := New(WithRedis(rdb), WithLocalCacher(local))
+	r.Set(&cacher.CacheBlock{Key: "A", Value: "a"})
+
+	// 关闭只释放本地资源,redis中的数据保留
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.Get("A"); !ok {
+		t.Fatal("close deletes the data in redis")
+	}
+	if local.Len() != 0 {
+		t.Fatal("local cache is not closed")
+	}
+}
+
+func TestLocker(t *testing.T) {
+	ctx := context.Background()
+	srv := redistest.Run(t)
+	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer rdb.Close()
+
+	l := NewLocker(New(WithRedis(rdb)))
+	lease, err := l.TryLock(ctx, "job", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, ok := srv.Get("lock:job"); !ok || string(token) != lease.Token {
+		t.Fatalf("unexpected token %q", token)
+	}
+
+	if _, err := l.TryLock(ctx, "job", time.Minute); err != cacher.ErrLockHeld {
+		t.Fatalf("expected ErrLockHeld, got %v", err)
+	}
+
+	if err := lease.Extend(ctx, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := rdb.PTTL(ctx, "lock:job").Val(); ttl <= time.Minute || ttl > time.Hour {
+		t.Fatalf("ttl after extend %v", ttl)
+	}
+	if err := lease.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.Get("lock:job"); ok {
+		t.Fatal("lock is not deleted by unlock")
+	}
+
+	// 锁被他人获得后不能释放
+	lease, _ = l.TryLock(ctx, "job", time.Minute)
+	srv.FlushAll()
+	other, err := l.TryLock(ctx, "job", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lease.Extend(ctx, time.Hour); err != cacher.ErrLockLost {
+		t.Fatalf("extend a lost lock %v", err)
+	}
+	if ttl := rdb.PTTL(ctx, "lock:job").Val(); ttl > time.Minute {
+		t.Fatalf("the lock of the other holder is extended to %v", ttl)
+	}
+	if err := lease.Unlock(ctx); err != cacher.ErrLockLost {
+		t.Fatalf("expected ErrLockLost, got %v", err)
+	}
+	if token, _ := srv.Get("lock:job"); string(token) != other.Token {
+		t.Fatal("the lock of the other holder is released")
+	}
+	other.Unlock(ctx)
+}
+
+func TestLockNamespace(t *testing.T) {
+	ctx := context.Background()
+	srv := redistest.Run(t)
+	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer rdb.Close()
+
+	r := New(WithRedis(rdb), WithPrefix("app:"))
+	lease, err := NewLocker(r).TryLock(ctx, "job", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Set(&cacher.CacheBlock{Key: "a", Value: "v"})
+
+	// 锁不在缓存的前缀下,Keys和Clear不会碰到它
+	if keys := r.Keys(); len(keys) != 1 || keys[0] != "a" {
+		t.Fatalf("keys %q", keys)
+	}
+	if err := r.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if token, ok := srv.Get("lock:app:job"); !ok || string(token) != lease.Token {
+		t.Fatal("clear deletes the lock")
+	}
+}
+
+// countingClient counts the pipelines sent by the client.
+type countingClient struct {
+	*redis.Client
+	pipelines int64
+}
+
+func (self *countingClient) Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	atomic.AddInt64(&self.pipelines, 1)
+	return self.Client.Pipelined(ctx, fn)
+}
+
+func TestAutoBatchConformance(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	cachertest.Run(t, func(t *testing.T) cacher.ICacher {
+		srv := redistest.Run(t)
+		srv.SetClock(clock)
+		rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+		t.Cleanup(func() { rdb.Close() })
+		return New(WithRedis(rdb), WithPrefix("test:"), WithAutoBatch(time.Millisecond, 16))
+	}, cachertest.WithSleep(clock.Advance))
+}
+
+func TestAutoBatch(t *testing.T) {
+	srv := redistest.Run(t)
+	rdb := &countingClient{Client: redis.NewClient(&redis.Options{Addr: srv.Addr()})}
+	defer rdb.Close()
+
+	r := New(WithRedis(rdb), WithAutoBatch(20*time.Millisecond, 16))
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := r.Set(&cacher.CacheBlock{Key: fmt.Sprintf("key%d", i), Value: i}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := len(srv.Keys()); n != 64 {
+		t.Fatalf("%d keys after the batch", n)
+	}
+	// 64条命令最多16条一批
+	if n := atomic.LoadInt64(&rdb.pipelines); n < 4 || n > 32 {
+		t.Fatalf("%d pipelines for 64 writes", n)
+	}
+
+	// 每个调用得到自己的结果
+	if err := r.Set(&cacher.CacheBlock{Key: "key0", Value: "new", SetOnlyNew: true}); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := r.Get("key0"); v != int64(0) {
+		t.Fatalf("key0 is overwritten by set only new %v", v)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Delete("key1", ctx); err != context.Canceled {
+		t.Fatalf("delete with a canceled context %v", err)
+	}
+
+	// 关闭后直接写入
+	r.Close()
+	if err := r.Delete("key1"); err != nil || r.Exists("key1") {
+		t.Fatalf("delete after close %v", err)
+	}
+}
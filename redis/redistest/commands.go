@@ -0,0 +1,243 @@
+package redistest
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commands maps the lower case command names to their handlers.
+var commands = map[string]handler{
+	"ping":     cmdPing,
+	"echo":     cmdEcho,
+	"get":      cmdGet,
+	"set":      cmdSet,
+	"del":      cmdDel,
+	"exists":   cmdExists,
+	"type":     cmdType,
+	"scan":     cmdScan,
+	"pttl":     cmdPTTL,
+	"pexpire":  cmdPExpire,
+	"flushdb":  cmdFlush,
+	"flushall": cmdFlush,
+}
+
+func cmdPing(s *Server, c *client, args []string) any {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return status("PONG")
+}
+
+func cmdEcho(s *Server, c *client, args []string) any {
+	if len(args) != 1 {
+		return errWrongArgs
+	}
+	return args[0]
+}
+
+func cmdGet(s *Server, c *client, args []string) any {
+	if len(args) != 1 {
+		return errWrongArgs
+	}
+
+	if e := s.lookup(args[0]); e != nil {
+		if e.hash != nil {
+			return errWrongType
+		}
+		return e.value
+	}
+	return nil
+}
+
+// SET key value [EX seconds|PX milliseconds|KEEPTTL] [NX|XX]
+func cmdSet(s *Server, c *client, args []string) any {
+	if len(args) < 2 {
+		return errWrongArgs
+	}
+
+	key, value := args[0], args[1]
+	var (
+		nx, xx, keepTTL bool
+		expireAt        time.Time
+	)
+
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "keepttl":
+			keepTTL = true
+		case "ex", "px":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return errNotInt
+			}
+			unit := time.Second
+			if strings.ToLower(args[i]) == "px" {
+				unit = time.Millisecond
+			}
+			expireAt = s.now().Add(time.Duration(n) * unit)
+			i++
+		default:
+			return errSyntax
+		}
+	}
+
+	if nx && xx {
+		return errSyntax
+	}
+
+	old := s.lookup(key)
+	if (nx && old != nil) || (xx && old == nil) {
+		return nil
+	}
+
+	if keepTTL && old != nil {
+		expireAt = old.expireAt
+	}
+
+	s.data[key] = &entry{value: []byte(value), expireAt: expireAt}
+	s.touch(key)
+	return ok
+}
+
+func cmdDel(s *Server, c *client, args []string) any {
+	if len(args) == 0 {
+		return errWrongArgs
+	}
+
+	n := 0
+	for _, key := range args {
+		if s.lookup(key) != nil {
+			delete(s.data, key)
+			s.touch(key)
+			n++
+		}
+	}
+	return n
+}
+
+func cmdExists(s *Server, c *client, args []string) any {
+	if len(args) == 0 {
+		return errWrongArgs
+	}
+
+	n := 0
+	for _, key := range args {
+		if s.lookup(key) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdType(s *Server, c *client, args []string) any {
+	if len(args) != 1 {
+		return errWrongArgs
+	}
+
+	switch e := s.lookup(args[0]); {
+	case e == nil:
+		return status("none")
+	case e.hash != nil:
+		return status("hash")
+	}
+	return status("string")
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count]
+// the cursor is the offset in the sorted keys.
+func cmdScan(s *Server, c *client, args []string) any {
+	if len(args) < 1 {
+		return errWrongArgs
+	}
+
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		return errCursor
+	}
+
+	pattern, count := "*", 10
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+		switch strings.ToLower(args[i]) {
+		case "match":
+			pattern = args[i+1]
+		case "count":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+				return errNotInt
+			}
+		default:
+			return errSyntax
+		}
+	}
+
+	keys := s.sortedKeys()
+	var found []string
+	for ; cursor < len(keys) && count > 0; cursor, count = cursor+1, count-1 {
+		if Match(pattern, keys[cursor]) {
+			found = append(found, keys[cursor])
+		}
+	}
+
+	if cursor >= len(keys) {
+		cursor = 0
+	}
+
+	if found == nil {
+		found = []string{}
+	}
+	return []any{strconv.Itoa(cursor), found}
+}
+
+func cmdPTTL(s *Server, c *client, args []string) any {
+	if len(args) != 1 {
+		return errWrongArgs
+	}
+
+	e := s.lookup(args[0])
+	switch {
+	case e == nil:
+		return -2
+	case e.expireAt.IsZero():
+		return -1
+	}
+	return int64(e.expireAt.Sub(s.now()) / time.Millisecond)
+}
+
+func cmdPExpire(s *Server, c *client, args []string) any {
+	if len(args) != 2 {
+		return errWrongArgs
+	}
+
+	ms, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return errNotInt
+	}
+
+	e := s.lookup(args[0])
+	if e == nil {
+		return 0
+	}
+
+	if ms <= 0 {
+		delete(s.data, args[0])
+	} else {
+		e.expireAt = s.now().Add(time.Duration(ms) * time.Millisecond)
+	}
+	s.touch(args[0])
+	return 1
+}
+
+func cmdFlush(s *Server, c *client, args []string) any {
+	s.flush()
+	return ok
+}
@@ -0,0 +1,151 @@
+package redistest
+
+import (
+	"sort"
+	"time"
+)
+
+func init() {
+	commands["hset"] = cmdHSet
+	commands["hget"] = cmdHGet
+	commands["hdel"] = cmdHDel
+	commands["hgetall"] = cmdHGetAll
+	commands["persist"] = cmdPersist
+}
+
+// Hash returns the raw fields of the hash.
+func (self *Server) Hash(key string) (map[string][]byte, bool) {
+	self.Lock()
+	defer self.Unlock()
+
+	e := self.lookup(key)
+	if e == nil || e.hash == nil {
+		return nil, false
+	}
+
+	fields := make(map[string][]byte, len(e.hash))
+	for field, value := range e.hash {
+		fields[field] = value
+	}
+	return fields, true
+}
+
+// lookupHash returns the hash of the key,nil if it is missing.
+func (self *Server) lookupHash(key string) (map[string][]byte, error) {
+	e := self.lookup(key)
+	switch {
+	case e == nil:
+		return nil, nil
+	case e.hash == nil:
+		return nil, errWrongType
+	}
+	return e.hash, nil
+}
+
+// HSET key field value [field value ...]
+func cmdHSet(s *Server, c *client, args []string) any {
+	if len(args) < 3 || len(args)%2 != 1 {
+		return errWrongArgs
+	}
+
+	key := args[0]
+	hash, err := s.lookupHash(key)
+	if err != nil {
+		return err
+	}
+	if hash == nil {
+		hash = make(map[string][]byte)
+		s.data[key] = &entry{hash: hash}
+	}
+
+	n := 0
+	for i := 1; i < len(args); i += 2 {
+		if _, has := hash[args[i]]; !has {
+			n++
+		}
+		hash[args[i]] = []byte(args[i+1])
+	}
+	s.touch(key)
+	return n
+}
+
+func cmdHGet(s *Server, c *client, args []string) any {
+	if len(args) != 2 {
+		return errWrongArgs
+	}
+
+	hash, err := s.lookupHash(args[0])
+	if err != nil {
+		return err
+	}
+	if value, has := hash[args[1]]; has {
+		return value
+	}
+	return nil
+}
+
+// HDEL deletes the fields,the key is deleted with its last field.
+func cmdHDel(s *Server, c *client, args []string) any {
+	if len(args) < 2 {
+		return errWrongArgs
+	}
+
+	key := args[0]
+	hash, err := s.lookupHash(key)
+	if err != nil {
+		return err
+	}
+
+	n := 0
+	for _, field := range args[1:] {
+		if _, has := hash[field]; has {
+			delete(hash, field)
+			n++
+		}
+	}
+	if hash != nil && len(hash) == 0 {
+		delete(s.data, key)
+	}
+	if n > 0 {
+		s.touch(key)
+	}
+	return n
+}
+
+// HGETALL replies the fields and values in order of the fields.
+func cmdHGetAll(s *Server, c *client, args []string) any {
+	if len(args) != 1 {
+		return errWrongArgs
+	}
+
+	hash, err := s.lookupHash(args[0])
+	if err != nil {
+		return err
+	}
+
+	fields := make([]string, 0, len(hash))
+	for field := range hash {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	reply := make([]string, 0, len(hash)*2)
+	for _, field := range fields {
+		reply = append(reply, field, string(hash[field]))
+	}
+	return reply
+}
+
+func cmdPersist(s *Server, c *client, args []string) any {
+	if len(args) != 1 {
+		return errWrongArgs
+	}
+
+	e := s.lookup(args[0])
+	if e == nil || e.expireAt.IsZero() {
+		return 0
+	}
+	e.expireAt = time.Time{}
+	s.touch(args[0])
+	return 1
+}
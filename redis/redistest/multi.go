@@ -0,0 +1,94 @@
+package redistest
+
+import (
+	"errors"
+	"strings"
+)
+
+// immediate commands are not queued by MULTI.
+var immediate = map[string]bool{
+	"multi":   true,
+	"exec":    true,
+	"discard": true,
+	"watch":   true,
+	"unwatch": true,
+}
+
+func init() {
+	// registered here since EXEC refers to the command table
+	commands["multi"] = cmdMulti
+	commands["exec"] = cmdExec
+	commands["discard"] = cmdDiscard
+	commands["watch"] = cmdWatch
+	commands["unwatch"] = cmdUnwatch
+}
+
+func (self *client) reset() {
+	self.multi = false
+	self.dirty = false
+	self.queue = nil
+	self.watched = nil
+}
+
+func cmdMulti(s *Server, c *client, args []string) any {
+	if c.multi {
+		return errors.New("ERR MULTI calls can not be nested")
+	}
+	c.multi = true
+	return ok
+}
+
+func cmdExec(s *Server, c *client, args []string) any {
+	if !c.multi {
+		return errors.New("ERR EXEC without MULTI")
+	}
+	defer c.reset()
+
+	if c.dirty {
+		return errors.New("EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	for key, rev := range c.watched {
+		s.lookup(key) // expire it first
+		if s.revs[key] != rev {
+			return []any(nil)
+		}
+	}
+
+	replies := make([]any, len(c.queue))
+	for i, args := range c.queue {
+		replies[i] = commands[strings.ToLower(args[0])](s, c, args[1:])
+	}
+	return replies
+}
+
+func cmdDiscard(s *Server, c *client, args []string) any {
+	if !c.multi {
+		return errors.New("ERR DISCARD without MULTI")
+	}
+	c.reset()
+	return ok
+}
+
+func cmdWatch(s *Server, c *client, args []string) any {
+	if c.multi {
+		return errors.New("ERR WATCH inside MULTI is not allowed")
+	}
+	if len(args) == 0 {
+		return errWrongArgs
+	}
+
+	if c.watched == nil {
+		c.watched = make(map[string]uint64)
+	}
+	for _, key := range args {
+		s.lookup(key)
+		c.watched[key] = s.revs[key]
+	}
+	return ok
+}
+
+func cmdUnwatch(s *Server, c *client, args []string) any {
+	c.watched = nil
+	return ok
+}
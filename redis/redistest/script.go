@@ -0,0 +1,264 @@
+package redistest
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+var errNoScript = errors.New("NOSCRIPT No matching script. Please use EVAL.")
+
+func init() {
+	commands["eval"] = cmdEval
+	commands["evalsha"] = cmdEvalSha
+	commands["script"] = cmdScript
+}
+
+func sha(src string) string {
+	h := sha1.Sum([]byte(src))
+	return hex.EncodeToString(h[:])
+}
+
+// load caches the script for EVALSHA.
+func (self *Server) load(src string) string {
+	h := sha(src)
+	if self.scripts == nil {
+		self.scripts = make(map[string]string)
+	}
+	self.scripts[h] = src
+	return h
+}
+
+// runScript runs the Lua source with the libraries redis offers,
+// the replies are converted like redis does between RESP and Lua.
+func (self *Server) runScript(c *client, src string, args []string) any {
+	if len(args) < 1 {
+		return errWrongArgs
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 || n > len(args)-1 {
+		return errors.New("ERR Number of keys can't be greater than number of args")
+	}
+
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
+
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	L.SetGlobal("KEYS", stringTable(L, args[1:n+1]))
+	L.SetGlobal("ARGV", stringTable(L, args[n+1:]))
+
+	api := L.NewTable()
+	L.SetField(api, "call", L.NewFunction(func(L *lua.LState) int {
+		return self.luaCall(L, c, true)
+	}))
+	L.SetField(api, "pcall", L.NewFunction(func(L *lua.LState) int {
+		return self.luaCall(L, c, false)
+	}))
+	L.SetField(api, "status_reply", L.NewFunction(func(L *lua.LState) int {
+		t := L.NewTable()
+		L.SetField(t, "ok", lua.LString(L.CheckString(1)))
+		L.Push(t)
+		return 1
+	}))
+	L.SetField(api, "error_reply", L.NewFunction(func(L *lua.LState) int {
+		t := L.NewTable()
+		L.SetField(t, "err", lua.LString(L.CheckString(1)))
+		L.Push(t)
+		return 1
+	}))
+	L.SetGlobal("redis", api)
+
+	fn, err := L.LoadString(src)
+	if err != nil {
+		return fmt.Errorf("ERR Error compiling script %v", err)
+	}
+
+	L.Push(fn)
+	if err := L.PCall(0, 1, nil); err != nil {
+		if e, ok := err.(*lua.ApiError); ok && e.Object.Type() == lua.LTTable {
+			return fromLua(e.Object) // redis.call的错误
+		}
+		return fmt.Errorf("ERR Error running script %v", err)
+	}
+	return fromLua(L.Get(-1))
+}
+
+func stringTable(L *lua.LState, values []string) *lua.LTable {
+	t := L.CreateTable(len(values), 0)
+	for _, v := range values {
+		t.Append(lua.LString(v))
+	}
+	return t
+}
+
+// luaCall runs redis.call or redis.pcall,call raises the error of the command.
+func (self *Server) luaCall(L *lua.LState, c *client, raise bool) int {
+	args := make([]string, L.GetTop())
+	for i := range args {
+		switch v := L.Get(i + 1).(type) {
+		case lua.LString:
+			args[i] = string(v)
+		case lua.LNumber:
+			args[i] = strconv.FormatFloat(float64(v), 'g', 17, 64)
+		default:
+			L.RaiseError("Lua redis() command arguments must be strings or integers")
+		}
+	}
+	if len(args) == 0 {
+		L.RaiseError("Please specify at least one argument for redis.call()")
+	}
+
+	var reply any
+	if cmd, has := commands[strings.ToLower(args[0])]; has {
+		reply = cmd(self, c, args[1:])
+	} else {
+		reply = fmt.Errorf("ERR unknown command '%s'", args[0])
+	}
+
+	if err, ok := reply.(error); ok && raise {
+		t := L.NewTable()
+		L.SetField(t, "err", lua.LString(err.Error()))
+		L.Error(t, 0)
+	}
+
+	L.Push(toLua(L, reply))
+	return 1
+}
+
+// toLua converts a reply of a command,nil becomes false.
+func toLua(L *lua.LState, reply any) lua.LValue {
+	switch v := reply.(type) {
+	case status:
+		t := L.NewTable()
+		L.SetField(t, "ok", lua.LString(v))
+		return t
+	case error:
+		t := L.NewTable()
+		L.SetField(t, "err", lua.LString(v.Error()))
+		return t
+	case int:
+		return lua.LNumber(v)
+	case int64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []byte:
+		return lua.LString(v)
+	case []string:
+		return stringTable(L, v)
+	case []any:
+		if v == nil {
+			return lua.LFalse
+		}
+		t := L.CreateTable(len(v), 0)
+		for _, item := range v {
+			t.Append(toLua(L, item))
+		}
+		return t
+	}
+	return lua.LFalse
+}
+
+// fromLua converts the value returned by a script,numbers are truncated to integers
+// and an array stops at the first nil.
+func fromLua(v lua.LValue) any {
+	switch v := v.(type) {
+	case lua.LNumber:
+		return int64(v)
+	case lua.LString:
+		return string(v)
+	case lua.LBool:
+		if v {
+			return 1
+		}
+		return nil
+	case *lua.LTable:
+		if e, ok := v.RawGetString("err").(lua.LString); ok {
+			return errors.New(string(e))
+		}
+		if s, ok := v.RawGetString("ok").(lua.LString); ok {
+			return status(s)
+		}
+
+		items := []any{}
+		for i := 1; ; i++ {
+			item := v.RawGetInt(i)
+			if item == lua.LNil {
+				return items
+			}
+			items = append(items, fromLua(item))
+		}
+	}
+	return nil
+}
+
+// EVAL script numkeys [key ...] [arg ...]
+func cmdEval(s *Server, c *client, args []string) any {
+	if len(args) < 2 {
+		return errWrongArgs
+	}
+
+	s.load(args[0])
+	return s.runScript(c, args[0], args[1:])
+}
+
+// EVALSHA sha1 numkeys [key ...] [arg ...]
+func cmdEvalSha(s *Server, c *client, args []string) any {
+	if len(args) < 2 {
+		return errWrongArgs
+	}
+
+	src, has := s.scripts[strings.ToLower(args[0])]
+	if !has {
+		return errNoScript
+	}
+	return s.runScript(c, src, args[1:])
+}
+
+// SCRIPT LOAD|EXISTS|FLUSH
+func cmdScript(s *Server, c *client, args []string) any {
+	if len(args) < 1 {
+		return errWrongArgs
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "load":
+		if len(args) != 2 {
+			return errWrongArgs
+		}
+		return s.load(args[1])
+	case "exists":
+		found := make([]any, len(args)-1)
+		for i, h := range args[1:] {
+			if _, has := s.scripts[strings.ToLower(h)]; has {
+				found[i] = 1
+			} else {
+				found[i] = 0
+			}
+		}
+		return found
+	case "flush":
+		s.scripts = nil
+		return ok
+	}
+	return errSyntax
+}
@@ -0,0 +1,368 @@
+// Package redistest provides an in-process RESP server for hermetic tests of the redis adapter.
+// EVAL runs the real Lua scripts by gopher-lua,so the scripts of the adapters are tested as they are.
+//
+// usage:
+//
+//	srv := redistest.Run(t)
+//	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+type (
+	// status is a simple string reply like "+OK".
+	status string
+
+	// handler runs a command with the server locked and returns the reply.
+	handler func(s *Server, c *client, args []string) any
+
+	entry struct {
+		value    []byte
+		hash     map[string][]byte // not nil if the key holds a hash
+		expireAt time.Time         // zero means never expire
+	}
+
+	client struct {
+		conn net.Conn
+		r    *bufio.Reader
+		w    *bufio.Writer
+
+		// transaction state
+		watched map[string]uint64 // key -> revision when watched
+		multi   bool
+		dirty   bool // a queued command was rejected
+		queue   [][]string
+	}
+
+	// Server is a tiny redis which keeps the data in memory.
+	// it implements only the commands needed by the adapters of this module.
+	Server struct {
+		sync.Mutex
+		ln      net.Listener
+		data    map[string]*entry
+		clients map[*client]struct{}
+		wg      sync.WaitGroup
+		closed  bool
+		clock   cacher.Clock
+		rev     uint64            // bumped by every write
+		revs    map[string]uint64 // revision of the last write of the key
+		scripts map[string]string // sha1 -> Lua source known by EVALSHA
+	}
+)
+
+var (
+	ok = status("OK")
+
+	errSyntax    = errors.New("ERR syntax error")
+	errNotInt    = errors.New("ERR value is not an integer or out of range")
+	errWrongArgs = errors.New("ERR wrong number of arguments")
+	errCursor    = errors.New("ERR invalid cursor")
+	errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+)
+
+// NewServer starts a server on a random local port.
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		ln:      ln,
+		data:    make(map[string]*entry),
+		clients: make(map[*client]struct{}),
+		clock:   cacher.SystemClock,
+		revs:    make(map[string]uint64),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Run starts a server which is closed when the test finishs.
+func Run(t testing.TB) *Server {
+	t.Helper()
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Addr returns the address to dial.
+func (self *Server) Addr() string {
+	return self.ln.Addr().String()
+}
+
+// Close stops the server and drops all connections.
+func (self *Server) Close() {
+	self.Lock()
+	if self.closed {
+		self.Unlock()
+		return
+	}
+	self.closed = true
+	self.ln.Close()
+	for c := range self.clients {
+		c.conn.Close()
+	}
+	self.Unlock()
+
+	self.wg.Wait()
+}
+
+// Keys returns all keys which are not expired in order.
+func (self *Server) Keys() []string {
+	self.Lock()
+	defer self.Unlock()
+	return self.sortedKeys()
+}
+
+// Get returns the raw value of the key.
+func (self *Server) Get(key string) ([]byte, bool) {
+	self.Lock()
+	defer self.Unlock()
+
+	if e := self.lookup(key); e != nil {
+		return e.value, true
+	}
+	return nil, false
+}
+
+// FlushAll deletes all keys.
+func (self *Server) FlushAll() {
+	self.Lock()
+	self.flush()
+	self.Unlock()
+}
+
+func (self *Server) flush() {
+	for key := range self.data {
+		self.touch(key)
+	}
+	self.data = make(map[string]*entry)
+}
+
+// touch marks the key as modified for the watching clients.
+func (self *Server) touch(key string) {
+	self.rev++
+	self.revs[key] = self.rev
+}
+
+// SetClock replaces the clock which expires the keys.
+func (self *Server) SetClock(clock cacher.Clock) {
+	self.Lock()
+	self.clock = clock
+	self.Unlock()
+}
+
+func (self *Server) now() time.Time {
+	return self.clock.Now()
+}
+
+// lookup returns the entry and drops it if it is expired.
+func (self *Server) lookup(key string) *entry {
+	e, has := self.data[key]
+	if !has {
+		return nil
+	}
+
+	if !e.expireAt.IsZero() && !self.now().Before(e.expireAt) {
+		delete(self.data, key)
+		self.touch(key)
+		return nil
+	}
+	return e
+}
+
+func (self *Server) serve() {
+	defer self.wg.Done()
+	for {
+		conn, err := self.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &client{
+			conn: conn,
+			r:    bufio.NewReader(conn),
+			w:    bufio.NewWriter(conn),
+		}
+
+		self.Lock()
+		if self.closed {
+			self.Unlock()
+			conn.Close()
+			return
+		}
+		self.clients[c] = struct{}{}
+		self.wg.Add(1)
+		self.Unlock()
+
+		go self.handle(c)
+	}
+}
+
+func (self *Server) handle(c *client) {
+	defer self.wg.Done()
+	defer func() {
+		self.Lock()
+		delete(self.clients, c)
+		self.Unlock()
+		c.conn.Close()
+	}()
+
+	for {
+		args, err := readCommand(c.r)
+		if err != nil {
+			return
+		}
+
+		reply := self.exec(c, args)
+		if err := writeReply(c.w, reply); err != nil {
+			return
+		}
+
+		// 管道中的命令一起返回
+		if c.r.Buffered() == 0 {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (self *Server) exec(c *client, args []string) any {
+	if len(args) == 0 {
+		return errors.New("ERR empty command")
+	}
+
+	name := strings.ToLower(args[0])
+	cmd, has := commands[name]
+	if !has {
+		if c.multi {
+			c.dirty = true
+		}
+		return fmt.Errorf("ERR unknown command '%s'", args[0])
+	}
+
+	self.Lock()
+	defer self.Unlock()
+
+	if c.multi && !immediate[name] {
+		c.queue = append(c.queue, args)
+		return status("QUEUED")
+	}
+
+	return cmd(self, c, args[1:])
+}
+
+// readCommand reads a RESP array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		// inline command
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("redistest: expected bulk string, got %q", line)
+		}
+
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeReply(w *bufio.Writer, reply any) error {
+	var err error
+	switch v := reply.(type) {
+	case nil:
+		_, err = w.WriteString("$-1\r\n")
+	case status:
+		_, err = w.WriteString("+" + string(v) + "\r\n")
+	case error:
+		_, err = w.WriteString("-" + v.Error() + "\r\n")
+	case int:
+		_, err = fmt.Fprintf(w, ":%d\r\n", v)
+	case int64:
+		_, err = fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		_, err = fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []byte:
+		_, err = fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []string:
+		if _, err = fmt.Fprintf(w, "*%d\r\n", len(v)); err != nil {
+			return err
+		}
+		for _, s := range v {
+			if err = writeReply(w, s); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if v == nil {
+			_, err = w.WriteString("*-1\r\n")
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "*%d\r\n", len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err = writeReply(w, item); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("redistest: unsupported reply %T", reply)
+	}
+	return err
+}
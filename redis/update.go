@@ -0,0 +1,95 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/volts-dev/cacher"
+)
+
+const (
+	defaultUpdateRetries = 16
+	updateBackoff        = time.Millisecond
+)
+
+// Update applies the function in a WATCH/MULTI transaction and retries
+// when the key is changed by others meanwhile,the function may run several times.
+func (self *RedisCache) Update(ctx context.Context, key string, fn cacher.UpdateFunc) error {
+	if !self.config.Active {
+		return cacher.ErrInactive
+	}
+
+	if self.config.Client == nil {
+		return errRedisNil
+	}
+
+	retries := self.config.UpdateRetries
+	if retries <= 0 {
+		retries = defaultUpdateRetries
+	}
+
+	var (
+		b   []byte
+		del bool
+	)
+	txf := func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, self.getKey(key)).Bytes()
+		exists := err == nil
+		if err != nil && err != redis.Nil {
+			return err
+		}
+
+		var old any
+		if exists {
+			if err := self.config.Unmarshal(current, &old); err != nil {
+				return err
+			}
+		}
+
+		var value any
+		var ttl time.Duration
+		value, ttl, del = fn(old, exists)
+		if !del {
+			if b, err = self.config.Marshal(value); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if del {
+				pipe.Del(ctx, self.getKey(key))
+			} else {
+				pipe.Set(ctx, self.getKey(key), b, (&cacher.CacheBlock{Key: key, TTL: ttl}).Ttl())
+			}
+			return nil
+		})
+		return err
+	}
+
+	var err error
+	for i := 0; i < retries; i++ {
+		err = self.config.Client.Watch(ctx, txf, self.getKey(key))
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(updateBackoff * time.Duration(i+1)):
+		}
+	}
+
+	self.DeleteFromLocalCache(key)
+	if errors.Is(err, redis.TxFailedErr) {
+		return fmt.Errorf("cache: update %s gave up after %d retries: %w", key, retries, cacher.ErrVersionMismatch)
+	}
+
+	if err != nil {
+		self.config.Logger.Error("cache: redis update failed", "key", key, "error", err)
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cespare/xxhash/v2"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/volts-dev/cacher"
+)
+
+var errRedisNil = errors.New("cache: Redis is nil")
+
+// version is the ETag of the stored bytes,it is never 0.
+func version(b []byte) uint64 {
+	if v := xxhash.Sum64(b); v != 0 {
+		return v
+	}
+	return 1
+}
+
+// GetWithVersion reads the value from redis with the hash of its bytes as version.
+// the local cache is skipped since it may be stale.
+func (self *RedisCache) GetWithVersion(key string, ctx ...context.Context) (any, uint64, error) {
+	if !self.config.Active {
+		return nil, 0, cacher.ErrInactive
+	}
+
+	if self.config.Client == nil {
+		return nil, 0, errRedisNil
+	}
+
+	b, err := self.getBytes(cacher.Context(ctx...), key, true)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var value any
+	if err := self.config.Unmarshal(b, &value); err != nil {
+		return nil, 0, err
+	}
+	return value, version(b), nil
+}
+
+// CompareAndSwap sets the block in a WATCH/MULTI transaction if the key still has the expected version.
+func (self *RedisCache) CompareAndSwap(ctx context.Context, key string, expected uint64, block *cacher.CacheBlock) error {
+	if !self.config.Active {
+		return cacher.ErrInactive
+	}
+
+	if self.config.Client == nil {
+		return errRedisNil
+	}
+
+	b, err := self.config.Marshal(block.Value)
+	if err != nil {
+		return err
+	}
+
+	ttl := block.Ttl()
+	err = self.config.Client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, self.getKey(key)).Bytes()
+		switch {
+		case err == redis.Nil:
+			if expected != 0 {
+				return cacher.ErrVersionMismatch
+			}
+		case err != nil:
+			return err
+		case version(current) != expected:
+			return cacher.ErrVersionMismatch
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, self.getKey(key), b, ttl)
+			return nil
+		})
+		return err
+	}, self.getKey(key))
+
+	if errors.Is(err, redis.TxFailedErr) {
+		err = cacher.ErrVersionMismatch
+	}
+
+	if err != nil {
+		// the local copy may be stale
+		self.DeleteFromLocalCache(key)
+		if !errors.Is(err, cacher.ErrVersionMismatch) {
+			self.config.Logger.Error("cache: redis compare and swap failed", "key", key, "error", err)
+		}
+		return err
+	}
+
+	block.Version = version(b)
+	if self.config.LocalCache != nil && !block.SkipLocalCache {
+		bb := block.Clone()
+		bb.Key = key
+		bb.Value = b
+		self.config.LocalCache.Set(bb)
+	}
+	return nil
+}
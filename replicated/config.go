@@ -0,0 +1,43 @@
+package replicated
+
+import (
+	"github.com/volts-dev/cacher"
+)
+
+type (
+	Option func(*Config)
+
+	Config struct {
+		cacher.Config
+		Active      bool
+		WriteQuorum int  `field:"write_quorum"` // successful writes required,default is a majority
+		ReadQuorum  int  `field:"read_quorum"`  // answers awaited by a read,default is 1 which is the fastest
+		NoRepair    bool `field:"no_repair"`    // only count the divergent reads
+		Logger      cacher.Logger
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+// WithWriteQuorum sets how many replicas must accept a write.
+func WithWriteQuorum(n int) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("write_quorum", n)
+	}
+}
+
+// WithReadQuorum sets how many answers a read waits for.
+func WithReadQuorum(n int) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("read_quorum", n)
+	}
+}
+
+// WithNoRepair disables the read repair.
+func WithNoRepair() cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("no_repair", true)
+	}
+}
@@ -0,0 +1,432 @@
+// Package replicated writes every cache to several cachers and reads the fastest answers,
+// so the cache survives the loss of a replica.
+//
+//	chr := replicated.New()
+//	chr.AddReplica(redisA, redisB, redisC)
+//
+// a write waits for all replicas and fails if less than WriteQuorum succeed,a read returns
+// once ReadQuorum replicas answer and awaits the others in the background. if the replicas disagree,
+// the replicas which differ from the majority are repaired,a miss counts as a vote
+// so a key deleted from the majority is deleted from the others too.
+package replicated
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/volts-dev/cacher"
+)
+
+// stripes of the write generations
+const stripes = 256
+
+var ErrNoQuorum = errors.New("cache: replicas did not reach the quorum")
+
+var Replicated = cacher.Register("Replicated", func() cacher.ICacher {
+	return New()
+})
+
+type (
+	ReplicatedCache struct {
+		sync.RWMutex
+		config   *Config
+		replicas []cacher.ICacher
+		wg       sync.WaitGroup // background repairs
+
+		// a repair is skipped if the key is written since the read,
+		// the writes hold the read lock of the stripe and the repairs the write lock.
+		locks [stripes]sync.RWMutex
+		gens  [stripes]uint64
+
+		divergences   uint64
+		repairs       uint64
+		repairErrors  uint64
+		writeFailures uint64
+		noQuorum      uint64
+	}
+
+	// Stats are the counters of the replication.
+	Stats struct {
+		Divergences   uint64 `json:"divergences"`    // reads whose replicas disagreed
+		Repairs       uint64 `json:"repairs"`        // replicas rewritten by read repair
+		RepairErrors  uint64 `json:"repair_errors"`  // failed repairs
+		WriteFailures uint64 `json:"write_failures"` // failed writes of single replicas
+		NoQuorum      uint64 `json:"no_quorum"`      // operations which did not reach the quorum
+	}
+
+	// answer is the result of one replica.
+	answer struct {
+		replica int
+		value   any
+		err     error
+	}
+)
+
+func New(opts ...cacher.Option) *ReplicatedCache {
+	cfg := &Config{
+		Active: true,
+	}
+	cfg.Init(opts...)
+
+	if cfg.Logger == nil {
+		cfg.Logger = cacher.DefaultLogger()
+	}
+
+	return &ReplicatedCache{config: cfg}
+}
+
+// AddReplica adds the cachers which every cache is written to.
+// the data is not copied to a new replica,it is filled by the writes and the read repairs.
+func (self *ReplicatedCache) AddReplica(chrs ...cacher.ICacher) {
+	self.Lock()
+	defer self.Unlock()
+	self.replicas = append(self.replicas[:len(self.replicas):len(self.replicas)], chrs...)
+}
+
+// Replicas returns the replicas.
+func (self *ReplicatedCache) Replicas() []cacher.ICacher {
+	self.RLock()
+	defer self.RUnlock()
+	return self.replicas
+}
+
+func (self *ReplicatedCache) Init(opts ...cacher.Option) {
+	self.config.Init(opts...)
+}
+
+func (self *ReplicatedCache) String() string {
+	return "replicated"
+}
+
+func (self *ReplicatedCache) Active(on ...bool) bool {
+	if len(on) > 0 {
+		self.config.Active = on[0]
+	}
+
+	return self.config.Active
+}
+
+// quorums returns the write and read quorums of n replicas,
+// a quorum out of range is a majority for writes and 1 for reads.
+func (self *ReplicatedCache) quorums(n int) (int, int) {
+	w, r := self.config.WriteQuorum, self.config.ReadQuorum
+	if w <= 0 || w > n {
+		w = n/2 + 1
+	}
+	if r <= 0 || r > n {
+		r = 1
+	}
+	return w, r
+}
+
+// write runs fn on every replica in parallel and fails if less than the quorum succeed,
+// it waits for all replicas so a read after it does not see an older value.
+func (self *ReplicatedCache) write(op string, idx []int, fn func(chr cacher.ICacher) error) error {
+	replicas := self.Replicas()
+	if len(replicas) == 0 {
+		return ErrNoQuorum
+	}
+	quorum, _ := self.quorums(len(replicas))
+
+	// 写入前后都递增,读到中间状态的修复会被跳过
+	for _, i := range idx {
+		self.locks[i].RLock()
+		atomic.AddUint64(&self.gens[i], 1)
+	}
+	defer func() {
+		for _, i := range idx {
+			atomic.AddUint64(&self.gens[i], 1)
+			self.locks[i].RUnlock()
+		}
+	}()
+
+	results := make(chan error, len(replicas))
+	for _, chr := range replicas {
+		go func(chr cacher.ICacher) {
+			err := fn(chr)
+			if err != nil {
+				atomic.AddUint64(&self.writeFailures, 1)
+				self.config.Logger.Warn("cache: replica write failed", "op", op, "replica", chr.String(), "error", err)
+			}
+			results <- err
+		}(chr)
+	}
+
+	var errs []error
+	for range replicas {
+		if err := <-results; err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if ok := len(replicas) - len(errs); ok < quorum {
+		atomic.AddUint64(&self.noQuorum, 1)
+		return fmt.Errorf("%w: %s %d/%d: %w", ErrNoQuorum, op, ok, quorum, errors.Join(errs...))
+	}
+	return nil
+}
+
+func (self *ReplicatedCache) Set(block *cacher.CacheBlock) error {
+	if !self.config.Active {
+		return nil
+	}
+
+	return self.write("set", stripe(block.Key), func(chr cacher.ICacher) error {
+		return chr.Set(block.Clone()) // 副本会修改块
+	})
+}
+
+func (self *ReplicatedCache) Delete(key string, ctx ...context.Context) error {
+	return self.write("delete", stripe(key), func(chr cacher.ICacher) error {
+		return chr.Delete(key, ctx...)
+	})
+}
+
+func (self *ReplicatedCache) Clear() error {
+	all := make([]int, stripes)
+	for i := range all {
+		all[i] = i
+	}
+
+	return self.write("clear", all, func(chr cacher.ICacher) error {
+		return chr.Clear()
+	})
+}
+
+// Get returns the most common answer of the first ReadQuorum answers,
+// the other answers are awaited in the background to repair the divergent replicas.
+func (self *ReplicatedCache) Get(key string, ctx ...context.Context) (any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	replicas := self.Replicas()
+	if len(replicas) == 0 {
+		return nil, ErrNoQuorum
+	}
+	_, quorum := self.quorums(len(replicas))
+	i := stripe(key)[0]
+	gen := atomic.LoadUint64(&self.gens[i])
+
+	results := make(chan answer, len(replicas))
+	for i, chr := range replicas {
+		go func(i int, chr cacher.ICacher) {
+			value, err := chr.Get(key, ctx...)
+			results <- answer{replica: i, value: value, err: err}
+		}(i, chr)
+	}
+
+	var answers []answer
+	var errs []error
+	for done := 0; done < len(replicas) && len(answers) < quorum; done++ {
+		a := <-results
+		if a.err == nil || errors.Is(a.err, cacher.ErrCacheMiss) {
+			answers = append(answers, a)
+		} else {
+			errs = append(errs, a.err)
+		}
+	}
+
+	rest := len(replicas) - len(answers) - len(errs)
+	self.wg.Add(1)
+	go func() {
+		defer self.wg.Done()
+		all := append([]answer(nil), answers...)
+		for i := 0; i < rest; i++ {
+			if a := <-results; a.err == nil || errors.Is(a.err, cacher.ErrCacheMiss) {
+				all = append(all, a)
+			}
+		}
+		self.repair(replicas, key, gen, all)
+	}()
+
+	if len(answers) < quorum {
+		atomic.AddUint64(&self.noQuorum, 1)
+		return nil, fmt.Errorf("%w: get %d/%d: %w", ErrNoQuorum, len(answers), quorum, errors.Join(errs...))
+	}
+
+	if winner, _ := vote(answers); winner.err == nil {
+		return winner.value, nil
+	}
+	return nil, cacher.ErrCacheMiss
+}
+
+// stripe returns the stripe of the key.
+func stripe(key string) []int {
+	return []int{int(xxhash.Sum64String(key) % stripes)}
+}
+
+// same reports whether the answers agree,two misses agree too.
+func same(a, b answer) bool {
+	if a.err != nil || b.err != nil {
+		return a.err != nil && b.err != nil
+	}
+	return reflect.DeepEqual(a.value, b.value)
+}
+
+// vote returns the most common answer,a miss counts like a value so a delete
+// which reached the majority is not undone. on a tie a value beats a miss
+// and unique is false.
+func vote(answers []answer) (winner answer, unique bool) {
+	if len(answers) == 0 {
+		return answer{err: cacher.ErrCacheMiss}, false
+	}
+
+	best, votes, tie := 0, 0, false
+	for i, a := range answers {
+		n := 0
+		for _, b := range answers {
+			if same(a, b) {
+				n++
+			}
+		}
+
+		switch {
+		case n > votes:
+			best, votes, tie = i, n, false
+		case n == votes && !same(answers[best], a):
+			tie = true
+			if answers[best].err != nil && a.err == nil {
+				best = i
+			}
+		}
+	}
+	return answers[best], !tie
+}
+
+// repair rewrites the replicas which differ from the winner of all answers,
+// unless the key is written since the read started at the generation.
+// if the majority misses the key it is deleted from the others,a tie is not repaired.
+func (self *ReplicatedCache) repair(replicas []cacher.ICacher, key string, gen uint64, answers []answer) {
+	winner, unique := vote(answers)
+
+	var stale []int
+	for _, a := range answers {
+		if !same(a, winner) {
+			stale = append(stale, a.replica)
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	i := stripe(key)[0]
+	self.locks[i].Lock()
+	defer self.locks[i].Unlock()
+
+	if atomic.LoadUint64(&self.gens[i]) != gen {
+		return // 读到的可能是旧值
+	}
+
+	atomic.AddUint64(&self.divergences, 1)
+	if self.config.NoRepair || !unique {
+		return
+	}
+
+	if winner.err != nil {
+		// 多数副本已删除
+		for _, i := range stale {
+			self.repairWith(replicas[i], key, func(chr cacher.ICacher) error {
+				return chr.Delete(key)
+			})
+		}
+		return
+	}
+
+	// 尽量保留原来的过期时间
+	block := &cacher.CacheBlock{Key: key, Value: winner.value}
+	if ttler, ok := replicas[winner.replica].(cacher.ITTLer); ok {
+		ttl, err := ttler.TTL(key)
+		if err != nil {
+			return // 赢家已被删除
+		}
+		block.TTL = ttl
+		if ttl < 0 {
+			block.TTL = -1
+		} else if ttl < time.Second {
+			return // 即将过期
+		}
+	}
+
+	for _, i := range stale {
+		self.repairWith(replicas[i], key, func(chr cacher.ICacher) error {
+			return chr.Set(block.Clone())
+		})
+	}
+}
+
+// repairWith runs the repair on the replica and counts it.
+func (self *ReplicatedCache) repairWith(chr cacher.ICacher, key string, fn func(chr cacher.ICacher) error) {
+	if err := fn(chr); err != nil {
+		atomic.AddUint64(&self.repairErrors, 1)
+		self.config.Logger.Warn("cache: read repair failed", "key", key, "replica", chr.String(), "error", err)
+		return
+	}
+	atomic.AddUint64(&self.repairs, 1)
+}
+
+func (self *ReplicatedCache) Exists(key string, ctx ...context.Context) bool {
+	_, err := self.Get(key, ctx...)
+	return err == nil
+}
+
+// Keys returns the union of the keys of all replicas.
+func (self *ReplicatedCache) Keys(ctx ...context.Context) []string {
+	if !self.config.Active {
+		return nil
+	}
+
+	seen := make(map[string]struct{})
+	for _, chr := range self.Replicas() {
+		for _, key := range chr.Keys(ctx...) {
+			seen[key] = struct{}{}
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (self *ReplicatedCache) Len() int {
+	return len(self.Keys())
+}
+
+// Wait blocks until the background repairs are done.
+func (self *ReplicatedCache) Wait() {
+	self.wg.Wait()
+}
+
+// Close waits for the background work and closes every replica.
+func (self *ReplicatedCache) Close() error {
+	self.wg.Wait()
+
+	var errs []error
+	for _, chr := range self.Replicas() {
+		if err := chr.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", chr, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns a snapshot of the counters.
+func (self *ReplicatedCache) Stats() Stats {
+	return Stats{
+		Divergences:   atomic.LoadUint64(&self.divergences),
+		Repairs:       atomic.LoadUint64(&self.repairs),
+		RepairErrors:  atomic.LoadUint64(&self.repairErrors),
+		WriteFailures: atomic.LoadUint64(&self.writeFailures),
+		NoQuorum:      atomic.LoadUint64(&self.noQuorum),
+	}
+}
@@ -0,0 +1,132 @@
+package replicated
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/cachertest"
+	"github.com/volts-dev/cacher/memory"
+	"github.com/volts-dev/cacher/readonly"
+)
+
+func replicate(chrs []cacher.ICacher, opts ...cacher.Option) *ReplicatedCache {
+	chr := New(opts...)
+	chr.AddReplica(chrs...)
+	return chr
+}
+
+func TestConformance(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	cachertest.Run(t, func(t *testing.T) cacher.ICacher {
+		return replicate([]cacher.ICacher{
+			memory.New(cacher.WithClock(clock)),
+			memory.New(cacher.WithClock(clock)),
+			memory.New(cacher.WithClock(clock)),
+		})
+	}, cachertest.WithSleep(clock.Advance))
+}
+
+func TestQuorum(t *testing.T) {
+	a, b := memory.New(), memory.New()
+	frozen := readonly.Wrap(memory.New())
+
+	chr := replicate([]cacher.ICacher{a, b, frozen})
+	if err := chr.Set(&cacher.CacheBlock{Key: "key", Value: "value"}); err != nil {
+		t.Fatalf("set with a majority %v", err)
+	}
+	if !a.Exists("key") || !b.Exists("key") {
+		t.Fatal("key is not replicated")
+	}
+
+	chr.Init(WithWriteQuorum(3))
+	err := chr.Set(&cacher.CacheBlock{Key: "key", Value: "value"})
+	if !errors.Is(err, ErrNoQuorum) || !errors.Is(err, cacher.ErrReadOnly) {
+		t.Fatalf("set with all replicas %v", err)
+	}
+
+	if s := chr.Stats(); s.WriteFailures != 2 || s.NoQuorum != 1 {
+		t.Fatalf("stats %+v", s)
+	}
+}
+
+func TestReadRepair(t *testing.T) {
+	replicas := []*memory.TMemoryCache{memory.New(), memory.New(), memory.New(), memory.New()}
+	chr := replicate([]cacher.ICacher{replicas[0], replicas[1], replicas[2], replicas[3]}, WithReadQuorum(4))
+	defer chr.Close()
+
+	// 2个副本一致,1个不同,1个丢失
+	replicas[0].Set(&cacher.CacheBlock{Key: "key", Value: "value", TTL: time.Minute})
+	replicas[1].Set(&cacher.CacheBlock{Key: "key", Value: "value", TTL: time.Minute})
+	replicas[2].Set(&cacher.CacheBlock{Key: "key", Value: "stale"})
+
+	if v, err := chr.Get("key"); err != nil || v != "value" {
+		t.Fatalf("get %v %v", v, err)
+	}
+	chr.Wait()
+
+	if s := chr.Stats(); s.Divergences != 1 || s.Repairs != 2 || s.RepairErrors != 0 {
+		t.Fatalf("stats %+v", s)
+	}
+
+	for i, r := range replicas {
+		if v, _ := r.Get("key"); v != "value" {
+			t.Fatalf("replica %d has %v after repair", i, v)
+		}
+	}
+
+	if ttl, _ := replicas[3].TTL("key"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("ttl of the repaired replica %v", ttl)
+	}
+}
+
+func TestPartialDelete(t *testing.T) {
+	a, b, c := memory.New(), memory.New(), memory.New()
+	failing := readonly.Wrap(c)
+	chr := replicate([]cacher.ICacher{a, b, failing}, WithReadQuorum(3))
+	defer chr.Close()
+
+	for _, r := range []*memory.TMemoryCache{a, b, c} {
+		r.Set(&cacher.CacheBlock{Key: "key", Value: "value"})
+	}
+
+	// 删除在2个副本成功,达到法定数
+	if err := chr.Delete("key"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := chr.Get("key"); err != cacher.ErrCacheMiss {
+		t.Fatalf("get after a partial delete %v", err)
+	}
+	chr.Wait()
+	if a.Exists("key") || b.Exists("key") {
+		t.Fatal("deleted key is resurrected by read repair")
+	}
+
+	// 恢复后的副本被修复为删除
+	chr = replicate([]cacher.ICacher{a, b, c}, WithReadQuorum(3))
+	if _, err := chr.Get("key"); err != cacher.ErrCacheMiss {
+		t.Fatalf("get with a stale replica %v", err)
+	}
+	chr.Wait()
+	if c.Exists("key") || chr.Stats().Repairs != 1 {
+		t.Fatalf("stale replica is not deleted %+v", chr.Stats())
+	}
+}
+
+func TestNoRepair(t *testing.T) {
+	a, b := memory.New(), memory.New()
+	chr := replicate([]cacher.ICacher{a, b}, WithReadQuorum(2), WithNoRepair())
+	defer chr.Close()
+
+	a.Set(&cacher.CacheBlock{Key: "key", Value: "value"})
+	if v, err := chr.Get("key"); err != nil || v != "value" {
+		t.Fatalf("a value beats a miss on a tie %v %v", v, err)
+	}
+	chr.Wait()
+
+	if b.Exists("key") || chr.Stats().Divergences != 1 {
+		t.Fatal("replica is repaired")
+	}
+}
@@ -0,0 +1,65 @@
+package retry
+
+import (
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+type (
+	Option func(*Config)
+
+	Config struct {
+		cacher.Config
+		MaxAttempts   int                  `field:"max_attempts"` // calls of an operation including the first one
+		Backoff       time.Duration        // delay before the first retry,doubled by every retry
+		MaxBackoff    time.Duration        `field:"max_backoff"` // upper bound of a delay
+		Jitter        float64              // random share 0..1 taken off a delay
+		Timeout       time.Duration        // deadline of an operation including its retries,0 means none
+		Retryable     func(err error) bool // default retries timeouts,broken connections and redis redirects
+		RetryCounters bool                 `field:"retry_counters"` // also retry Incr and Decr which are not idempotent
+		Logger        cacher.Logger
+		Clock         cacher.Clock
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+// WithMaxAttempts limits the calls of an operation,1 disables the retries.
+func WithMaxAttempts(n int) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("max_attempts", n)
+	}
+}
+
+// WithBackoff sets the first delay,its upper bound and the random share taken off every delay.
+func WithBackoff(backoff, max time.Duration, jitter float64) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("backoff", backoff)
+		cfg.SetByField("max_backoff", max)
+		cfg.SetByField("jitter", jitter)
+	}
+}
+
+// WithTimeout sets the deadline of an operation including its retries.
+func WithTimeout(timeout time.Duration) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("timeout", timeout)
+	}
+}
+
+// WithRetryable decides which errors are retried.
+func WithRetryable(fn func(err error) bool) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("retryable", fn)
+	}
+}
+
+// WithRetryCounters retries Incr and Decr too,a retried counter may be changed twice.
+func WithRetryCounters(on bool) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("retry_counters", on)
+	}
+}
@@ -0,0 +1,207 @@
+// Package retry retries the operations of a remote cacher which fail by transient errors,
+// e.g. timeouts,broken connections or a redis cluster which is resharding.
+//
+//	chr := retry.New(redisCache, retry.WithMaxAttempts(3), retry.WithTimeout(time.Second))
+//
+// the delays grow exponentially from Backoff up to MaxBackoff with a random jitter,
+// Timeout bounds an operation including all its retries. Incr and Decr are not idempotent
+// and only called once unless RetryCounters is set.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+var errNoCounter = errors.New("cache: cacher does not support counters")
+
+// redirects are the prefixes of the redis errors which succeed once retried.
+var redirects = []string{"MOVED ", "ASK ", "TRYAGAIN", "LOADING", "CLUSTERDOWN", "MASTERDOWN"}
+
+type (
+	// RetryCache wraps a cacher and retries its failed operations.
+	RetryCache struct {
+		cacher.ICacher
+		config *Config
+	}
+
+	// redisError is implemented by the errors replied by a redis server.
+	redisError interface {
+		error
+		RedisError()
+	}
+)
+
+func New(chr cacher.ICacher, opts ...cacher.Option) *RetryCache {
+	cfg := &Config{
+		MaxAttempts: 3,
+		Backoff:     10 * time.Millisecond,
+		MaxBackoff:  time.Second,
+		Jitter:      0.5,
+	}
+	cfg.Init(opts...)
+
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.Jitter < 0 {
+		cfg.Jitter = 0
+	} else if cfg.Jitter > 1 {
+		cfg.Jitter = 1
+	}
+	if cfg.Retryable == nil {
+		cfg.Retryable = Retryable
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = cacher.DefaultLogger()
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = cacher.SystemClock
+	}
+
+	return &RetryCache{
+		ICacher: chr,
+		config:  cfg,
+	}
+}
+
+// Retryable reports whether the error is transient:timeouts,broken or refused connections
+// and the redis replies MOVED,ASK,TRYAGAIN,LOADING,CLUSTERDOWN and MASTERDOWN.
+// misses,canceled calls and all other errors are final.
+func Retryable(err error) bool {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE):
+		return true
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+
+	var re redisError
+	if errors.As(err, &re) {
+		for _, prefix := range redirects {
+			if strings.HasPrefix(re.Error(), prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (self *RetryCache) Init(opts ...cacher.Option) {
+	self.ICacher.Init(opts...)
+}
+
+// Unwrap returns the underlying cacher.
+func (self *RetryCache) Unwrap() cacher.ICacher {
+	return self.ICacher
+}
+
+// delay returns the wait before the retry after the attempt,counted from 0.
+func (self *RetryCache) delay(attempt int) time.Duration {
+	d := self.config.Backoff << attempt
+	if max := self.config.MaxBackoff; max > 0 && (d > max || d < self.config.Backoff) {
+		d = max // 含溢出
+	}
+	return d - time.Duration(rand.Float64()*self.config.Jitter*float64(d))
+}
+
+// do calls fn until it succeeds,fails by a final error,runs out of attempts or the context ends.
+func (self *RetryCache) do(ctx context.Context, op string, attempts int, fn func(ctx context.Context) error) error {
+	if self.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, self.config.Timeout)
+		defer cancel()
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt+1 >= attempts || !self.config.Retryable(err) {
+			return err
+		}
+
+		delay := self.delay(attempt)
+		self.config.Logger.Debug("cache: retrying", "op", op, "attempt", attempt+1, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-self.config.Clock.After(delay):
+		}
+	}
+}
+
+func (self *RetryCache) Get(key string, ctx ...context.Context) (value any, err error) {
+	err = self.do(cacher.Context(ctx...), "get", self.config.MaxAttempts, func(ctx context.Context) (err error) {
+		value, err = self.ICacher.Get(key, ctx)
+		return
+	})
+	return
+}
+
+func (self *RetryCache) Set(block *cacher.CacheBlock) error {
+	return self.do(block.Context(), "set", self.config.MaxAttempts, func(ctx context.Context) error {
+		if ctx != block.Context() {
+			block = block.Clone() // 带上超时
+			block.Ctx = ctx
+		}
+		return self.ICacher.Set(block)
+	})
+}
+
+func (self *RetryCache) Delete(key string, ctx ...context.Context) error {
+	return self.do(cacher.Context(ctx...), "delete", self.config.MaxAttempts, func(ctx context.Context) error {
+		return self.ICacher.Delete(key, ctx)
+	})
+}
+
+func (self *RetryCache) Clear() error {
+	return self.do(context.Background(), "clear", self.config.MaxAttempts, func(context.Context) error {
+		return self.ICacher.Clear()
+	})
+}
+
+// Incr increases the counter once unless RetryCounters is set.
+func (self *RetryCache) Incr(key string) error {
+	return self.count("incr", func(counter cacher.ICounter) error {
+		return counter.Incr(key)
+	})
+}
+
+// Decr decreases the counter once unless RetryCounters is set.
+func (self *RetryCache) Decr(key string) error {
+	return self.count("decr", func(counter cacher.ICounter) error {
+		return counter.Decr(key)
+	})
+}
+
+func (self *RetryCache) count(op string, fn func(counter cacher.ICounter) error) error {
+	counter, ok := self.ICacher.(cacher.ICounter)
+	if !ok {
+		return errNoCounter
+	}
+
+	attempts := 1
+	if self.config.RetryCounters {
+		attempts = self.config.MaxAttempts
+	}
+	return self.do(context.Background(), op, attempts, func(context.Context) error {
+		return fn(counter)
+	})
+}
@@ -0,0 +1,160 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/cachertest"
+	"github.com/volts-dev/cacher/memory"
+)
+
+// flaky fails the next calls by err.
+type flaky struct {
+	*memory.TMemoryCache
+	err   error
+	fails int
+	calls int
+}
+
+func (self *flaky) fail() error {
+	self.calls++
+	if self.fails > 0 {
+		self.fails--
+		return self.err
+	}
+	return nil
+}
+
+func (self *flaky) Get(key string, ctx ...context.Context) (any, error) {
+	if err := self.fail(); err != nil {
+		return nil, err
+	}
+	return self.TMemoryCache.Get(key, ctx...)
+}
+
+func (self *flaky) Set(block *cacher.CacheBlock) error {
+	if err := self.fail(); err != nil {
+		return err
+	}
+	return self.TMemoryCache.Set(block)
+}
+
+func (self *flaky) Incr(key string) error {
+	if err := self.fail(); err != nil {
+		return err
+	}
+	return self.TMemoryCache.Incr(key)
+}
+
+// redisErr looks like a reply of a redis server.
+type redisErr string
+
+func (self redisErr) Error() string { return string(self) }
+func (self redisErr) RedisError()   {}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestConformance(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	cachertest.Run(t, func(t *testing.T) cacher.ICacher {
+		return New(memory.New(cacher.WithClock(clock)))
+	}, cachertest.WithSleep(clock.Advance))
+}
+
+func TestRetryable(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{io.EOF, true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{timeoutErr{}, true},
+		{redisErr("MOVED 3999 127.0.0.1:6381"), true},
+		{redisErr("TRYAGAIN Multiple keys request during rehashing of slot"), true},
+		{redisErr("WRONGTYPE Operation against a key holding the wrong kind of value"), false},
+		{errors.New("MOVED but not from redis"), false},
+		{cacher.ErrCacheMiss, false},
+		{context.Canceled, false},
+	} {
+		if got := Retryable(c.err); got != c.want {
+			t.Errorf("retryable %v is %v", c.err, got)
+		}
+	}
+}
+
+func TestAttempts(t *testing.T) {
+	backend := &flaky{TMemoryCache: memory.New(), err: io.EOF}
+	chr := New(backend, WithMaxAttempts(3), WithBackoff(0, 0, 0))
+
+	backend.fails = 2
+	if err := chr.Set(&cacher.CacheBlock{Key: "key", Value: "value"}); err != nil || backend.calls != 3 {
+		t.Fatalf("set %v after %d calls", err, backend.calls)
+	}
+
+	backend.fails, backend.calls = 3, 0
+	if _, err := chr.Get("key"); err != io.EOF || backend.calls != 3 {
+		t.Fatalf("get %v after %d calls", err, backend.calls)
+	}
+
+	// 最终错误不重试
+	backend.fails, backend.calls, backend.err = 1, 0, errors.New("bad value")
+	if _, err := chr.Get("key"); err == nil || backend.calls != 1 {
+		t.Fatalf("get %v after %d calls", err, backend.calls)
+	}
+}
+
+func TestCounters(t *testing.T) {
+	backend := &flaky{TMemoryCache: memory.New(), err: io.EOF}
+	chr := New(backend, WithBackoff(0, 0, 0))
+	chr.Set(&cacher.CacheBlock{Key: "n", Value: 1})
+
+	backend.fails, backend.calls = 1, 0
+	if err := chr.Incr("n"); err != io.EOF || backend.calls != 1 {
+		t.Fatalf("incr %v after %d calls", err, backend.calls)
+	}
+
+	chr = New(backend, WithBackoff(0, 0, 0), WithRetryCounters(true))
+	backend.fails, backend.calls = 1, 0
+	if err := chr.Incr("n"); err != nil || backend.calls != 2 {
+		t.Fatalf("incr %v after %d calls", err, backend.calls)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	backend := &flaky{TMemoryCache: memory.New(), err: io.EOF, fails: 100}
+	chr := New(backend, WithMaxAttempts(100), WithBackoff(20*time.Millisecond, 0, 0), WithTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	if _, err := chr.Get("key"); err != io.EOF {
+		t.Fatalf("get %v", err)
+	}
+	if d := time.Since(start); d > time.Second || backend.calls > 4 {
+		t.Fatalf("%d calls in %v", backend.calls, d)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	chr := New(memory.New(), WithBackoff(10*time.Millisecond, 50*time.Millisecond, 0.5))
+	for attempt, max := range []time.Duration{10, 20, 40, 50, 50} {
+		max *= time.Millisecond
+		for i := 0; i < 100; i++ {
+			if d := chr.delay(attempt); d < max/2 || d > max {
+				t.Fatalf("delay %v after attempt %d", d, attempt)
+			}
+		}
+	}
+
+	if d := chr.delay(100); d > 50*time.Millisecond || d <= 0 {
+		t.Fatalf("delay %v after overflow", d)
+	}
+}
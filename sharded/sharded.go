@@ -0,0 +1,357 @@
+// Package sharded partitions the keys over several cachers by a consistent hash ring,
+// e.g. independent redis nodes or memory caches in a client side cluster.
+//
+//	chr := sharded.New()
+//	chr.AddNode("a", redisA, 1)
+//	chr.AddNode("b", redisB, 2) // owns twice as many keys
+//
+// adding or removing a node only remaps about its share of the keys,
+// the keys of a removed node are not migrated and simply miss.
+// Keys,Len and Clear fan out to every node.
+package sharded
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/internal/hashring"
+)
+
+var errNoNodes = errors.New("cache: sharded has no nodes")
+
+var Sharded = cacher.Register("Sharded", func() cacher.ICacher {
+	return New()
+})
+
+type (
+	Config struct {
+		cacher.Config
+		Active   bool
+		Replicas int // points of a node of weight 1 on the ring
+	}
+
+	ShardedCache struct {
+		sync.RWMutex
+		config *Config
+		ring   *hashring.Ring
+		nodes  map[string]cacher.ICacher
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+// WithReplicas sets the points of a node of weight 1 on the ring.
+func WithReplicas(n int) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("replicas", n)
+	}
+}
+
+func New(opts ...cacher.Option) *ShardedCache {
+	cfg := &Config{
+		Active:   true,
+		Replicas: hashring.DefaultReplicas,
+	}
+	cfg.Init(opts...)
+
+	return &ShardedCache{
+		config: cfg,
+		ring:   hashring.New(cfg.Replicas),
+		nodes:  make(map[string]cacher.ICacher),
+	}
+}
+
+// Init rebuilds the ring by the replicas of the config,the nodes keep their weights.
+func (self *ShardedCache) Init(opts ...cacher.Option) {
+	self.Lock()
+	defer self.Unlock()
+
+	self.config.Init(opts...)
+	ring := hashring.New(self.config.Replicas)
+	for _, name := range self.ring.Nodes() {
+		ring.Add(name, self.ring.Weight(name))
+	}
+	self.ring = ring
+}
+
+func (self *ShardedCache) String() string {
+	return "sharded"
+}
+
+func (self *ShardedCache) Active(on ...bool) bool {
+	if len(on) > 0 {
+		self.config.Active = on[0]
+	}
+
+	return self.config.Active
+}
+
+// AddNode puts the cacher on the ring by the name,adding an existing name
+// replaces its cacher and weight. a node of weight 2 owns twice the keys of weight 1.
+func (self *ShardedCache) AddNode(name string, chr cacher.ICacher, weight int) {
+	self.Lock()
+	defer self.Unlock()
+
+	self.nodes[name] = chr
+	self.ring.Add(name, weight)
+}
+
+// RemoveNode takes the node off the ring and returns its cacher,which is not closed.
+func (self *ShardedCache) RemoveNode(name string) cacher.ICacher {
+	self.Lock()
+	defer self.Unlock()
+
+	chr := self.nodes[name]
+	delete(self.nodes, name)
+	self.ring.Remove(name)
+	return chr
+}
+
+// Node returns the name of the node owning the key.
+func (self *ShardedCache) Node(key string) (string, bool) {
+	self.RLock()
+	defer self.RUnlock()
+	return self.ring.Get(key)
+}
+
+// pick returns the cacher owning the key.
+func (self *ShardedCache) pick(key string) (cacher.ICacher, error) {
+	self.RLock()
+	defer self.RUnlock()
+
+	name, ok := self.ring.Get(key)
+	if !ok {
+		return nil, errNoNodes
+	}
+	return self.nodes[name], nil
+}
+
+// all returns the cachers in order of their names.
+func (self *ShardedCache) all() []cacher.ICacher {
+	self.RLock()
+	defer self.RUnlock()
+
+	names := self.ring.Nodes()
+	nodes := make([]cacher.ICacher, len(names))
+	for i, name := range names {
+		nodes[i] = self.nodes[name]
+	}
+	return nodes
+}
+
+// group splits the keys by their cachers,the values are the indexes of the keys.
+func (self *ShardedCache) group(keys []string) (map[cacher.ICacher][]int, error) {
+	self.RLock()
+	defer self.RUnlock()
+
+	groups := make(map[cacher.ICacher][]int)
+	for i, key := range keys {
+		name, ok := self.ring.Get(key)
+		if !ok {
+			return nil, errNoNodes
+		}
+		chr := self.nodes[name]
+		groups[chr] = append(groups[chr], i)
+	}
+	return groups, nil
+}
+
+func (self *ShardedCache) Get(key string, ctx ...context.Context) (any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	chr, err := self.pick(key)
+	if err != nil {
+		return nil, err
+	}
+	return chr.Get(key, ctx...)
+}
+
+func (self *ShardedCache) Set(block *cacher.CacheBlock) error {
+	if !self.config.Active {
+		return nil
+	}
+
+	chr, err := self.pick(block.Key)
+	if err != nil {
+		return err
+	}
+	return chr.Set(block)
+}
+
+func (self *ShardedCache) Exists(key string, ctx ...context.Context) bool {
+	if !self.config.Active {
+		return false
+	}
+
+	chr, err := self.pick(key)
+	return err == nil && chr.Exists(key, ctx...)
+}
+
+func (self *ShardedCache) Delete(key string, ctx ...context.Context) error {
+	chr, err := self.pick(key)
+	if err != nil {
+		return err
+	}
+	return chr.Delete(key, ctx...)
+}
+
+// Keys returns the keys of all nodes.
+func (self *ShardedCache) Keys(ctx ...context.Context) []string {
+	if !self.config.Active {
+		return nil
+	}
+
+	var keys []string
+	for _, chr := range self.all() {
+		keys = append(keys, chr.Keys(ctx...)...)
+	}
+	return keys
+}
+
+func (self *ShardedCache) Len() int {
+	n := 0
+	for _, chr := range self.all() {
+		n += chr.Len()
+	}
+	return n
+}
+
+// Clear clears every node,the errors of all nodes are returned.
+func (self *ShardedCache) Clear() error {
+	var errs []error
+	for _, chr := range self.all() {
+		if err := chr.Clear(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", chr, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every node.
+func (self *ShardedCache) Close() error {
+	var errs []error
+	for _, chr := range self.all() {
+		if err := chr.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", chr, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GetMulti reads the keys of every node in one batch if the node supports it,
+// missing keys are omitted.
+func (self *ShardedCache) GetMulti(keys []string, ctx ...context.Context) (map[string]any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	groups, err := self.group(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]any, len(keys))
+	for chr, idx := range groups {
+		sub := make([]string, len(idx))
+		for i, j := range idx {
+			sub[i] = keys[j]
+		}
+
+		if batcher, ok := chr.(cacher.IBatcher); ok {
+			got, err := batcher.GetMulti(sub, ctx...)
+			if err != nil {
+				return nil, err
+			}
+			for key, value := range got {
+				values[key] = value
+			}
+			continue
+		}
+
+		for _, key := range sub {
+			value, err := chr.Get(key, ctx...)
+			if errors.Is(err, cacher.ErrCacheMiss) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// SetMulti writes the blocks of every node in one batch if the node supports it.
+func (self *ShardedCache) SetMulti(blocks ...*cacher.CacheBlock) error {
+	if !self.config.Active {
+		return nil
+	}
+
+	keys := make([]string, len(blocks))
+	for i, block := range blocks {
+		keys[i] = block.Key
+	}
+
+	groups, err := self.group(keys)
+	if err != nil {
+		return err
+	}
+
+	for chr, idx := range groups {
+		sub := make([]*cacher.CacheBlock, len(idx))
+		for i, j := range idx {
+			sub[i] = blocks[j]
+		}
+
+		if batcher, ok := chr.(cacher.IBatcher); ok {
+			if err := batcher.SetMulti(sub...); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, block := range sub {
+			if err := chr.Set(block); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteMulti deletes the keys of every node in one batch if the node supports it.
+func (self *ShardedCache) DeleteMulti(keys []string, ctx ...context.Context) error {
+	groups, err := self.group(keys)
+	if err != nil {
+		return err
+	}
+
+	for chr, idx := range groups {
+		sub := make([]string, len(idx))
+		for i, j := range idx {
+			sub[i] = keys[j]
+		}
+
+		if batcher, ok := chr.(cacher.IBatcher); ok {
+			if err := batcher.DeleteMulti(sub, ctx...); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, key := range sub {
+			if err := chr.Delete(key, ctx...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
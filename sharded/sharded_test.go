@@ -0,0 +1,150 @@
+package sharded
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/cachertest"
+	"github.com/volts-dev/cacher/internal/hashring"
+	"github.com/volts-dev/cacher/memory"
+)
+
+func cluster(clock cacher.Clock, weights ...int) (*ShardedCache, []*memory.TMemoryCache) {
+	chr := New()
+	nodes := make([]*memory.TMemoryCache, len(weights))
+	for i, w := range weights {
+		nodes[i] = memory.New(cacher.WithClock(clock))
+		chr.AddNode(fmt.Sprintf("node%d", i), nodes[i], w)
+	}
+	return chr, nodes
+}
+
+func TestConformance(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	cachertest.Run(t, func(t *testing.T) cacher.ICacher {
+		chr, _ := cluster(clock, 1, 1, 1)
+		return chr
+	}, cachertest.WithSleep(clock.Advance))
+}
+
+func TestWeights(t *testing.T) {
+	chr, nodes := cluster(cacher.SystemClock, 1, 3)
+	defer chr.Close()
+
+	const n = 4000
+	for i := 0; i < n; i++ {
+		chr.Set(&cacher.CacheBlock{Key: fmt.Sprintf("key%d", i), Value: i})
+	}
+
+	// 权重3的节点约占3/4
+	if heavy := nodes[1].Len(); heavy < n*2/3 || heavy > n*5/6 {
+		t.Fatalf("node of weight 3 owns %d of %d keys", heavy, n)
+	}
+	if chr.Len() != n {
+		t.Fatalf("len %d", chr.Len())
+	}
+}
+
+func TestRemap(t *testing.T) {
+	chr, _ := cluster(cacher.SystemClock, 1, 1, 1)
+	defer chr.Close()
+
+	const n = 10000
+	owners := make([]string, n)
+	for i := range owners {
+		owners[i], _ = chr.Node(fmt.Sprintf("key%d", i))
+	}
+
+	// 增加节点只移动约1/4的键,且都移到新节点
+	chr.AddNode("node3", memory.New(), 1)
+	moved := 0
+	for i, owner := range owners {
+		if node, _ := chr.Node(fmt.Sprintf("key%d", i)); node != owner {
+			if node != "node3" {
+				t.Fatalf("key%d moved from %s to %s", i, owner, node)
+			}
+			moved++
+		}
+	}
+	if moved < n/8 || moved > n*3/8 {
+		t.Fatalf("%d of %d keys moved", moved, n)
+	}
+
+	// 删除后回到原来的节点
+	chr.RemoveNode("node3").Close()
+	for i, owner := range owners {
+		if node, _ := chr.Node(fmt.Sprintf("key%d", i)); node != owner {
+			t.Fatalf("key%d is on %s after remove", i, node)
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	chr, nodes := cluster(cacher.SystemClock, 1, 1)
+	defer chr.Close()
+
+	blocks := make([]*cacher.CacheBlock, 20)
+	keys := make([]string, len(blocks))
+	for i := range blocks {
+		keys[i] = fmt.Sprintf("key%d", i)
+		blocks[i] = &cacher.CacheBlock{Key: keys[i], Value: i}
+	}
+
+	if err := chr.SetMulti(blocks...); err != nil {
+		t.Fatal(err)
+	}
+	if nodes[0].Len() == 0 || nodes[1].Len() == 0 {
+		t.Fatal("blocks are not spread")
+	}
+
+	values, err := chr.GetMulti(append(keys, "missing"))
+	if err != nil || len(values) != len(keys) || values["key7"] != 7 {
+		t.Fatalf("get multi %v %v", values, err)
+	}
+
+	if err := chr.DeleteMulti(keys[:10]); err != nil {
+		t.Fatal(err)
+	}
+	if chr.Len() != 10 {
+		t.Fatalf("len %d after delete multi", chr.Len())
+	}
+}
+
+func TestNoNodes(t *testing.T) {
+	chr, err := cacher.New("sharded")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := chr.Get("key"); err != errNoNodes {
+		t.Fatalf("get %v", err)
+	}
+	if chr.Len() != 0 || chr.Clear() != nil {
+		t.Fatal("empty sharded")
+	}
+}
+
+func TestInitReplicas(t *testing.T) {
+	c, err := cacher.New("sharded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chr := c.(*ShardedCache)
+	chr.AddNode("node0", memory.New(), 1)
+	chr.AddNode("node1", memory.New(), 2)
+
+	// 注册表创建后再设置的replicas要重建哈希环
+	chr.Init(WithReplicas(8))
+	want := hashring.New(8)
+	want.Add("node0", 1)
+	want.Add("node1", 2)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		got, _ := chr.Node(key)
+		if node, _ := want.Get(key); got != node {
+			t.Fatalf("%s is on %s,expected %s", key, got, node)
+		}
+	}
+}
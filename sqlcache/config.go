@@ -0,0 +1,84 @@
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/volts-dev/cacher"
+)
+
+type (
+	MarshalFunc   func(interface{}) ([]byte, error)
+	UnmarshalFunc func([]byte, interface{}) error
+
+	// querier is the part of *sql.DB used by the cacher,*sql.DB and *sql.Tx both implement it.
+	querier interface {
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+		QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+		QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	}
+
+	Option func(*Config)
+
+	Config struct {
+		cacher.Config
+		Active     bool
+		DB         querier       `field:"db"` // must be exported to be set by WithDB
+		Dialect    string        // one of Postgres,MySQL and SQLite
+		Table      string        // created by New if it does not exist
+		Interval   time.Duration // interval of the expiry sweeps,0 disables them
+		SweepBatch int           `field:"sweep_batch"` // rows deleted per statement by a sweep
+		Marshal    MarshalFunc
+		Unmarshal  UnmarshalFunc
+		Logger     cacher.Logger
+		Clock      cacher.Clock
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+// WithDB sets the database,it is not closed by the cacher.
+func WithDB(db querier) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("db", db)
+	}
+}
+
+// WithDialect sets the SQL dialect of the database.
+func WithDialect(dialect string) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("dialect", dialect)
+	}
+}
+
+// WithTable sets the table of the caches.
+func WithTable(table string) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("table", table)
+	}
+}
+
+// WithInterval sets how often the expired rows are swept.
+func WithInterval(interval time.Duration) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("interval", interval)
+	}
+}
+
+// WithSweepBatch limits the rows deleted per statement by a sweep.
+func WithSweepBatch(n int) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("sweep_batch", n)
+	}
+}
+
+// WithCodec sets how the values are encoded.
+func WithCodec(marshal MarshalFunc, unmarshal UnmarshalFunc) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("marshal", marshal)
+		cfg.SetByField("unmarshal", unmarshal)
+	}
+}
@@ -0,0 +1,142 @@
+package sqlcache
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	Postgres = "postgres"
+	MySQL    = "mysql"
+	SQLite   = "sqlite"
+)
+
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+type (
+	// dialect is the SQL which differs between the databases.
+	dialect struct {
+		key         string // type of the key column,compared byte by byte
+		blob        string // type of the value column
+		indexInline bool   // the index is declared in CREATE TABLE
+		insertNew   string // insert a row unless the key exists
+		upsert      string // insert or replace a row
+		sweep       string // delete up to a batch of expired rows
+		numbered    bool   // placeholders are $1,$2...
+	}
+
+	// queries are the statements of a table,every "?" is an argument.
+	// expire_at is in unix nanoseconds and 0 means never expire.
+	queries struct {
+		create        []string
+		get           string
+		ttl           string
+		insertNew     string
+		upsert        string
+		update        string
+		expire        string
+		deleteExpired string
+		delete        string
+		keys          string
+		count         string
+		clear         string
+		sweep         string
+	}
+)
+
+var dialects = map[string]*dialect{
+	Postgres: {
+		key:       "VARCHAR(250)",
+		blob:      "BYTEA",
+		insertNew: "INSERT INTO %s (cache_key, cache_value, expire_at) VALUES (?, ?, ?) ON CONFLICT (cache_key) DO NOTHING",
+		upsert: "INSERT INTO %s (cache_key, cache_value, expire_at) VALUES (?, ?, ?) " +
+			"ON CONFLICT (cache_key) DO UPDATE SET cache_value = EXCLUDED.cache_value, expire_at = EXCLUDED.expire_at",
+		sweep:    "DELETE FROM %[1]s WHERE cache_key IN (SELECT cache_key FROM %[1]s WHERE expire_at > 0 AND expire_at <= ? LIMIT ?)",
+		numbered: true,
+	},
+	MySQL: {
+		// VARCHAR 的默认排序规则忽略大小写和尾部空格,"a"和"A "会是同一个键
+		key:         "VARBINARY(250)",
+		blob:        "LONGBLOB",
+		indexInline: true,
+		insertNew:   "INSERT IGNORE INTO %s (cache_key, cache_value, expire_at) VALUES (?, ?, ?)",
+		upsert: "INSERT INTO %s (cache_key, cache_value, expire_at) VALUES (?, ?, ?) " +
+			"ON DUPLICATE KEY UPDATE cache_value = VALUES(cache_value), expire_at = VALUES(expire_at)",
+		// MySQL 不支持子查询中的LIMIT
+		sweep: "DELETE FROM %s WHERE expire_at > 0 AND expire_at <= ? ORDER BY expire_at LIMIT ?",
+	},
+	SQLite: {
+		key:       "VARCHAR(250)",
+		blob:      "BLOB",
+		insertNew: "INSERT INTO %s (cache_key, cache_value, expire_at) VALUES (?, ?, ?) ON CONFLICT (cache_key) DO NOTHING",
+		upsert: "INSERT INTO %s (cache_key, cache_value, expire_at) VALUES (?, ?, ?) " +
+			"ON CONFLICT (cache_key) DO UPDATE SET cache_value = excluded.cache_value, expire_at = excluded.expire_at",
+		sweep: "DELETE FROM %[1]s WHERE cache_key IN (SELECT cache_key FROM %[1]s WHERE expire_at > 0 AND expire_at <= ? LIMIT ?)",
+	},
+}
+
+// newQueries returns the statements of the table in the dialect.
+func newQueries(name, table string) (*queries, error) {
+	d, ok := dialects[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("cache: unknown sql dialect %q", name)
+	}
+
+	if !tableName.MatchString(table) {
+		return nil, fmt.Errorf("cache: invalid table name %q", table)
+	}
+
+	const alive = "(expire_at = 0 OR expire_at > ?)"
+	index := strings.ReplaceAll(table, ".", "_") + "_expire_at"
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (cache_key %s NOT NULL PRIMARY KEY, cache_value %s NOT NULL, expire_at BIGINT NOT NULL DEFAULT 0", table, d.key, d.blob)
+
+	q := &queries{
+		get:           fmt.Sprintf("SELECT cache_value FROM %s WHERE cache_key = ? AND %s", table, alive),
+		ttl:           fmt.Sprintf("SELECT expire_at FROM %s WHERE cache_key = ? AND %s", table, alive),
+		insertNew:     fmt.Sprintf(d.insertNew, table),
+		upsert:        fmt.Sprintf(d.upsert, table),
+		update:        fmt.Sprintf("UPDATE %s SET cache_value = ?, expire_at = ? WHERE cache_key = ? AND %s", table, alive),
+		expire:        fmt.Sprintf("UPDATE %s SET expire_at = ? WHERE cache_key = ? AND %s", table, alive),
+		deleteExpired: fmt.Sprintf("DELETE FROM %s WHERE cache_key = ? AND expire_at > 0 AND expire_at <= ?", table),
+		delete:        fmt.Sprintf("DELETE FROM %s WHERE cache_key = ?", table),
+		keys:          fmt.Sprintf("SELECT cache_key FROM %s WHERE %s", table, alive),
+		count:         fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", table, alive),
+		clear:         fmt.Sprintf("DELETE FROM %s", table),
+		sweep:         fmt.Sprintf(d.sweep, table),
+	}
+
+	if d.indexInline {
+		q.create = []string{create + fmt.Sprintf(", INDEX %s (expire_at))", index)}
+	} else {
+		q.create = []string{create + ")", fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (expire_at)", index, table)}
+	}
+
+	if d.numbered {
+		q.rebind()
+	}
+	return q, nil
+}
+
+// rebind numbers the placeholders like $1,$2... for postgres.
+func (self *queries) rebind() {
+	number := func(query string) string {
+		var b strings.Builder
+		n := 0
+		for _, r := range query {
+			if r == '?' {
+				n++
+				b.WriteString("$" + strconv.Itoa(n))
+				continue
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+
+	for _, p := range []*string{&self.get, &self.ttl, &self.insertNew, &self.upsert, &self.update,
+		&self.expire, &self.deleteExpired, &self.delete, &self.keys, &self.count, &self.sweep} {
+		*p = number(*p)
+	}
+}
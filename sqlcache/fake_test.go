@@ -0,0 +1,233 @@
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+type (
+	// fakeDB emulates the statements of a table in memory,
+	// an unknown statement fails so the cacher can only send what the dialect defines.
+	fakeDB struct {
+		sync.Mutex
+		q     *queries
+		rows  map[string]fakeRow
+		execs map[string]int // count of every statement
+	}
+
+	fakeRow struct {
+		value    []byte
+		expireAt int64
+	}
+
+	fakeConnector struct{ db *fakeDB }
+	fakeDriver    struct{ db *fakeDB }
+	fakeConn      struct{ db *fakeDB }
+
+	fakeRows struct {
+		columns []string
+		values  [][]driver.Value
+	}
+)
+
+// openFake returns a database which understands the queries of the dialect.
+func openFake(dialect, table string) (*sql.DB, *fakeDB, error) {
+	q, err := newQueries(dialect, table)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db := &fakeDB{q: q, rows: make(map[string]fakeRow), execs: make(map[string]int)}
+	return sql.OpenDB(&fakeConnector{db: db}), db, nil
+}
+
+func (self *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{db: self.db}, nil
+}
+
+func (self *fakeConnector) Driver() driver.Driver {
+	return &fakeDriver{db: self.db}
+}
+
+func (self *fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{db: self.db}, nil
+}
+
+func (self *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("fake: prepare is not supported")
+}
+
+func (self *fakeConn) Close() error {
+	return nil
+}
+
+func (self *fakeConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("fake: transactions are not supported")
+}
+
+func (self *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	n, _, err := self.db.run(query, values(args))
+	return driver.RowsAffected(n), err
+}
+
+func (self *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	_, rows, err := self.db.run(query, values(args))
+	if err == nil && rows == nil {
+		err = fmt.Errorf("fake: %q returns no rows", query)
+	}
+	return rows, err
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	v := make([]driver.Value, len(args))
+	for i, arg := range args {
+		v[i] = arg.Value
+	}
+	return v
+}
+
+func alive(row fakeRow, now int64) bool {
+	return row.expireAt == 0 || row.expireAt > now
+}
+
+// run executes the statement and returns the affected rows or the result set.
+func (self *fakeDB) run(query string, args []driver.Value) (int64, *fakeRows, error) {
+	self.Lock()
+	defer self.Unlock()
+
+	self.execs[query]++
+	q := self.q
+	switch query {
+	case q.get, q.ttl:
+		key, now := args[0].(string), args[1].(int64)
+		rows := &fakeRows{columns: []string{"v"}}
+		if row, has := self.rows[key]; has && alive(row, now) {
+			if query == q.get {
+				rows.values = append(rows.values, []driver.Value{row.value})
+			} else {
+				rows.values = append(rows.values, []driver.Value{row.expireAt})
+			}
+		}
+		return 0, rows, nil
+
+	case q.insertNew:
+		key := args[0].(string)
+		if _, has := self.rows[key]; has {
+			return 0, nil, nil
+		}
+		self.rows[key] = fakeRow{value: args[1].([]byte), expireAt: args[2].(int64)}
+		return 1, nil, nil
+
+	case q.upsert:
+		self.rows[args[0].(string)] = fakeRow{value: args[1].([]byte), expireAt: args[2].(int64)}
+		return 1, nil, nil
+
+	case q.update:
+		key, now := args[2].(string), args[3].(int64)
+		if row, has := self.rows[key]; !has || !alive(row, now) {
+			return 0, nil, nil
+		}
+		self.rows[key] = fakeRow{value: args[0].([]byte), expireAt: args[1].(int64)}
+		return 1, nil, nil
+
+	case q.expire:
+		key, now := args[1].(string), args[2].(int64)
+		row, has := self.rows[key]
+		if !has || !alive(row, now) {
+			return 0, nil, nil
+		}
+		row.expireAt = args[0].(int64)
+		self.rows[key] = row
+		return 1, nil, nil
+
+	case q.deleteExpired:
+		key, now := args[0].(string), args[1].(int64)
+		if row, has := self.rows[key]; has && !alive(row, now) {
+			delete(self.rows, key)
+			return 1, nil, nil
+		}
+		return 0, nil, nil
+
+	case q.delete:
+		key := args[0].(string)
+		if _, has := self.rows[key]; !has {
+			return 0, nil, nil
+		}
+		delete(self.rows, key)
+		return 1, nil, nil
+
+	case q.keys, q.count:
+		now := args[0].(int64)
+		var keys []string
+		for key, row := range self.rows {
+			if alive(row, now) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		rows := &fakeRows{columns: []string{"k"}}
+		if query == q.count {
+			rows.values = append(rows.values, []driver.Value{int64(len(keys))})
+			return 0, rows, nil
+		}
+		for _, key := range keys {
+			rows.values = append(rows.values, []driver.Value{key})
+		}
+		return 0, rows, nil
+
+	case q.clear:
+		n := len(self.rows)
+		self.rows = make(map[string]fakeRow)
+		return int64(n), nil, nil
+
+	case q.sweep:
+		now, limit := args[0].(int64), args[1].(int64)
+		var n int64
+		for key, row := range self.rows {
+			if n == limit {
+				break
+			}
+			if !alive(row, now) {
+				delete(self.rows, key)
+				n++
+			}
+		}
+		return n, nil, nil
+	}
+
+	for _, create := range q.create {
+		if query == create {
+			return 0, nil, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("fake: unknown statement %q", query)
+}
+
+func (self *fakeDB) count(query string) int {
+	self.Lock()
+	defer self.Unlock()
+	return self.execs[query]
+}
+
+func (self *fakeRows) Columns() []string {
+	return self.columns
+}
+
+func (self *fakeRows) Close() error {
+	return nil
+}
+
+func (self *fakeRows) Next(dest []driver.Value) error {
+	if len(self.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, self.values[0])
+	self.values = self.values[1:]
+	return nil
+}
@@ -0,0 +1,408 @@
+// Package sqlcache keeps the caches in a table of an existing database through database/sql,
+// for tools which already have Postgres,MySQL or SQLite but no redis.
+//
+// the table has the key,the encoded value and the expiry in unix nanoseconds which is indexed,
+// the expired rows are hidden by the queries and deleted in batches by a sweep.
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/internal/codec"
+)
+
+const defaultSweepBatch = 1000
+
+var (
+	errNoDB   = errors.New("cache: sql database is not set")
+	errClosed = errors.New("cache: sql cacher is closed")
+)
+
+var SQL = cacher.Register("SQL", func() cacher.ICacher {
+	return New()
+})
+
+type SQLCache struct {
+	sync.RWMutex
+	config *Config
+	q      *queries
+	err    error         // of creating the table
+	exit   chan struct{} // closed to stop the sweeps of the current table
+	closed bool
+}
+
+// New creates the table if it does not exist,an error is returned by the operations.
+func New(opts ...cacher.Option) *SQLCache {
+	cfg := &Config{
+		Active:     true,
+		Dialect:    Postgres,
+		Table:      "cacher",
+		Interval:   cacher.INTERVAL_TIME * time.Second,
+		SweepBatch: defaultSweepBatch,
+	}
+	cfg.Init(opts...)
+
+	if cfg.Logger == nil {
+		cfg.Logger = cacher.DefaultLogger()
+	}
+
+	if cfg.Clock == nil {
+		cfg.Clock = cacher.SystemClock
+	}
+
+	if cfg.Marshal == nil {
+		cfg.Marshal = codec.Marshal
+	}
+
+	if cfg.Unmarshal == nil {
+		cfg.Unmarshal = codec.Unmarshal
+	}
+
+	c := &SQLCache{config: cfg}
+	c.open()
+	return c
+}
+
+// open builds the queries and creates the table from the config,
+// the sweeps of the previous table are stopped. the lock must be held or not shared yet.
+func (self *SQLCache) open() {
+	if self.exit != nil {
+		close(self.exit)
+		self.exit = nil
+	}
+
+	cfg := self.config
+	self.q, self.err = newQueries(cfg.Dialect, cfg.Table)
+	if self.err == nil && cfg.DB == nil {
+		self.err = errNoDB
+	}
+
+	if self.err == nil {
+		for _, query := range self.q.create {
+			if _, self.err = cfg.DB.ExecContext(context.Background(), query); self.err != nil {
+				break
+			}
+		}
+	}
+
+	if self.err != nil {
+		cfg.Logger.Error("cache: open sql table failed", "table", cfg.Table, "error", self.err)
+		return
+	}
+
+	if cfg.Interval > 0 {
+		self.exit = make(chan struct{})
+		go self.vaccuum(self.exit, cfg.Clock, cfg.Interval)
+	}
+}
+
+// Init applies the options and reopens the table,
+// so a cacher created by cacher.New("sql") works once WithDB is given.
+func (self *SQLCache) Init(opts ...cacher.Option) {
+	self.Lock()
+	defer self.Unlock()
+
+	self.config.Init(opts...)
+	if !self.closed {
+		self.open()
+	}
+}
+
+func (self *SQLCache) String() string {
+	return "sql"
+}
+
+func (self *SQLCache) Active(on ...bool) bool {
+	if len(on) > 0 {
+		self.config.Active = on[0]
+	}
+
+	return self.config.Active
+}
+
+// check returns the database and the queries of the current table.
+func (self *SQLCache) check(ctx context.Context) (querier, *queries, error) {
+	self.RLock()
+	db, q, err := self.config.DB, self.q, self.err
+	self.RUnlock()
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, q, ctx.Err()
+}
+
+// now returns the current time in unix nanoseconds like the expire_at column.
+func (self *SQLCache) now() int64 {
+	return self.config.Clock.Now().UnixNano()
+}
+
+func (self *SQLCache) Get(key string, ctx ...context.Context) (any, error) {
+	if !self.config.Active {
+		return nil, cacher.ErrInactive
+	}
+
+	c := cacher.Context(ctx...)
+	db, q, err := self.check(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	err = db.QueryRowContext(c, q.get, key, self.now()).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, cacher.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	if err := self.config.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Set upserts the row,SetOnlyNew replaces only an expired row and SetOnlyExist updates only an alive one.
+func (self *SQLCache) Set(block *cacher.CacheBlock) error {
+	if !self.config.Active {
+		return nil
+	}
+
+	ctx := block.Context()
+	db, q, err := self.check(ctx)
+	if err != nil {
+		return err
+	}
+
+	value, err := self.config.Marshal(block.Value)
+	if err != nil {
+		return err
+	}
+
+	now := self.now()
+	var expireAt int64
+	if ttl := block.Ttl(); ttl > 0 {
+		expireAt = now + int64(ttl)
+	}
+
+	switch {
+	case block.SetOnlyNew:
+		// 过期的行仍占着主键
+		if _, err = db.ExecContext(ctx, q.deleteExpired, block.Key, now); err == nil {
+			_, err = db.ExecContext(ctx, q.insertNew, block.Key, value, expireAt)
+		}
+	case block.SetOnlyExist:
+		_, err = db.ExecContext(ctx, q.update, value, expireAt, block.Key, now)
+	default:
+		_, err = db.ExecContext(ctx, q.upsert, block.Key, value, expireAt)
+	}
+
+	if err != nil {
+		self.config.Logger.Error("cache: sql set failed", "key", block.Key, "error", err)
+	}
+	return err
+}
+
+func (self *SQLCache) Exists(key string, ctx ...context.Context) bool {
+	_, err := self.Get(key, ctx...)
+	return err == nil
+}
+
+// Delete removes the row,deleting a missing key is not an error.
+func (self *SQLCache) Delete(key string, ctx ...context.Context) error {
+	c := cacher.Context(ctx...)
+	db, q, err := self.check(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(c, q.delete, key)
+	return err
+}
+
+func (self *SQLCache) Keys(ctx ...context.Context) []string {
+	if !self.config.Active {
+		return nil
+	}
+
+	c := cacher.Context(ctx...)
+	db, q, err := self.check(c)
+	if err != nil {
+		return nil
+	}
+
+	rows, err := db.QueryContext(c, q.keys, self.now())
+	if err != nil {
+		self.config.Logger.Error("cache: sql keys failed", "error", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			self.config.Logger.Error("cache: sql keys failed", "error", err)
+			return keys
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Len counts the alive rows.
+func (self *SQLCache) Len() int {
+	ctx := context.Background()
+	db, q, err := self.check(ctx)
+	if err != nil {
+		return 0
+	}
+
+	var n int
+	if err := db.QueryRowContext(ctx, q.count, self.now()).Scan(&n); err != nil {
+		self.config.Logger.Error("cache: sql count failed", "error", err)
+	}
+	return n
+}
+
+// Clear deletes all rows of the table.
+func (self *SQLCache) Clear() error {
+	ctx := context.Background()
+	db, q, err := self.check(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, q.clear)
+	return err
+}
+
+// TTL returns the remaining time of the key,-1 means never expire.
+func (self *SQLCache) TTL(key string, ctx ...context.Context) (time.Duration, error) {
+	c := cacher.Context(ctx...)
+	db, q, err := self.check(c)
+	if err != nil {
+		return 0, err
+	}
+
+	now := self.now()
+	var expireAt int64
+	err = db.QueryRowContext(c, q.ttl, key, now).Scan(&expireAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, cacher.ErrCacheMiss
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if expireAt == 0 {
+		return -1, nil
+	}
+	return time.Duration(expireAt - now), nil
+}
+
+// Expire resets the TTL of the key,a negative TTL means never expire.
+func (self *SQLCache) Expire(key string, ttl time.Duration, ctx ...context.Context) error {
+	c := cacher.Context(ctx...)
+	db, q, err := self.check(c)
+	if err != nil {
+		return err
+	}
+
+	now := self.now()
+	var expireAt int64
+	if ttl >= 0 {
+		expireAt = now + int64(ttl)
+	}
+
+	res, err := db.ExecContext(c, q.expire, expireAt, key, now)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return cacher.ErrCacheMiss
+	}
+	return nil
+}
+
+// vaccuum sweeps the expired rows every interval until exit is closed by Init or Close,
+// the clock and the interval are passed in since Init rewrites the config.
+func (self *SQLCache) vaccuum(exit chan struct{}, clock cacher.Clock, interval time.Duration) {
+	for {
+		select {
+		case <-clock.After(interval):
+		case <-exit:
+			return
+		}
+
+		if !self.config.Active {
+			continue
+		}
+
+		start := time.Now()
+		n, err := self.sweep(context.Background())
+		if err != nil {
+			self.config.Logger.Error("cache: sql sweep failed", "error", err)
+		}
+		self.config.Logger.Debug("cache: sql sweep", "expired", n, "duration", time.Since(start))
+	}
+}
+
+// sweep deletes the expired rows by the index in batches,so the table is not locked for long.
+func (self *SQLCache) sweep(ctx context.Context) (int64, error) {
+	db, q, err := self.check(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	batch := self.config.SweepBatch
+	if batch <= 0 {
+		batch = defaultSweepBatch
+	}
+
+	var total int64
+	for {
+		res, err := db.ExecContext(ctx, q.sweep, self.now(), batch)
+		if err != nil {
+			return total, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		if n < int64(batch) {
+			return total, nil
+		}
+	}
+}
+
+// Close stops the sweeps,the database is left open for its owner.
+func (self *SQLCache) Close() error {
+	self.Lock()
+	defer self.Unlock()
+
+	if self.closed {
+		return nil
+	}
+
+	self.closed = true
+	if self.exit != nil {
+		close(self.exit)
+		self.exit = nil
+	}
+	if self.err == nil {
+		self.err = errClosed
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package sqlcache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/cachertest"
+)
+
+func open(t *testing.T, dialect string, clock cacher.Clock) (*SQLCache, *fakeDB) {
+	db, fake, err := openFake(dialect, "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	chr := New(WithDB(db), WithDialect(dialect), WithTable("cache"), WithInterval(0), cacher.WithClock(clock))
+	if chr.err != nil {
+		t.Fatal(chr.err)
+	}
+	return chr, fake
+}
+
+func TestConformance(t *testing.T) {
+	for _, dialect := range []string{Postgres, MySQL, SQLite} {
+		t.Run(dialect, func(t *testing.T) {
+			clock := cachertest.NewClock(time.Now())
+			cachertest.Run(t, func(t *testing.T) cacher.ICacher {
+				chr, _ := open(t, dialect, clock)
+				return chr
+			}, cachertest.WithSleep(clock.Advance))
+		})
+	}
+}
+
+func TestDialects(t *testing.T) {
+	q, _ := newQueries(Postgres, "app.cache")
+	if !strings.Contains(q.update, "$4") || strings.Contains(q.update, "?") {
+		t.Fatalf("postgres placeholders %s", q.update)
+	}
+	if len(q.create) != 2 || !strings.Contains(q.create[1], "app_cache_expire_at") {
+		t.Fatalf("postgres create %q", q.create)
+	}
+
+	q, _ = newQueries(MySQL, "cache")
+	if len(q.create) != 1 || !strings.Contains(q.create[0], "INDEX cache_expire_at") || !strings.Contains(q.upsert, "ON DUPLICATE KEY") {
+		t.Fatalf("mysql %q %s", q.create, q.upsert)
+	}
+	if !strings.Contains(q.create[0], "cache_key VARBINARY(250)") {
+		t.Fatalf("mysql keys must compare byte by byte %q", q.create)
+	}
+
+	if _, err := newQueries("oracle", "cache"); err == nil {
+		t.Fatal("unknown dialect is accepted")
+	}
+	if _, err := newQueries(SQLite, "cache; DROP TABLE users"); err == nil {
+		t.Fatal("invalid table is accepted")
+	}
+
+	if chr := New(); chr.err != errNoDB {
+		t.Fatalf("new without db %v", chr.err)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	chr, fake := open(t, SQLite, clock)
+	defer chr.Close()
+
+	chr.Init(WithSweepBatch(4))
+	for i := 0; i < 10; i++ {
+		chr.Set(&cacher.CacheBlock{Key: fmt.Sprintf("short%d", i), Value: i, TTL: time.Second})
+	}
+	chr.Set(&cacher.CacheBlock{Key: "forever", Value: "v", TTL: -1})
+
+	clock.Advance(2 * time.Second)
+	n, err := chr.sweep(context.Background())
+	if err != nil || n != 10 {
+		t.Fatalf("swept %d %v", n, err)
+	}
+
+	// 10条分3批删除
+	if c := fake.count(chr.q.sweep); c != 3 {
+		t.Fatalf("%d sweep statements", c)
+	}
+
+	if len(fake.rows) != 1 {
+		t.Fatalf("%d rows left", len(fake.rows))
+	}
+
+	if ttl, _ := chr.TTL("forever"); ttl != -1 {
+		t.Fatalf("ttl of forever %v", ttl)
+	}
+
+	if err := chr.Expire("forever", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := chr.TTL("forever"); ttl != time.Minute {
+		t.Fatalf("ttl after expire %v", ttl)
+	}
+	if err := chr.Expire("short0", time.Minute); err != cacher.ErrCacheMiss {
+		t.Fatalf("expire missing %v", err)
+	}
+}
+
+func TestSetOnlyNewExpired(t *testing.T) {
+	clock := cachertest.NewClock(time.Now())
+	chr, _ := open(t, Postgres, clock)
+	defer chr.Close()
+
+	chr.Set(&cacher.CacheBlock{Key: "key", Value: "old", TTL: time.Second})
+	clock.Advance(2 * time.Second)
+
+	// 未清扫的过期行不能阻止SetOnlyNew
+	chr.Set(&cacher.CacheBlock{Key: "key", Value: "new", SetOnlyNew: true})
+	if v, err := chr.Get("key"); err != nil || v != "new" {
+		t.Fatalf("get %v %v", v, err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	db, fake, err := openFake(SQLite, "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// 注册表创建时没有数据库,Init重新建表
+	chr, err := cacher.New("sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chr.Init(WithDB(db), WithDialect(SQLite), WithTable("cache"))
+	if err := chr.Set(&cacher.CacheBlock{Key: "key", Value: "value"}); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := chr.Get("key"); err != nil || v != "value" {
+		t.Fatalf("get %v %v", v, err)
+	}
+	if len(fake.rows) != 1 {
+		t.Fatalf("rows %v", fake.rows)
+	}
+
+	chr.Close()
+	chr.Init(WithInterval(0))
+	if err := chr.Set(&cacher.CacheBlock{Key: "key", Value: "value"}); err != errClosed {
+		t.Fatalf("set after close %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package tracing
+
+import (
+	"github.com/volts-dev/cacher"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type (
+	Option func(*Config)
+
+	Config struct {
+		cacher.Config
+		Name           string               // instrumentation name
+		TracerProvider trace.TracerProvider `field:"tracer_provider"`
+	}
+)
+
+func (self *Config) Init(opts ...cacher.Option) {
+	self.Config.Init(self, opts...)
+}
+
+// WithName sets the instrumentation name of the tracer.
+func WithName(name string) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("name", name)
+	}
+}
+
+// WithTracerProvider uses the provider instead of the global one.
+func WithTracerProvider(provider trace.TracerProvider) cacher.Option {
+	return func(cfg *cacher.Config) {
+		cfg.SetByField("tracer_provider", provider)
+	}
+}
@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/volts-dev/cacher"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultName = "github.com/volts-dev/cacher/tracing"
+
+// span attributes
+const (
+	AttrBackend   = attribute.Key("cacher.backend")
+	AttrKeyHash   = attribute.Key("cacher.key_hash")
+	AttrHit       = attribute.Key("cacher.hit")
+	AttrValueSize = attribute.Key("cacher.value_size")
+	AttrKeys      = attribute.Key("cacher.keys")
+)
+
+type (
+	// TracingCache wraps a cacher and creates a span for every operation.
+	TracingCache struct {
+		cacher.ICacher
+		config *Config
+		tracer trace.Tracer
+	}
+)
+
+// New wraps the cacher with tracing.
+func New(chr cacher.ICacher, opts ...cacher.Option) *TracingCache {
+	cfg := &Config{
+		Name: defaultName,
+	}
+	cfg.Init(opts...)
+
+	provider := cfg.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	return &TracingCache{
+		ICacher: chr,
+		config:  cfg,
+		tracer:  provider.Tracer(cfg.Name),
+	}
+}
+
+func (self *TracingCache) Init(opts ...cacher.Option) {
+	self.ICacher.Init(opts...)
+}
+
+// Unwrap returns the underlying cacher.
+func (self *TracingCache) Unwrap() cacher.ICacher {
+	return self.ICacher
+}
+
+func (self *TracingCache) Get(key string, ctx ...context.Context) (any, error) {
+	c, span := self.start(cacher.Context(ctx...), "Get", key)
+	defer span.End()
+
+	value, err := self.ICacher.Get(key, c)
+	switch {
+	case err == nil:
+		span.SetAttributes(AttrHit.Bool(true))
+		if size, ok := valueSize(value); ok {
+			span.SetAttributes(AttrValueSize.Int(size))
+		}
+	case errors.Is(err, cacher.ErrCacheMiss):
+		span.SetAttributes(AttrHit.Bool(false))
+	default:
+		fail(span, err)
+	}
+
+	return value, err
+}
+
+func (self *TracingCache) Set(block *cacher.CacheBlock) error {
+	c, span := self.start(block.Context(), "Set", block.Key)
+	defer span.End()
+
+	if size, ok := valueSize(block.Value); ok {
+		span.SetAttributes(AttrValueSize.Int(size))
+	}
+
+	bb := block.Clone()
+	bb.Ctx = c
+	err := self.ICacher.Set(bb)
+	if err != nil {
+		fail(span, err)
+	}
+
+	return err
+}
+
+func (self *TracingCache) Exists(key string, ctx ...context.Context) bool {
+	c, span := self.start(cacher.Context(ctx...), "Exists", key)
+	defer span.End()
+
+	ok := self.ICacher.Exists(key, c)
+	span.SetAttributes(AttrHit.Bool(ok))
+	return ok
+}
+
+func (self *TracingCache) Delete(key string, ctx ...context.Context) error {
+	c, span := self.start(cacher.Context(ctx...), "Delete", key)
+	defer span.End()
+
+	err := self.ICacher.Delete(key, c)
+	if err != nil {
+		fail(span, err)
+	}
+
+	return err
+}
+
+func (self *TracingCache) Keys(ctx ...context.Context) []string {
+	c, span := self.start(cacher.Context(ctx...), "Keys", "")
+	defer span.End()
+
+	keys := self.ICacher.Keys(c)
+	span.SetAttributes(AttrKeys.Int(len(keys)))
+	return keys
+}
+
+func (self *TracingCache) start(ctx context.Context, op string, key string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		AttrBackend.String(self.ICacher.String()),
+	}
+	if key != "" {
+		attrs = append(attrs, AttrKeyHash.String(hashKey(key)))
+	}
+
+	return self.tracer.Start(ctx, "cacher."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// hashKey hides the key which may contain user data.
+func hashKey(key string) string {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// valueSize returns the size of the value if it is cheap to know.
+func valueSize(value any) (int, bool) {
+	switch v := value.(type) {
+	case []byte:
+		return len(v), true
+	case string:
+		return len(v), true
+	}
+	return 0, false
+}
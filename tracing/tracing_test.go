@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/volts-dev/cacher"
+	"github.com/volts-dev/cacher/memory"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	chr := New(memory.New(), WithTracerProvider(provider))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	chr.Set(&cacher.CacheBlock{Key: "A", Value: "hello", Ctx: ctx})
+	chr.Get("A", ctx)
+	chr.Get("B", ctx)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+
+	for _, span := range spans[:3] {
+		if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %s is not a child of the request", span.Name)
+		}
+	}
+
+	attrs := func(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+		m := make(map[attribute.Key]attribute.Value)
+		for _, kv := range span.Attributes {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+
+	set := attrs(spans[0])
+	if spans[0].Name != "cacher.Set" || set[AttrBackend].AsString() != "memory" || set[AttrValueSize].AsInt64() != 5 {
+		t.Errorf("unexpected set span %s %v", spans[0].Name, set)
+	}
+	if set[AttrKeyHash].AsString() != hashKey("A") {
+		t.Errorf("unexpected key hash %v", set[AttrKeyHash])
+	}
+
+	if hit := attrs(spans[1]); !hit[AttrHit].AsBool() {
+		t.Errorf("expected hit %v", hit)
+	}
+
+	if miss := attrs(spans[2]); miss[AttrHit].AsBool() {
+		t.Errorf("expected miss %v", miss)
+	}
+}